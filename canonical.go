@@ -0,0 +1,308 @@
+package runxml
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// CanonicalMethod is an algorithm of XML canonicalization
+type CanonicalMethod int
+
+// Canonicalization methods
+const (
+	C14N10        CanonicalMethod = iota // Canonical XML 1.0
+	C14N11                               // Canonical XML 1.1
+	ExclusiveC14N                        // Exclusive XML Canonicalization 1.0
+)
+
+// Canonicalizer writes the canonical form of documents and subtrees, for comparing and
+// signing them. Documents should be parsed with PreserveWhitespace, as whitespace between
+// markup is part of the canonical form. The canonical form of an element includes the
+// namespace declarations in scope from its ancestors and, except for Exclusive C14N, their
+// attributes in the xml namespace.
+type Canonicalizer struct {
+	Method            CanonicalMethod
+	WithComments      bool     // Include comments
+	InclusivePrefixes []string // InclusiveNamespaces PrefixList of Exclusive C14N; #default for the default namespace
+}
+
+// Algorithm returns the identifier of the canonicalization method used by XML Signature
+func (c *Canonicalizer) Algorithm() string {
+	var uri string
+	switch c.Method {
+	case C14N10:
+		uri = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	case C14N11:
+		uri = "http://www.w3.org/2006/12/xml-c14n11"
+	case ExclusiveC14N:
+		uri = "http://www.w3.org/2001/10/xml-exc-c14n#"
+		if c.WithComments {
+			return uri + "WithComments"
+		}
+		return uri
+	}
+	if c.WithComments {
+		return uri + "#WithComments"
+	}
+	return uri
+}
+
+// Canonicalize writes the canonical form of the node to w, and returns the number of bytes
+// written
+func (c *Canonicalizer) Canonicalize(w io.Writer, g *GenericNode) (int64, error) {
+	cz := canonicalizer{Canonicalizer: c, printer: printer{w: w}}
+	cz.canonicalize(g)
+	cz.flush()
+	return cz.n, cz.err
+}
+
+// Append appends the canonical form of the node to b
+func (c *Canonicalizer) Append(b []byte, g *GenericNode) ([]byte, error) {
+	cz := canonicalizer{Canonicalizer: c, printer: printer{buf: b}}
+	cz.canonicalize(g)
+	if cz.err != nil {
+		return b, cz.err
+	}
+	return cz.buf, nil
+}
+
+// canonicalizer holds the output of a Canonicalizer
+type canonicalizer struct {
+	*Canonicalizer
+	printer
+}
+
+// canonicalAttribute is an attribute of the canonical form, with its sort keys
+type canonicalAttribute struct {
+	uri, local, name, value []byte
+}
+
+func (c *canonicalizer) canonicalize(g *GenericNode) {
+	if c.Method < C14N10 || c.Method > ExclusiveC14N {
+		c.err = fmt.Errorf("unknown canonicalization method %v", c.Method)
+		return
+	}
+	if g.NodeType != Document {
+		c.node(g, nil, true)
+		return
+	}
+	// Outside the document element, a line break separates comments and PIs from it
+	afterRoot := false
+	for n := g.firstChild; n != nil && c.err == nil; n = n.next {
+		switch n.NodeType {
+		case Element:
+			c.node(n, nil, false)
+			afterRoot = true
+		case Comment, Pi:
+			if n.NodeType == Comment && !c.WithComments {
+				continue
+			}
+			if afterRoot {
+				c.buf = append(c.buf, '\n')
+			}
+			c.node(n, nil, false)
+			if !afterRoot {
+				c.buf = append(c.buf, '\n')
+			}
+		}
+	}
+}
+
+// node writes the canonical form of a node. The namespaces rendered by the ancestors in the
+// output map prefixes to namespace names, with "" for the default namespace. The apex is the
+// node canonicalized without its ancestors.
+func (c *canonicalizer) node(n *GenericNode, rendered map[string]string, apex bool) {
+	if c.err != nil {
+		return
+	}
+	switch n.NodeType {
+	case Element:
+		c.element(n, rendered, apex)
+	case Data, Cdata:
+		c.buf = appendCanonical(c.buf, n.Value, false)
+	case EntityRef:
+		c.err = fmt.Errorf("reference to entity %q cannot be canonicalized without its declaration", n.Name)
+	case Comment:
+		if c.WithComments {
+			c.buf = append(c.buf, "<!--"...)
+			c.buf = append(c.buf, n.Value...)
+			c.buf = append(c.buf, "-->"...)
+		}
+	case Pi:
+		c.buf = append(c.buf, "<?"...)
+		c.buf = append(c.buf, n.Name...)
+		if len(n.Value) > 0 {
+			c.buf = append(c.buf, ' ')
+			c.buf = append(c.buf, n.Value...)
+		}
+		c.buf = append(c.buf, "?>"...)
+	case Document:
+		c.canonicalize(n)
+	}
+	if c.w != nil && len(c.buf) >= printBufferSize {
+		c.flush()
+	}
+}
+
+func (c *canonicalizer) element(n *GenericNode, rendered map[string]string, apex bool) {
+	// Namespace declarations which differ from those rendered by the ancestors
+	var prefixes []string
+	if c.Method == ExclusiveC14N {
+		prefixes = c.utilizedPrefixes(n)
+	} else {
+		prefixes = append(prefixes, "")
+		for _, ns := range inScopeNamespaces(n) {
+			if string(ns.prefix) != "xml" && len(ns.prefix) > 0 {
+				prefixes = append(prefixes, string(ns.prefix))
+			}
+		}
+	}
+	var decls []string
+	for _, prefix := range prefixes {
+		uri := string(n.LookupNamespace([]byte(prefix)))
+		if prefix == "xml" || (uri == "" && prefix != "") || rendered[prefix] == uri {
+			continue
+		}
+		if decls == nil {
+			rendered = maps.Clone(rendered)
+			if rendered == nil {
+				rendered = map[string]string{}
+			}
+		}
+		rendered[prefix] = uri
+		decls = append(decls, prefix)
+	}
+	slices.Sort(decls)
+
+	c.buf = append(c.buf, '<')
+	c.buf = append(c.buf, n.Name...)
+	for _, prefix := range decls {
+		c.buf = append(c.buf, " xmlns"...)
+		if prefix != "" {
+			c.buf = append(c.buf, ':')
+			c.buf = append(c.buf, prefix...)
+		}
+		c.buf = append(c.buf, `="`...)
+		c.buf = appendCanonical(c.buf, []byte(rendered[prefix]), true)
+		c.buf = append(c.buf, '"')
+	}
+	for _, a := range c.attributes(n, apex) {
+		c.buf = append(c.buf, ' ')
+		c.buf = append(c.buf, a.name...)
+		c.buf = append(c.buf, `="`...)
+		c.buf = appendCanonical(c.buf, a.value, true)
+		c.buf = append(c.buf, '"')
+	}
+	c.buf = append(c.buf, '>')
+	for ch := n.firstChild; ch != nil; ch = ch.next {
+		c.node(ch, rendered, false)
+	}
+	c.buf = append(c.buf, "</"...)
+	c.buf = append(c.buf, n.Name...)
+	c.buf = append(c.buf, '>')
+}
+
+// utilizedPrefixes returns the prefixes visibly utilized by the element and its attributes,
+// and those of the InclusiveNamespaces PrefixList
+func (c *canonicalizer) utilizedPrefixes(n *GenericNode) []string {
+	prefixes := []string{string(n.Prefix())}
+	for a := n.firstAttribute; a != nil; a = a.next {
+		if _, ok := namespaceDecl(a.Name); !ok && len(a.Prefix()) > 0 {
+			prefixes = append(prefixes, string(a.Prefix()))
+		}
+	}
+	for _, prefix := range c.InclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+	return slices.Compact(prefixes)
+}
+
+// attributes returns the attributes of the element in canonical order, without namespace
+// declarations. The apex inherits the attributes in the xml namespace of its ancestors,
+// except for Exclusive C14N.
+func (c *canonicalizer) attributes(n *GenericNode, apex bool) []canonicalAttribute {
+	var attrs []canonicalAttribute
+	has := func(name string) bool {
+		return slices.ContainsFunc(attrs, func(a canonicalAttribute) bool { return string(a.name) == name })
+	}
+	for a := n.firstAttribute; a != nil; a = a.next {
+		if _, ok := namespaceDecl(a.Name); ok {
+			continue
+		}
+		attrs = append(attrs, canonicalAttribute{a.NamespaceURI(), a.LocalName(), a.Name, a.Value})
+	}
+	if apex && c.Method != ExclusiveC14N {
+		var bases [][]byte // xml:base of the ancestors, from the nearest
+		for p := n.Parent; p != nil && p.NodeType == Element; p = p.Parent {
+			for a := p.firstAttribute; a != nil; a = a.next {
+				name := string(a.Name)
+				if !bytes.HasPrefix(a.Name, []byte("xml:")) {
+					continue
+				}
+				if c.Method == C14N11 && name == "xml:base" {
+					bases = append(bases, a.Value)
+					continue
+				}
+				if has(name) || (c.Method == C14N11 && name != "xml:lang" && name != "xml:space") {
+					continue
+				}
+				attrs = append(attrs, canonicalAttribute{xmlNamespace, a.LocalName(), a.Name, a.Value})
+			}
+		}
+		if len(bases) > 0 {
+			// xml:base fixup of C14N 1.1 joins the values of the omitted ancestors
+			base := ""
+			for _, b := range slices.Backward(bases) {
+				base = resolveURI(base, string(b))
+			}
+			i := slices.IndexFunc(attrs, func(a canonicalAttribute) bool { return string(a.name) == "xml:base" })
+			if i >= 0 {
+				attrs[i].value = []byte(resolveURI(base, string(attrs[i].value)))
+			} else {
+				attrs = append(attrs, canonicalAttribute{xmlNamespace, []byte("base"), []byte("xml:base"), []byte(base)})
+			}
+		}
+	}
+	slices.SortFunc(attrs, func(a, b canonicalAttribute) int {
+		return cmp.Or(bytes.Compare(a.uri, b.uri), bytes.Compare(a.local, b.local))
+	})
+	return attrs
+}
+
+// appendCanonical appends text escaped as in the canonical form of text or attribute values
+func appendCanonical(b, text []byte, attribute bool) []byte {
+	last := 0
+	for i, c := range text {
+		var esc string
+		switch {
+		case c == '&':
+			esc = "&amp;"
+		case c == '<':
+			esc = "&lt;"
+		case c == '\r':
+			esc = "&#xD;"
+		case c == '>' && !attribute:
+			esc = "&gt;"
+		case c == '"' && attribute:
+			esc = "&quot;"
+		case c == '\t' && attribute:
+			esc = "&#x9;"
+		case c == '\n' && attribute:
+			esc = "&#xA;"
+		default:
+			continue
+		}
+		b = append(b, text[last:i]...)
+		b = append(b, esc...)
+		last = i + 1
+	}
+	return append(b, text[last:]...)
+}
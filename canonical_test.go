@@ -0,0 +1,306 @@
+package runxml
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// mapResolver resolves external entities to the strings of the map
+type mapResolver map[string]string
+
+func (m mapResolver) ResolveEntity(publicID, systemID, baseURI string) (io.ReadCloser, error) {
+	s, ok := m[systemID]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(s)), nil
+}
+
+// Examples of section 3 of Canonical XML Version 1.0
+var c14nExamples = []struct {
+	name, input, canonical, withComments string
+}{
+	{"3.1 PIs, Comments, and Outside of Document Element", `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`, `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!</doc>
+<?pi-without-data?>`, `<?xml-stylesheet href="doc.xsl"
+   type="text/xsl"   ?>
+<doc>Hello, world!<!-- Comment 1 --></doc>
+<?pi-without-data?>
+<!-- Comment 2 -->
+<!-- Comment 3 -->`},
+	{"3.2 Whitespace in Document Content", `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`, `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`, ""},
+	{"3.3 Start and End Tags", `<!DOCTYPE doc [<!ATTLIST e9 attr CDATA "default">]>
+<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`, `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org" attr="default"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`, ""},
+	{"3.4 Character Modifications and Character References", `<!DOCTYPE doc [
+<!ATTLIST normId id ID #IMPLIED>
+<!ATTLIST normNames attr NMTOKENS #IMPLIED>
+]>
+<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+   <normNames attr='   A   &#x20;&#13;&#xa;&#9;   B   '/>
+   <normId id=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`, `<doc>
+   <text>First line&#xD;
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+   <normNames attr="A &#xD;&#xA;&#x9; B"></normNames>
+   <normId id="' &#xD;&#xA;&#x9; '"></normId>
+</doc>`, ""},
+	{"3.5 Entity References", `<!DOCTYPE doc [
+<!ATTLIST doc attrExtEnt ENTITY #IMPLIED>
+<!ENTITY ent1 "Hello">
+<!ENTITY ent2 SYSTEM "world.txt">
+<!ENTITY entExt SYSTEM "earth.gif" NDATA gif>
+<!NOTATION gif SYSTEM "viewgif.exe">
+]>
+<doc attrExtEnt="entExt">
+   &ent1;, &ent2;!
+</doc>
+
+<!-- Let world.txt contain "world" (excluding the quotes) -->`, `<doc attrExtEnt="entExt">
+   Hello, world!
+</doc>`, `<doc attrExtEnt="entExt">
+   Hello, world!
+</doc>
+<!-- Let world.txt contain "world" (excluding the quotes) -->`},
+	{"3.6 UTF-8 Encoding", `<?xml version="1.0" encoding="ISO-8859-1"?>
+<doc>&#169;</doc>`, `<doc>©</doc>`, ""},
+}
+
+// parseCanonical parses a document keeping whitespace, as needed for canonicalization
+func parseCanonical(t *testing.T, xml string) *GenericNode {
+	r := NewDefaultRunXML()
+	r.PreserveWhitespace = true
+	r.EntityResolver = mapResolver{"world.txt": "world"}
+	doc, err := r.Parse([]byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestCanonicalize(t *testing.T) {
+	for _, example := range c14nExamples {
+		doc := parseCanonical(t, example.input)
+		if example.withComments == "" {
+			example.withComments = example.canonical
+		}
+		for _, c := range []Canonicalizer{{Method: C14N10}, {Method: C14N11}} {
+			var buf bytes.Buffer
+			if _, err := c.Canonicalize(&buf, doc); err != nil {
+				t.Error(example.name, err)
+			} else if buf.String() != example.canonical {
+				t.Errorf("%v: expected\n%v\nfound\n%v", example.name, example.canonical, buf.String())
+			}
+			c.WithComments = true
+			if b, err := c.Append(nil, doc); err != nil {
+				t.Error(example.name, err)
+			} else if string(b) != example.withComments {
+				t.Errorf("%v with comments: expected\n%v\nfound\n%s", example.name, example.withComments, b)
+			}
+		}
+	}
+}
+
+// TestCanonicalizeSubtree canonicalizes the n1:elem2 element of the examples of section 2.2 of
+// Exclusive XML Canonicalization Version 1.0
+func TestCanonicalizeSubtree(t *testing.T) {
+	doc1 := parseCanonical(t, `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+     <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`)
+	doc2 := parseCanonical(t, `<n2:pdu xmlns:n1="http://example.com" xmlns:n2="http://foo.example" xml:lang="fr" xml:space="retain">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+     <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n2:pdu>`)
+	exclusive := `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+     <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`
+	for _, test := range []struct {
+		doc       *GenericNode
+		c         Canonicalizer
+		canonical string
+	}{
+		{doc1, Canonicalizer{Method: C14N10}, `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en">
+     <n3:stuff></n3:stuff>
+  </n1:elem2>`},
+		{doc2, Canonicalizer{Method: C14N10}, `<n1:elem2 xmlns:n1="http://example.net" xmlns:n2="http://foo.example" xml:lang="en" xml:space="retain">
+     <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`},
+		{doc1, Canonicalizer{Method: ExclusiveC14N}, exclusive},
+		{doc2, Canonicalizer{Method: ExclusiveC14N}, exclusive},
+		{doc1, Canonicalizer{Method: ExclusiveC14N, InclusivePrefixes: []string{"n0", "n2"}}, `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xml:lang="en">
+     <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`},
+		{doc2, Canonicalizer{Method: ExclusiveC14N, InclusivePrefixes: []string{"n0", "n2"}}, `<n1:elem2 xmlns:n1="http://example.net" xmlns:n2="http://foo.example" xml:lang="en">
+     <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>`},
+	} {
+		elem2, err := test.doc.QuerySelector(`n1\:elem2`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := test.c.Append(nil, elem2); err != nil {
+			t.Error(err)
+		} else if string(b) != test.canonical {
+			t.Errorf("%v: expected\n%v\nfound\n%s", test.c.Algorithm(), test.canonical, b)
+		}
+	}
+}
+
+func TestCanonicalizeLineEnds(t *testing.T) {
+	// Literal line ends are normalized to LF, and literal whitespace in attribute values to
+	// spaces, while characters from character references remain
+	xml := "<!DOCTYPE doc [<!ATTLIST doc names NMTOKENS #IMPLIED><!ENTITY tab \"a\tb\">]>\r\n" +
+		"<doc a='x\ny\tz\r\nw\rv' b=\"&tab;&#9;&#13;\" names=\"\r\n A\t\tB&#9;\r\n\">\r\n" +
+		"\tFirst\rSecond\r\n&#13;<![CDATA[x\r\ny]]><!--c\r\n-->\r</doc>"
+	expected := "<doc a=\"x y z w v\" b=\"a b&#x9;&#xD;\" names=\"A B&#x9;\">\n" +
+		"\tFirst\nSecond\n&#xD;x\ny<!--c\n-->\n</doc>"
+	r := NewDefaultRunXML()
+	r.PreserveWhitespace = true
+	parsed, err := r.Parse([]byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Line ends split across reads
+	streamed, err := r.ParseReader(iotest.OneByteReader(strings.NewReader(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []*GenericNode{parsed, streamed} {
+		c := Canonicalizer{Method: C14N11, WithComments: true}
+		if b, err := c.Append(nil, doc); err != nil {
+			t.Error(err)
+		} else if string(b) != expected {
+			t.Errorf("expected\n%q\nfound\n%q", expected, b)
+		}
+	}
+}
+
+func TestCanonicalizeNamespaces(t *testing.T) {
+	doc := parseCanonical(t, `<a xmlns="urn:a" xmlns:p="urn:p" xml:base="http://example.org/x/" xml:id="a">`+
+		`<b xml:base="y/" p:q="1" xml:space="preserve"><c xml:base="z"/><p:d xmlns=""><e/></p:d></b></a>`)
+	b, _ := doc.QuerySelector("b")
+	for _, test := range []struct {
+		node      *GenericNode
+		c         Canonicalizer
+		canonical string
+	}{
+		{b, Canonicalizer{Method: C14N10}, `<b xmlns="urn:a" xmlns:p="urn:p" xml:base="y/" xml:id="a" xml:space="preserve" p:q="1">` +
+			`<c xml:base="z"></c><p:d xmlns=""><e></e></p:d></b>`},
+		{b, Canonicalizer{Method: C14N11}, `<b xmlns="urn:a" xmlns:p="urn:p" xml:base="http://example.org/x/y/" xml:space="preserve" p:q="1">` +
+			`<c xml:base="z"></c><p:d xmlns=""><e></e></p:d></b>`},
+		{b.GetFirstChild(), Canonicalizer{Method: C14N11}, `<c xmlns="urn:a" xmlns:p="urn:p" xml:base="http://example.org/x/y/z" xml:space="preserve"></c>`},
+		{b, Canonicalizer{Method: ExclusiveC14N}, `<b xmlns="urn:a" xmlns:p="urn:p" xml:base="y/" xml:space="preserve" p:q="1">` +
+			`<c xml:base="z"></c><p:d><e xmlns=""></e></p:d></b>`},
+		{b.GetLastChild(), Canonicalizer{Method: ExclusiveC14N}, `<p:d xmlns:p="urn:p"><e></e></p:d>`},
+		{b.GetLastChild(), Canonicalizer{Method: ExclusiveC14N, InclusivePrefixes: []string{"#default"}}, `<p:d xmlns:p="urn:p"><e></e></p:d>`},
+		{b.GetLastChild(), Canonicalizer{Method: C14N10}, `<p:d xmlns:p="urn:p" xml:base="y/" xml:id="a" xml:space="preserve"><e></e></p:d>`},
+	} {
+		if s, err := test.c.Append(nil, test.node); err != nil {
+			t.Error(err)
+		} else if string(s) != test.canonical {
+			t.Errorf("%v: expected\n%v\nfound\n%s", test.c.Algorithm(), test.canonical, s)
+		}
+	}
+	for _, test := range []struct {
+		c         Canonicalizer
+		algorithm string
+	}{
+		{Canonicalizer{Method: C14N10}, "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"},
+		{Canonicalizer{Method: C14N11, WithComments: true}, "http://www.w3.org/2006/12/xml-c14n11#WithComments"},
+		{Canonicalizer{Method: ExclusiveC14N, WithComments: true}, "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"},
+	} {
+		if a := test.c.Algorithm(); a != test.algorithm {
+			t.Errorf("expected %v, found %v", test.algorithm, a)
+		}
+	}
+}
+
+func TestCanonicalizeEntityRef(t *testing.T) {
+	// The external subset is not read, so the replacement text of the entity is unknown
+	doc, err := NewDefaultRunXML().Parse([]byte(`<!DOCTYPE doc SYSTEM "doc.dtd"><doc>a&e;</doc>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	c := Canonicalizer{Method: C14N11}
+	if _, err := c.Append(nil, doc); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,159 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CatalogResolver resolves external entities through an OASIS XML Catalog to entity
+// contents held in memory. The public, system, rewriteSystem and systemSuffix entries
+// are supported, optionally inside group elements; delegation and next catalogs are not.
+// Entities not matched by the catalog are looked up by their system identifier.
+type CatalogResolver struct {
+	Entities map[string][]byte // Entity contents by URI
+	entries  []catalogEntry
+}
+
+// catalogEntry is a public, system, rewriteSystem or systemSuffix catalog entry
+type catalogEntry struct {
+	kind         string // Entry element name
+	match        string // Public identifier, system identifier, prefix, or suffix to match
+	uri          string // Replacement URI or prefix, resolved against the base URI of the entry
+	preferPublic bool   // Public entries apply even if a system identifier is given
+}
+
+// NewCatalogResolver parses an OASIS XML Catalog document and returns a resolver
+// providing the given entity contents
+func NewCatalogResolver(catalog []byte, entities map[string][]byte) (*CatalogResolver, error) {
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(bytes.Clone(catalog)) // parsing modifies the data
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse catalog: %w", err)
+	}
+	c := &CatalogResolver{Entities: entities}
+	for n := doc.GetFirstChild(); n != nil; n = n.GetNextSibling() {
+		if n.NodeType == Element {
+			if catalogName(n.Name) != "catalog" {
+				return nil, fmt.Errorf("unexpected catalog root element %q", n.Name)
+			}
+			c.addEntries(n, "", true)
+		}
+	}
+	return c, nil
+}
+
+// addEntries adds the entries of a catalog or group element
+func (c *CatalogResolver) addEntries(parent *GenericNode, base string, preferPublic bool) {
+	attrs := catalogAttributes(parent)
+	if b, ok := attrs["xml:base"]; ok {
+		base = resolveURI(base, b)
+	}
+	if prefer, ok := attrs["prefer"]; ok {
+		preferPublic = prefer == "public"
+	}
+	for n := parent.GetFirstChild(); n != nil; n = n.GetNextSibling() {
+		if n.NodeType != Element {
+			continue
+		}
+		attrs := catalogAttributes(n)
+		entryBase := base
+		if b, ok := attrs["xml:base"]; ok {
+			entryBase = resolveURI(base, b)
+		}
+		e := catalogEntry{kind: catalogName(n.Name), preferPublic: preferPublic}
+		switch e.kind {
+		case "group":
+			c.addEntries(n, base, preferPublic)
+			continue
+		case "public":
+			e.match, e.uri = normalizePublicID(attrs["publicId"]), attrs["uri"]
+		case "system":
+			e.match, e.uri = attrs["systemId"], attrs["uri"]
+		case "rewriteSystem":
+			e.match, e.uri = attrs["systemIdStartString"], attrs["rewritePrefix"]
+		case "systemSuffix":
+			e.match, e.uri = attrs["systemIdSuffix"], attrs["uri"]
+		default:
+			continue // not supported
+		}
+		e.uri = resolveURI(entryBase, e.uri)
+		c.entries = append(c.entries, e)
+	}
+}
+
+// ResolveEntity implements EntityResolver
+func (c *CatalogResolver) ResolveEntity(publicID, systemID, baseURI string) (io.ReadCloser, error) {
+	uri, ok := c.lookup(publicID, systemID)
+	if !ok {
+		uri = resolveURI(baseURI, systemID)
+	}
+	data, ok := c.Entities[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not in the catalog", ErrEntityRefused, uri)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// lookup returns the URI the catalog maps the identifiers to. System entries take
+// precedence over public entries; the longest matching prefix or suffix is used.
+func (c *CatalogResolver) lookup(publicID, systemID string) (string, bool) {
+	if systemID != "" {
+		var rewrite, suffix *catalogEntry
+		for i := range c.entries {
+			e := &c.entries[i]
+			switch e.kind {
+			case "system":
+				if e.match == systemID {
+					return e.uri, true
+				}
+			case "rewriteSystem":
+				if strings.HasPrefix(systemID, e.match) && (rewrite == nil || len(e.match) > len(rewrite.match)) {
+					rewrite = e
+				}
+			case "systemSuffix":
+				if strings.HasSuffix(systemID, e.match) && (suffix == nil || len(e.match) > len(suffix.match)) {
+					suffix = e
+				}
+			}
+		}
+		if rewrite != nil {
+			return rewrite.uri + systemID[len(rewrite.match):], true
+		}
+		if suffix != nil {
+			return suffix.uri, true
+		}
+	}
+	if publicID != "" {
+		publicID = normalizePublicID(publicID)
+		for _, e := range c.entries {
+			if e.kind == "public" && e.match == publicID && (e.preferPublic || systemID == "") {
+				return e.uri, true
+			}
+		}
+	}
+	return "", false
+}
+
+// catalogName returns an element name without namespace prefix
+func catalogName(name []byte) string {
+	if i := bytes.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return string(name)
+}
+
+// catalogAttributes returns the attributes of a catalog element by name
+func catalogAttributes(n *GenericNode) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range n.GetAttributes() {
+		attrs[string(a.Name)] = string(a.Value)
+	}
+	return attrs
+}
+
+// normalizePublicID normalizes the whitespace of a public identifier
+func normalizePublicID(id string) string {
+	return strings.Join(strings.Fields(id), " ")
+}
@@ -0,0 +1,386 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// CompactDocument is a parsed document stored in flat slices instead of linked nodes. Nodes
+// refer to the parsed data and to each other by int32 offsets and indices, so the tree holds
+// no pointers for the garbage collector to scan, and takes a fraction of the memory of a tree
+// of GenericNode. It is read-only, and accessed through NodeRef handles.
+type CompactDocument struct {
+	data       []byte             // Data buffer the document was parsed from
+	extra      []byte             // Values that are not in data, such as entity replacement text
+	nodes      []compactNode      // Nodes in document order; the document node is at index 0
+	attributes []compactAttribute // Attributes of the nodes, in order of their nodes
+	dtd        *DTD               // Declarations of the document type declaration
+}
+
+// span is the location of a name or value; offsets from len(data) are in extra
+type span struct {
+	start, end int32
+}
+
+// compactNode is a node of a CompactDocument. The first child of a node is the next node in
+// document order. Index 0 is never a child or sibling, so it marks missing nodes.
+type compactNode struct {
+	name, value   span
+	parent        int32 // Index of the parent node
+	prev, next    int32 // Indices of the siblings
+	lastChild     int32 // Index of the last child
+	attributes    int32 // Index of the first attribute
+	numAttributes int32 // Number of attributes
+	nodeType      uint8 // NodeType of the node
+}
+
+// compactAttribute is an attribute of a CompactDocument
+type compactAttribute struct {
+	name, value span
+}
+
+// ParseCompact parses the entire byte slice into a CompactDocument. The size of the document
+// is limited to 2 GiB, as are the number of nodes and attributes.
+func (r *RunXML) ParseCompact(b []byte) (*CompactDocument, error) {
+	data, err := r.decodeDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > math.MaxInt32 {
+		return nil, fmt.Errorf("document of %v bytes too large for compact storage", len(data))
+	}
+	r.reset(r.BaseURI)
+	r.data = data
+	r.docSize = len(b)
+	c := newCompactBuilder(data)
+	r.events.compact = c
+	r.events.reuse = true
+	defer func() {
+		r.events.compact = nil
+		r.events.reuse = false
+	}()
+	if _, err := r.parseDocument(); err != nil {
+		return nil, err
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.doc.dtd = r.dtd
+	return c.doc, nil
+}
+
+// Root returns the document node
+func (d *CompactDocument) Root() NodeRef {
+	return NodeRef{d, 0}
+}
+
+// Len returns the number of nodes of the document, including the document node
+func (d *CompactDocument) Len() int {
+	return len(d.nodes)
+}
+
+// DTD returns the declarations of the document type declaration, or nil
+func (d *CompactDocument) DTD() *DTD {
+	return d.dtd
+}
+
+// bytes returns the bytes at a span
+func (d *CompactDocument) bytes(s span) []byte {
+	switch {
+	case s.start == s.end:
+		return nil
+	case int(s.start) < len(d.data):
+		return d.data[s.start:s.end]
+	}
+	return d.extra[int(s.start)-len(d.data) : int(s.end)-len(d.data)]
+}
+
+// compactBuilder adds the nodes passed by the parser to a CompactDocument
+type compactBuilder struct {
+	doc  *CompactDocument
+	base uintptr // Address of the data buffer
+	open []int32 // Open elements; the last one is the parent of new nodes
+	err  error   // Error that ended building
+}
+
+func newCompactBuilder(data []byte) *compactBuilder {
+	// Most nodes start with a '<', or are text followed by an end tag; attributes have a '='
+	doc := &CompactDocument{
+		data:       data,
+		nodes:      make([]compactNode, 1, bytes.Count(data, []byte("<"))+1),
+		attributes: make([]compactAttribute, 0, bytes.Count(data, []byte("="))),
+	}
+	doc.nodes[0].nodeType = uint8(Document)
+	return &compactBuilder{doc: doc, base: uintptr(unsafe.Pointer(unsafe.SliceData(data))), open: []int32{0}}
+}
+
+// add appends a node to the children of the current parent, and returns its index
+func (c *compactBuilder) add(n *GenericNode) (int32, error) {
+	d := c.doc
+	if len(d.nodes) == math.MaxInt32 {
+		c.err = fmt.Errorf("too many nodes for compact storage")
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	i := int32(len(d.nodes))
+	parent := c.open[len(c.open)-1]
+	cn := compactNode{
+		name:       c.span(n.Name),
+		value:      c.span(n.Value),
+		parent:     parent,
+		attributes: int32(len(d.attributes)),
+		nodeType:   uint8(n.NodeType),
+	}
+	for a := n.firstAttribute; a != nil; a = a.next {
+		if len(d.attributes) == math.MaxInt32 {
+			c.err = fmt.Errorf("too many attributes for compact storage")
+			return 0, c.err
+		}
+		d.attributes = append(d.attributes, compactAttribute{c.span(a.Name), c.span(a.Value)})
+		cn.numAttributes++
+	}
+	p := &d.nodes[parent]
+	if p.lastChild != 0 {
+		cn.prev = p.lastChild
+		d.nodes[p.lastChild].next = i
+	}
+	p.lastChild = i
+	if n.NodeType == Data {
+		p.value = cn.value // as the value of GenericNode elements
+	}
+	d.nodes = append(d.nodes, cn)
+	return i, c.err
+}
+
+// startElement adds an element, which becomes the parent of the following nodes
+func (c *compactBuilder) startElement(element *GenericNode) error {
+	i, err := c.add(element)
+	if err != nil {
+		return err
+	}
+	c.open = append(c.open, i)
+	return nil
+}
+
+// endElement makes the parent of the current element the parent of the following nodes
+func (c *compactBuilder) endElement() {
+	c.open = c.open[:len(c.open)-1]
+}
+
+// span returns the span of b, which is copied to the extra data unless it is in the data buffer
+func (c *compactBuilder) span(b []byte) span {
+	if len(b) == 0 {
+		return span{}
+	}
+	d := c.doc
+	if off := uintptr(unsafe.Pointer(unsafe.SliceData(b))) - c.base; off < uintptr(len(d.data)) && int(off)+len(b) <= len(d.data) {
+		return span{int32(off), int32(int(off) + len(b))}
+	}
+	start := len(d.data) + len(d.extra)
+	if start+len(b) > math.MaxInt32 {
+		c.err = fmt.Errorf("document too large for compact storage")
+		return span{}
+	}
+	d.extra = append(d.extra, b...)
+	return span{int32(start), int32(start + len(b))}
+}
+
+// NodeRef is a handle of a node of a CompactDocument. The zero NodeRef refers to no node, and
+// is returned when a node does not exist, where the methods of GenericNode return nil. Its
+// methods return nil, 0 or the zero NodeRef.
+type NodeRef struct {
+	doc *CompactDocument
+	i   int32
+}
+
+// IsZero reports whether the handle refers to no node
+func (n NodeRef) IsZero() bool {
+	return n.doc == nil
+}
+
+// Document returns the document of the node
+func (n NodeRef) Document() *CompactDocument {
+	return n.doc
+}
+
+// noNode is the stored node of the zero NodeRef, without name, value, relatives or attributes
+var noNode compactNode
+
+// node returns the stored node, or noNode for the zero NodeRef
+func (n NodeRef) node() *compactNode {
+	if n.doc == nil {
+		return &noNode
+	}
+	return &n.doc.nodes[n.i]
+}
+
+// ref returns the handle of the node at index i of the document, or the zero NodeRef for 0
+func (n NodeRef) ref(i int32) NodeRef {
+	if i == 0 {
+		return NodeRef{}
+	}
+	return NodeRef{n.doc, i}
+}
+
+// NodeType returns the type of the node
+func (n NodeRef) NodeType() NodeType {
+	return NodeType(n.node().nodeType)
+}
+
+// Name returns the name of the node, as the Name of GenericNode
+func (n NodeRef) Name() []byte {
+	return n.doc.bytes(n.node().name)
+}
+
+// Value returns the value of the node, as the Value of GenericNode
+func (n NodeRef) Value() []byte {
+	return n.doc.bytes(n.node().value)
+}
+
+// Parent returns the parent of the node, or the zero NodeRef for the document node
+func (n NodeRef) Parent() NodeRef {
+	if n.i == 0 {
+		return NodeRef{}
+	}
+	return NodeRef{n.doc, n.node().parent}
+}
+
+// FirstChild returns the first child of the node, or the zero NodeRef
+func (n NodeRef) FirstChild() NodeRef {
+	if n.node().lastChild == 0 {
+		return NodeRef{}
+	}
+	return NodeRef{n.doc, n.i + 1}
+}
+
+// LastChild returns the last child of the node, or the zero NodeRef
+func (n NodeRef) LastChild() NodeRef {
+	return n.ref(n.node().lastChild)
+}
+
+// NextSibling returns the next sibling of the node, or the zero NodeRef
+func (n NodeRef) NextSibling() NodeRef {
+	return n.ref(n.node().next)
+}
+
+// PreviousSibling returns the previous sibling of the node, or the zero NodeRef
+func (n NodeRef) PreviousSibling() NodeRef {
+	return n.ref(n.node().prev)
+}
+
+// NumAttributes returns the number of attributes of the node
+func (n NodeRef) NumAttributes() int {
+	return int(n.node().numAttributes)
+}
+
+// Attribute returns the i'th attribute of the node, or the zero AttributeRef if the node has
+// no i'th attribute
+func (n NodeRef) Attribute(i int) AttributeRef {
+	if i < 0 || i >= n.NumAttributes() {
+		return AttributeRef{}
+	}
+	return AttributeRef{n, n.node().attributes + int32(i)}
+}
+
+// AttributeValue returns the value of the attribute with the given name, and whether the
+// node has the attribute
+func (n NodeRef) AttributeValue(name []byte) ([]byte, bool) {
+	for i := range n.NumAttributes() {
+		if a := n.Attribute(i); bytes.Equal(a.Name(), name) {
+			return a.Value(), true
+		}
+	}
+	return nil, false
+}
+
+// Prefix returns the namespace prefix of the element name, or nil
+func (n NodeRef) Prefix() []byte {
+	prefix, _ := splitQName(n.Name())
+	return prefix
+}
+
+// LocalName returns the element name without namespace prefix
+func (n NodeRef) LocalName() []byte {
+	_, local := splitQName(n.Name())
+	return local
+}
+
+// NamespaceURI returns the namespace name of the element, or nil if it is in no namespace
+func (n NodeRef) NamespaceURI() []byte {
+	return n.LookupNamespace(n.Prefix())
+}
+
+// LookupNamespace returns the namespace name bound to prefix by the namespace declarations
+// in scope of the node, as LookupNamespace of GenericNode
+func (n NodeRef) LookupNamespace(prefix []byte) []byte {
+	switch string(prefix) {
+	case "xml":
+		return xmlNamespace
+	case "xmlns":
+		return xmlnsNamespace
+	}
+	for ; !n.IsZero() && n.NodeType() == Element; n = n.Parent() {
+		for i := range n.NumAttributes() {
+			a := n.Attribute(i)
+			if declPrefix, ok := namespaceDecl(a.Name()); ok && bytes.Equal(declPrefix, prefix) {
+				return a.Value()
+			}
+		}
+	}
+	return nil
+}
+
+// AttributeRef is a handle of an attribute of a CompactDocument. The methods of the zero
+// AttributeRef return nil or the zero NodeRef.
+type AttributeRef struct {
+	parent NodeRef
+	i      int32
+}
+
+// Parent returns the node of the attribute
+func (a AttributeRef) Parent() NodeRef {
+	return a.parent
+}
+
+// Name returns the name of the attribute
+func (a AttributeRef) Name() []byte {
+	if a.parent.doc == nil {
+		return nil
+	}
+	return a.parent.doc.bytes(a.parent.doc.attributes[a.i].name)
+}
+
+// Value returns the value of the attribute
+func (a AttributeRef) Value() []byte {
+	if a.parent.doc == nil {
+		return nil
+	}
+	return a.parent.doc.bytes(a.parent.doc.attributes[a.i].value)
+}
+
+// Prefix returns the namespace prefix of the attribute name, or nil
+func (a AttributeRef) Prefix() []byte {
+	prefix, _ := splitQName(a.Name())
+	return prefix
+}
+
+// LocalName returns the attribute name without namespace prefix
+func (a AttributeRef) LocalName() []byte {
+	_, local := splitQName(a.Name())
+	return local
+}
+
+// NamespaceURI returns the namespace name of the attribute, as NamespaceURI of AttributeNode
+func (a AttributeRef) NamespaceURI() []byte {
+	prefix := a.Prefix()
+	if prefix == nil {
+		if string(a.Name()) == "xmlns" {
+			return xmlnsNamespace
+		}
+		return nil
+	}
+	return a.parent.LookupNamespace(prefix)
+}
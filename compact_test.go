@@ -0,0 +1,170 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeCompactTree writes the nodes of a compact document as writeTree does
+func writeCompactTree(sb *strings.Builder, n NodeRef) {
+	fmt.Fprintf(sb, "%v %q %q", n.NodeType(), n.Name(), n.Value())
+	for i := 0; i < n.NumAttributes(); i++ {
+		a := n.Attribute(i)
+		fmt.Fprintf(sb, " %q=%q", a.Name(), a.Value())
+	}
+	sb.WriteByte('\n')
+	for c := n.FirstChild(); !c.IsZero(); c = c.NextSibling() {
+		if c.Parent() != n {
+			sb.WriteString("wrong parent\n")
+		}
+		writeCompactTree(sb, c)
+	}
+	sb.WriteString("end\n")
+}
+
+// TestParseCompact compares the compact documents of the conformance test files to their trees
+func TestParseCompact(t *testing.T) {
+	numFiles := 0
+	for _, dir := range testDirs {
+		files, _ := filepath.Glob(dir.path)
+		for _, fn := range files {
+			if dir.exclusion[filepath.Base(fn)] {
+				continue
+			}
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewDefaultRunXML()
+			r.EntityResolver = NewFileResolver("xmltestfiles")
+			r.BaseURI = filepath.ToSlash(must(filepath.Abs(fn)))
+			doc, err := r.Parse(bytes.Clone(b))
+			if err != nil {
+				t.Fatal(fn, err)
+			}
+			var expected strings.Builder
+			writeTree(&expected, doc)
+			compact, err := r.ParseCompact(b)
+			if err != nil {
+				t.Fatal(fn, err)
+			}
+			var sb strings.Builder
+			writeCompactTree(&sb, compact.Root())
+			if sb.String() != expected.String() {
+				t.Errorf("%v: compact document differs from the tree of Parse:\n%v\n%v", fn, sb.String(), expected.String())
+			}
+			numFiles++
+		}
+	}
+	t.Log("Files tested", numFiles)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestNodeRef(t *testing.T) {
+	doc, err := NewDefaultRunXML().ParseCompact(bytes.Clone(messagePayload))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.Root()
+	if root.NodeType() != Document || !root.Parent().IsZero() || root.Document() != doc {
+		t.Fatal("unexpected document node")
+	}
+	envelope := root.LastChild()
+	if string(envelope.LocalName()) != "Envelope" || string(envelope.NamespaceURI()) != "http://www.w3.org/2003/05/soap-envelope" {
+		t.Fatalf("unexpected element %s", envelope.Name())
+	}
+	order := envelope.LastChild().LastChild()
+	if string(order.NamespaceURI()) != "urn:example:orders" || string(order.Prefix()) != "m" {
+		t.Fatalf("unexpected element %s", order.Name())
+	}
+	if v, ok := order.AttributeValue([]byte("currency")); !ok || string(v) != "EUR" {
+		t.Errorf("expected currency EUR, found %q", v)
+	}
+	if _, ok := order.AttributeValue([]byte("missing")); ok {
+		t.Error("expected no attribute")
+	}
+	if order.NumAttributes() != 3 || order.Attribute(2).Parent() != order || order.Attribute(0).NamespaceURI() != nil {
+		t.Error("unexpected attributes")
+	}
+	note := order.LastChild()
+	if string(note.LocalName()) != "Note" || note.FirstChild().NodeType() != Cdata || string(note.FirstChild().Value()) != "Deliver <before> noon" {
+		t.Errorf("unexpected element %s", note.Name())
+	}
+	customer := order.FirstChild()
+	if string(customer.Value()) != "Smith & Sons Ltd." {
+		t.Errorf("unexpected value %q", customer.Value())
+	}
+	if !customer.FirstChild().FirstChild().IsZero() || !customer.FirstChild().NextSibling().IsZero() {
+		t.Error("expected no further nodes")
+	}
+}
+
+func TestZeroNodeRef(t *testing.T) {
+	doc, err := NewDefaultRunXML().ParseCompact([]byte(`<r a="1">text</r>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	for _, n := range []NodeRef{{}, doc.Root().Parent(), doc.Root().LastChild().NextSibling()} {
+		if !n.IsZero() || n.Document() != nil || n.NodeType() != 0 || n.NumAttributes() != 0 {
+			t.Error("expected zero node")
+		}
+		if n.Name() != nil || n.Value() != nil || n.LocalName() != nil || n.NamespaceURI() != nil {
+			t.Error("expected no name or value")
+		}
+		if !n.Parent().IsZero() || !n.FirstChild().IsZero() || !n.LastChild().IsZero() ||
+			!n.NextSibling().IsZero() || !n.PreviousSibling().IsZero() {
+			t.Error("expected no relatives")
+		}
+		if _, ok := n.AttributeValue([]byte("a")); ok {
+			t.Error("expected no attribute")
+		}
+	}
+	root := doc.Root().FirstChild()
+	for _, a := range []AttributeRef{{}, root.Attribute(-1), root.Attribute(1), NodeRef{}.Attribute(0)} {
+		if a.Name() != nil || a.Value() != nil || a.LocalName() != nil || a.NamespaceURI() != nil || !a.Parent().IsZero() {
+			t.Error("expected zero attribute")
+		}
+	}
+	if v := root.Attribute(0).Value(); string(v) != "1" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+}
+
+// BenchmarkParseCompact compares the memory of compact documents with BenchmarkParseTree
+func BenchmarkParseCompact(b *testing.B) {
+	xml := streamTestDoc(20000)
+	buf := make([]byte, len(xml))
+	r := NewDefaultRunXML()
+	b.SetBytes(int64(len(xml)))
+	b.ReportAllocs()
+	for b.Loop() {
+		copy(buf, xml)
+		if _, err := r.ParseCompact(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseTree(b *testing.B) {
+	xml := streamTestDoc(20000)
+	buf := make([]byte, len(xml))
+	r := NewDefaultRunXML()
+	b.SetBytes(int64(len(xml)))
+	b.ReportAllocs()
+	for b.Loop() {
+		copy(buf, xml)
+		if _, err := r.Parse(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
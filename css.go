@@ -0,0 +1,511 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Selector is a compiled CSS selector, matched against elements of GenericNode trees. Type
+// selectors are compared with the qualified names of elements; a colon in a name is escaped
+// as in CSS, for example soap\:Body. The id and class shorthands refer to the attributes id
+// and class.
+type Selector struct {
+	text  string
+	group []complexSelector
+}
+
+// complexSelector is a sequence of compound selectors separated by combinators
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte // ' ', '>', '+' or '~' before each compound except the first
+}
+
+// compoundSelector is a type selector and the conditions an element must meet
+type compoundSelector struct {
+	name       string // Element name, or empty for any
+	conditions []cssCondition
+}
+
+// cssCondition is an attribute selector or pseudo-class
+type cssCondition struct {
+	kind  byte // '[' for attributes; ':' for pseudo-classes
+	name  string
+	op    string    // Attribute operator, or empty for presence
+	value string    // Attribute value
+	a, b  int       // an+b of :nth-child and :nth-last-child
+	not   *Selector // Argument of :not
+}
+
+// CompileSelector compiles a group of CSS selectors separated by commas
+func CompileSelector(sel string) (*Selector, error) {
+	p := &cssParser{s: sel}
+	s, err := p.parseGroup()
+	if err == nil && p.pos < len(p.s) {
+		err = fmt.Errorf("unexpected %q at position %v", p.s[p.pos], p.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("selector %q: %w", sel, err)
+	}
+	return s, nil
+}
+
+// String returns the source text of the selector
+func (s *Selector) String() string {
+	return s.text
+}
+
+// Match reports whether the node is an element matched by the selector
+func (s *Selector) Match(n *GenericNode) bool {
+	if n == nil || n.NodeType != Element {
+		return false
+	}
+	for i := range s.group {
+		c := &s.group[i]
+		if c.match(n, len(c.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// QuerySelector returns the first element below the node matched by the selector, in
+// document order, or nil
+func (g *GenericNode) QuerySelector(sel string) (*GenericNode, error) {
+	s, err := CompileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	for n := range g.Descendants() {
+		if s.Match(n) {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// QuerySelectorAll returns the elements below the node matched by the selector, in
+// document order
+func (g *GenericNode) QuerySelectorAll(sel string) ([]*GenericNode, error) {
+	s, err := CompileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*GenericNode
+	for n := range g.Descendants() {
+		if s.Match(n) {
+			ret = append(ret, n)
+		}
+	}
+	return ret, nil
+}
+
+// match reports whether the element matches the compound selector at index i, and the
+// compounds before it match the elements related by the combinators
+func (c *complexSelector) match(n *GenericNode, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c.combinators[i-1] {
+	case '>':
+		p := n.Parent
+		return p != nil && p.NodeType == Element && c.match(p, i-1)
+	case '+':
+		s := previousElement(n)
+		return s != nil && c.match(s, i-1)
+	case '~':
+		for s := previousElement(n); s != nil; s = previousElement(s) {
+			if c.match(s, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+	for a := n.Parent; a != nil && a.NodeType == Element; a = a.Parent {
+		if c.match(a, i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// previousElement returns the element sibling before the node, or nil
+func previousElement(n *GenericNode) *GenericNode {
+	for s := n.prev; s != nil; s = s.prev {
+		if s.NodeType == Element {
+			return s
+		}
+	}
+	return nil
+}
+
+// nextElement returns the element sibling after the node, or nil
+func nextElement(n *GenericNode) *GenericNode {
+	for s := n.next; s != nil; s = s.next {
+		if s.NodeType == Element {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *compoundSelector) match(n *GenericNode) bool {
+	if c.name != "" && string(n.Name) != c.name {
+		return false
+	}
+	for i := range c.conditions {
+		if !c.conditions[i].match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *cssCondition) match(n *GenericNode) bool {
+	if c.kind == '[' {
+		for a := range n.Attributes() {
+			if string(a.Name) == c.name {
+				return matchAttributeValue(c.op, a.Value, c.value)
+			}
+		}
+		return false
+	}
+	switch c.name {
+	case "first-child":
+		return previousElement(n) == nil
+	case "last-child":
+		return nextElement(n) == nil
+	case "only-child":
+		return previousElement(n) == nil && nextElement(n) == nil
+	case "root":
+		return n.Parent == nil || n.Parent.NodeType == Document
+	case "empty":
+		for ch := range n.Children() {
+			if ch.NodeType == Element || ch.NodeType == Data || ch.NodeType == Cdata {
+				return false
+			}
+		}
+		return true
+	case "not":
+		return !c.not.Match(n)
+	case "nth-child", "nth-last-child":
+		pos := 1
+		sibling := previousElement
+		if c.name == "nth-last-child" {
+			sibling = nextElement
+		}
+		for s := sibling(n); s != nil; s = sibling(s) {
+			pos++
+		}
+		// pos = a*k + b for some k >= 0
+		if c.a == 0 {
+			return pos == c.b
+		}
+		k := pos - c.b
+		return k%c.a == 0 && k/c.a >= 0
+	}
+	return false
+}
+
+// matchAttributeValue compares an attribute value with the operator of an attribute selector
+func matchAttributeValue(op string, value []byte, operand string) bool {
+	switch op {
+	case "":
+		return true
+	case "=":
+		return string(value) == operand
+	case "~=":
+		for _, w := range bytes.Fields(value) {
+			if string(w) == operand {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return string(value) == operand || bytes.HasPrefix(value, []byte(operand+"-"))
+	}
+	if operand == "" {
+		return false // the substring operators match nothing with an empty operand
+	}
+	switch op {
+	case "^=":
+		return bytes.HasPrefix(value, []byte(operand))
+	case "$=":
+		return bytes.HasSuffix(value, []byte(operand))
+	}
+	return bytes.Contains(value, []byte(operand))
+}
+
+// cssParser parses CSS selectors
+type cssParser struct {
+	s   string
+	pos int
+}
+
+func (p *cssParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n\f", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *cssParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// parseGroup parses selectors separated by commas, up to the end or a ')'
+func (p *cssParser) parseGroup() (*Selector, error) {
+	start := p.pos
+	s := &Selector{}
+	for {
+		p.skipSpace()
+		c, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		s.group = append(s.group, c)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	s.text = strings.TrimSpace(p.s[start:p.pos])
+	return s, nil
+}
+
+func (p *cssParser) parseComplex() (complexSelector, error) {
+	var c complexSelector
+	for {
+		compound, err := p.parseCompound()
+		if err != nil {
+			return c, err
+		}
+		c.compounds = append(c.compounds, compound)
+		space := p.skipSpace()
+		comb := p.peek()
+		switch {
+		case comb == '>' || comb == '+' || comb == '~':
+			p.pos++
+			p.skipSpace()
+		case comb == 0 || comb == ',' || comb == ')':
+			return c, nil
+		case space:
+			comb = ' '
+		default:
+			return c, fmt.Errorf("unexpected %q at position %v", comb, p.pos)
+		}
+		c.combinators = append(c.combinators, comb)
+	}
+}
+
+func (p *cssParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+	start := p.pos
+	if p.peek() == '*' {
+		p.pos++
+	} else if name := p.parseIdent(); name != "" {
+		c.name = name
+	}
+	for {
+		var cond cssCondition
+		var err error
+		switch p.peek() {
+		case '#':
+			p.pos++
+			cond = cssCondition{kind: '[', name: "id", op: "=", value: p.parseIdent()}
+			if cond.value == "" {
+				return c, fmt.Errorf("expected id at position %v", p.pos)
+			}
+		case '.':
+			p.pos++
+			cond = cssCondition{kind: '[', name: "class", op: "~=", value: p.parseIdent()}
+			if cond.value == "" {
+				return c, fmt.Errorf("expected class name at position %v", p.pos)
+			}
+		case '[':
+			cond, err = p.parseAttribute()
+		case ':':
+			cond, err = p.parsePseudo()
+		default:
+			if p.pos == start {
+				return c, fmt.Errorf("expected selector at position %v", p.pos)
+			}
+			return c, nil
+		}
+		if err != nil {
+			return c, err
+		}
+		c.conditions = append(c.conditions, cond)
+	}
+}
+
+// parseAttribute parses an attribute selector; expects the position to be at '['
+func (p *cssParser) parseAttribute() (cssCondition, error) {
+	p.pos++
+	p.skipSpace()
+	cond := cssCondition{kind: '[', name: p.parseIdent()}
+	if cond.name == "" {
+		return cond, fmt.Errorf("expected attribute name at position %v", p.pos)
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		for _, op := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+			if strings.HasPrefix(p.s[p.pos:], op) {
+				cond.op = op
+			}
+		}
+		if cond.op == "" {
+			return cond, fmt.Errorf("expected attribute operator at position %v", p.pos)
+		}
+		p.pos += len(cond.op)
+		p.skipSpace()
+		if q := p.peek(); q == '"' || q == '\'' {
+			end := strings.IndexByte(p.s[p.pos+1:], q)
+			if end < 0 {
+				return cond, fmt.Errorf("unterminated string at position %v", p.pos)
+			}
+			cond.value = unescapeCSS(p.s[p.pos+1 : p.pos+1+end])
+			p.pos += end + 2
+		} else if cond.value = p.parseIdent(); cond.value == "" {
+			return cond, fmt.Errorf("expected attribute value at position %v", p.pos)
+		}
+		p.skipSpace()
+	}
+	if p.peek() != ']' {
+		return cond, fmt.Errorf("expected ']' at position %v", p.pos)
+	}
+	p.pos++
+	return cond, nil
+}
+
+// parsePseudo parses a pseudo-class; expects the position to be at ':'
+func (p *cssParser) parsePseudo() (cssCondition, error) {
+	p.pos++
+	start := p.pos
+	cond := cssCondition{kind: ':', name: strings.ToLower(p.parseIdent())}
+	switch cond.name {
+	case "first-child", "last-child", "only-child", "root", "empty":
+		return cond, nil
+	case "not", "nth-child", "nth-last-child":
+	default:
+		return cond, fmt.Errorf("unsupported pseudo-class %q at position %v", cond.name, start)
+	}
+	if p.peek() != '(' {
+		return cond, fmt.Errorf("expected '(' at position %v", p.pos)
+	}
+	p.pos++
+	p.skipSpace()
+	if cond.name == "not" {
+		not, err := p.parseGroup()
+		if err != nil {
+			return cond, err
+		}
+		cond.not = not
+	} else {
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return cond, fmt.Errorf("expected ')' at position %v", p.pos)
+		}
+		var err error
+		if cond.a, cond.b, err = parseNth(p.s[p.pos : p.pos+end]); err != nil {
+			return cond, fmt.Errorf("%w at position %v", err, p.pos)
+		}
+		p.pos += end
+	}
+	p.skipSpace()
+	if p.peek() != ')' {
+		return cond, fmt.Errorf("expected ')' at position %v", p.pos)
+	}
+	p.pos++
+	return cond, nil
+}
+
+// parseNth parses the an+b argument of :nth-child, including odd and even
+func parseNth(s string) (a, b int, err error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+	n := strings.IndexByte(s, 'n')
+	if n < 0 {
+		b, err = strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid argument %q", s)
+		}
+		return 0, b, nil
+	}
+	switch coef := s[:n]; coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(coef); err != nil {
+			return 0, 0, fmt.Errorf("invalid argument %q", s)
+		}
+	}
+	if rest := s[n+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, fmt.Errorf("invalid argument %q", s)
+		}
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, fmt.Errorf("invalid argument %q", s)
+		}
+	}
+	return a, b, nil
+}
+
+// parseIdent parses an identifier, with backslash escapes, or returns an empty string
+func (p *cssParser) parseIdent() string {
+	start := p.pos
+	escaped := false
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.s):
+			_, size := utf8.DecodeRuneInString(p.s[p.pos+1:])
+			p.pos += 1 + size
+			escaped = true
+			continue
+		case c >= 0x80, c == '_', c == '-', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && p.pos > start:
+		default:
+			if escaped {
+				return unescapeCSS(p.s[start:p.pos])
+			}
+			return p.s[start:p.pos]
+		}
+		p.pos++
+	}
+	if escaped {
+		return unescapeCSS(p.s[start:p.pos])
+	}
+	return p.s[start:p.pos]
+}
+
+// unescapeCSS removes the backslashes escaping characters
+func unescapeCSS(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package runxml
+
+import (
+	"strings"
+	"testing"
+)
+
+var cssTestDoc = `<?xml version="1.0"?>
+<shop xmlns:p="urn:prices">
+	<book id="b1" class="new sale" lang="en-GB">
+		<title>Go</title>
+		<author>Ann</author>
+		<p:price>10.50</p:price>
+	</book>
+	<book id="b2" href="https://example.com/x.pdf">
+		<title>XML</title>
+		<author>Bob</author>
+		<author>Cid</author>
+		<p:price currency="EUR">7</p:price>
+	</book>
+	<magazine id="m1" class="sale"><title/></magazine>
+	<book id="b3" lang="fr"><title>Deux</title></book>
+</shop>`
+
+// cssNames returns the names of the elements selected, with the value of the id attribute
+func cssNames(nodes []*GenericNode) string {
+	var s []string
+	for _, n := range nodes {
+		name := string(n.Name)
+		for a := range n.Attributes() {
+			if string(a.Name) == "id" {
+				name += "#" + string(a.Value)
+			}
+		}
+		s = append(s, name)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestQuerySelector(t *testing.T) {
+	doc, err := NewDefaultRunXML().Parse([]byte(cssTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, _ := doc.QuerySelector("#b2")
+	for _, test := range []struct {
+		context *GenericNode
+		sel     string
+		nodes   string
+	}{
+		{doc, "book", "book#b1 book#b2 book#b3"},
+		{doc, "*", "shop book#b1 title author p:price book#b2 title author author p:price magazine#m1 title book#b3 title"},
+		{doc, "p\\:price", "p:price p:price"},
+		{doc, "[currency]", "p:price"},
+		{doc, "[lang=fr]", "book#b3"},
+		{doc, `[lang|="en"]`, "book#b1"},
+		{doc, "[class~=sale]", "book#b1 magazine#m1"},
+		{doc, ".sale.new", "book#b1"},
+		{doc, "[href^='https:']", "book#b2"},
+		{doc, "[href$=\".pdf\"]", "book#b2"},
+		{doc, "[href*=example]", "book#b2"},
+		{doc, "[href^='']", ""},
+		{doc, "[ id = b3 ]", "book#b3"},
+		{doc, "shop > book > title", "title title title"},
+		{doc, "shop title", "title title title title"},
+		{doc, "author + author", "author"},
+		{doc, "book author + p\\:price", "p:price p:price"},
+		{doc, "title ~ author", "author author author"},
+		{doc, "book + magazine", "magazine#m1"},
+		{doc, "magazine ~ *", "book#b3"},
+		{doc, "author:first-child", ""},
+		{doc, "title:first-child", "title title title title"},
+		{doc, "book:last-child", "book#b3"},
+		{doc, "title:only-child", "title title"},
+		{doc, "shop > :nth-child(2)", "book#b2"},
+		{doc, "shop > :nth-child(odd)", "book#b1 magazine#m1"},
+		{doc, "shop > :nth-child(2n)", "book#b2 book#b3"},
+		{doc, "shop > :nth-child(-n+2)", "book#b1 book#b2"},
+		{doc, "shop > :nth-child(n + 3)", "magazine#m1 book#b3"},
+		{doc, "shop > :nth-last-child(1)", "book#b3"},
+		{doc, "book:not(.sale)", "book#b2 book#b3"},
+		{doc, "book > :not(title, author)", "p:price p:price"},
+		{doc, ":root", "shop"},
+		{doc, "title:empty", "title"},
+		{doc, "magazine, book#b1, #m1", "book#b1 magazine#m1"},
+		{b2, "author", "author author"},
+		{b2, "shop author", "author author"},
+		{b2, "book", ""},
+	} {
+		nodes, err := test.context.QuerySelectorAll(test.sel)
+		if err != nil {
+			t.Error(err)
+		} else if s := cssNames(nodes); s != test.nodes {
+			t.Errorf("%v: expected %q, found %q", test.sel, test.nodes, s)
+		}
+	}
+	if n, err := doc.QuerySelector("author"); err != nil || string(n.GetFirstChild().Value) != "Ann" {
+		t.Errorf("unexpected node %v %v", n, err)
+	}
+	if n, err := doc.QuerySelector("missing"); n != nil || err != nil {
+		t.Errorf("expected no node, found %v %v", n, err)
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	for _, sel := range []string{
+		"",
+		"book >",
+		"> book",
+		"book,",
+		"[id",
+		"[id=]",
+		"[id!=b1]",
+		"[id='b1]",
+		"#",
+		".",
+		":hover",
+		":nth-child(x)",
+		":nth-child(2n1)",
+		":not(book",
+		"book)",
+	} {
+		if _, err := CompileSelector(sel); err == nil {
+			t.Errorf("%q: expected error", sel)
+		}
+	}
+}
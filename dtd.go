@@ -0,0 +1,1204 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DTD is the document type definition declared by the DOCTYPE of a document.
+// It holds the declarations of the internal subset, including those read through
+// parameter entity references.
+type DTD struct {
+	Name              string                      // Name of the document element
+	PublicID          string                      // Public identifier of the external subset
+	SystemID          string                      // System identifier of the external subset
+	Elements          map[string]*ElementDecl     // Element type declarations by element name
+	Attributes        map[string][]*AttributeDecl // Attribute-list declarations by element name, in declaration order
+	Entities          map[string]*EntityDecl      // General entity declarations by entity name
+	ParameterEntities map[string]*EntityDecl      // Parameter entity declarations by entity name
+	Notations         map[string]*NotationDecl    // Notation declarations by notation name
+
+	incomplete   bool     // A parameter entity was not read; later declarations are not processed
+	peReferenced bool     // A parameter entity reference occurred
+	open         []string // Parameter entities being expanded, used to detect recursion
+}
+
+// ContentType is the kind of content an element type declaration allows
+type ContentType int
+
+// ContentType enum values
+const (
+	ContentEmpty    ContentType = iota // EMPTY
+	ContentAny                         // ANY
+	ContentMixed                       // Character data, optionally mixed with the listed elements
+	ContentChildren                    // Child elements only, as described by the content model
+)
+
+// ElementDecl is an element type declaration, <!ELEMENT name content>
+type ElementDecl struct {
+	Name        string           // Element name
+	ContentType ContentType      // Kind of content
+	Content     *ContentParticle // Content model; a choice starting with #PCDATA for mixed content, nil for EMPTY and ANY
+}
+
+// ParticleType is the kind of a content particle
+type ParticleType int
+
+// ParticleType enum values
+const (
+	ParticleName   ParticleType = iota // An element name
+	ParticleSeq                        // A sequence (a,b,c)
+	ParticleChoice                     // A choice (a|b|c)
+)
+
+// ContentParticle is a node of the content model of an element type declaration
+type ContentParticle struct {
+	Type       ParticleType       // Name, sequence or choice
+	Name       string             // Element name of a ParticleName
+	Children   []*ContentParticle // Members of a sequence or choice
+	Occurrence byte               // One of '?', '*', '+', or 0 when the particle occurs exactly once
+}
+
+// String returns the content particle in DTD syntax, e.g. (a,(b|c)*)
+func (c *ContentParticle) String() string {
+	var sb strings.Builder
+	c.writeTo(&sb)
+	return sb.String()
+}
+
+func (c *ContentParticle) writeTo(sb *strings.Builder) {
+	if c.Type == ParticleName {
+		sb.WriteString(c.Name)
+	} else {
+		sep := ","
+		if c.Type == ParticleChoice {
+			sep = "|"
+		}
+		sb.WriteByte('(')
+		for i, child := range c.Children {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			child.writeTo(sb)
+		}
+		sb.WriteByte(')')
+	}
+	if c.Occurrence != 0 {
+		sb.WriteByte(c.Occurrence)
+	}
+}
+
+// AttributeType is the declared type of an attribute
+type AttributeType int
+
+// AttributeType enum values
+const (
+	AttrCDATA       AttributeType = iota // CDATA
+	AttrID                               // ID
+	AttrIDREF                            // IDREF
+	AttrIDREFS                           // IDREFS
+	AttrEntity                           // ENTITY
+	AttrEntities                         // ENTITIES
+	AttrNmtoken                          // NMTOKEN
+	AttrNmtokens                         // NMTOKENS
+	AttrNotation                         // NOTATION (a|b|c)
+	AttrEnumeration                      // (a|b|c)
+)
+
+// Keywords of the attribute types, indexed by AttributeType
+var attributeTypeNames = [...]string{"CDATA", "ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES",
+	"NMTOKEN", "NMTOKENS", "NOTATION", ""}
+
+// String returns the keyword of the attribute type
+func (t AttributeType) String() string {
+	if t < 0 || int(t) >= len(attributeTypeNames) {
+		return fmt.Sprintf("AttributeType(%d)", t)
+	}
+	if t == AttrEnumeration {
+		return "Enumeration"
+	}
+	return attributeTypeNames[t]
+}
+
+// DefaultType tells how the default of an attribute is declared
+type DefaultType int
+
+// DefaultType enum values
+const (
+	DefaultImplied  DefaultType = iota // #IMPLIED
+	DefaultRequired                    // #REQUIRED
+	DefaultFixed                       // #FIXED "value"
+	DefaultValue                       // "value"
+)
+
+// AttributeDecl is the declaration of an attribute in an attribute-list
+// declaration, <!ATTLIST element name type default>
+type AttributeDecl struct {
+	Element     string        // Name of the element the attribute belongs to
+	Name        string        // Attribute name
+	Type        AttributeType // Declared type
+	Enumeration []string      // Allowed values of AttrNotation and AttrEnumeration types
+	DefaultType DefaultType   // How the default is declared
+	Default     string        // Default value of DefaultFixed and DefaultValue, with references expanded
+
+	name  []byte // Name and Default as used for the nodes of defaulted attributes
+	value []byte
+}
+
+// EntityDecl is a general or parameter entity declaration, <!ENTITY name value>
+// or <!ENTITY % name value>
+type EntityDecl struct {
+	Name      string // Entity name
+	Parameter bool   // Parameter entity
+	Value     string // Replacement text of an internal entity
+	PublicID  string // Public identifier of an external entity
+	SystemID  string // System identifier of an external entity
+	Notation  string // Notation of an unparsed entity
+	value     []byte // Replacement text
+	markup    int8   // Whether the replacement text contains markup; see entityHasMarkup
+	base      string // Base URI of the declaration, for resolving SystemID
+	loaded    bool   // The replacement text of the external entity has been read
+	extDecl   bool   // Declared in the external subset or a parameter entity
+}
+
+// IsExternal reports whether the entity is an external entity
+func (e *EntityDecl) IsExternal() bool {
+	return e.SystemID != ""
+}
+
+// NotationDecl is a notation declaration, <!NOTATION name id>
+type NotationDecl struct {
+	Name     string // Notation name
+	PublicID string // Public identifier
+	SystemID string // System identifier
+}
+
+// DTD returns the document type definition of the document containing the node,
+// or nil if the document has no DOCTYPE
+func (g *GenericNode) DTD() *DTD {
+	for g.Parent != nil {
+		g = g.Parent
+	}
+	return g.dtd
+}
+
+func newDTD() *DTD {
+	return &DTD{
+		Elements:          make(map[string]*ElementDecl),
+		Attributes:        make(map[string][]*AttributeDecl),
+		Entities:          make(map[string]*EntityDecl),
+		ParameterEntities: make(map[string]*EntityDecl),
+		Notations:         make(map[string]*NotationDecl),
+	}
+}
+
+// Contexts in which markup declarations are parsed by parseDeclarations
+const (
+	internalSubset = iota // The internal subset; ends at ']'
+	externalSubset        // The external subset or the text of a parameter entity; ends at end of data
+	includeSection        // An INCLUDE conditional section; ends at "]]>"
+)
+
+// parseDocTypeDecl parses the document type declaration; expects position to be after
+// "<!DOCTYPE" and leaves it at the closing '>'
+func (r *RunXML) parseDocTypeDecl() (*DTD, error) {
+	dtd := newDTD()
+	r.dtd = dtd // attribute defaults may reference the entities declared before them
+	r.dtdSpace()
+	name, err := r.dtdName()
+	if err != nil {
+		return nil, err
+	}
+	dtd.Name = string(name)
+	if r.dtdSpace() > 0 {
+		if dtd.PublicID, dtd.SystemID, _, err = r.parseExternalID(true); err != nil {
+			return nil, err
+		}
+		r.dtdSpace()
+	}
+	if r.dtdPeek() == '[' {
+		r.position++
+		if err := r.parseDeclarations(dtd, internalSubset); err != nil {
+			return nil, err
+		}
+		r.position++ // skip ']'
+		r.dtdSpace()
+	}
+	if r.dtdPeek() != '>' {
+		return nil, fmt.Errorf("expected '>' at end of DOCTYPE at position %v", r.position)
+	}
+	if dtd.SystemID != "" {
+		if err := r.parseExternalSubset(dtd); err != nil {
+			return nil, err
+		}
+	}
+	return dtd, nil
+}
+
+// parseExternalSubset reads the external subset through the entity resolver and parses
+// its declarations. The DTD is incomplete if the subset is not available.
+func (r *RunXML) parseExternalSubset(dtd *DTD) error {
+	text, err := r.readExternal(dtd.PublicID, dtd.SystemID, r.baseURI)
+	if entityUnavailable(err) {
+		dtd.incomplete = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, pos, base := r.data, r.position, r.baseURI
+	r.data, r.position, r.baseURI = text, 0, resolveURI(base, dtd.SystemID)
+	err = r.parseDeclarations(dtd, externalSubset)
+	r.data, r.position, r.baseURI = data, pos, base
+	if err != nil {
+		return fmt.Errorf("in external subset %q: %w", dtd.SystemID, err)
+	}
+	return nil
+}
+
+// parseDeclarations parses markup declarations, comments, PIs and parameter entity
+// references until the end of the given context
+func (r *RunXML) parseDeclarations(dtd *DTD, context int) error {
+	for {
+		r.dtdSpace()
+		if r.position >= len(r.data) {
+			if context != externalSubset {
+				return fmt.Errorf("unexpected end of file in document type declaration")
+			}
+			return nil
+		}
+		var err error
+		switch c := r.data[r.position]; {
+		case c == ']' && context == internalSubset:
+			return nil
+		case context == includeSection && r.dtdKeyword("]]>"):
+			return nil
+		case c == '%':
+			err = r.parsePEReference(dtd)
+		case r.dtdKeyword("<!--"):
+			err = r.skipDTDComment()
+		case r.dtdKeyword("<?"):
+			err = r.skipDTDPI()
+		case context != internalSubset && r.dtdKeyword("<!["):
+			err = r.parseConditionalSection(dtd)
+		case c == '<' && context != internalSubset:
+			err = r.parseExpandedMarkupDecl(dtd, context)
+		default:
+			err = r.parseMarkupDecl(dtd, context)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseExpandedMarkupDecl parses a markup declaration that may contain parameter
+// entity references, as allowed outside the internal subset
+func (r *RunXML) parseExpandedMarkupDecl(dtd *DTD, context int) error {
+	end, decl, err := r.expandDeclPEReferences(dtd)
+	switch {
+	case err != nil:
+		return err
+	case decl == nil: // no references
+		return r.parseMarkupDecl(dtd, context)
+	case len(decl) == 0: // skipped, as the references can not be read
+		r.position = end
+		return nil
+	}
+	data := r.data
+	r.data, r.position = decl, 0
+	err = r.parseMarkupDecl(dtd, context)
+	if err == nil {
+		r.dtdSpace()
+		if r.position != len(r.data) {
+			err = fmt.Errorf("parameter entity text is not properly nested with markup declaration")
+		}
+	}
+	r.data, r.position = data, end
+	return err
+}
+
+// parseMarkupDecl parses an element type, attribute-list, entity or notation declaration
+func (r *RunXML) parseMarkupDecl(dtd *DTD, context int) error {
+	switch {
+	case r.dtdKeyword("<!ELEMENT"):
+		return r.parseElementDecl(dtd)
+	case r.dtdKeyword("<!ATTLIST"):
+		return r.parseAttlistDecl(dtd)
+	case r.dtdKeyword("<!ENTITY"):
+		return r.parseEntityDecl(dtd, context)
+	case r.dtdKeyword("<!NOTATION"):
+		return r.parseNotationDecl(dtd)
+	}
+	return fmt.Errorf("unexpected %q in document type declaration at position %v", r.dtdPeek(), r.position)
+}
+
+// parseElementDecl parses <!ELEMENT name contentspec>; expects position to be after "<!ELEMENT"
+func (r *RunXML) parseElementDecl(dtd *DTD) error {
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after <!ELEMENT at position %v", r.position)
+	}
+	name, err := r.dtdName()
+	if err != nil {
+		return err
+	}
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after element name at position %v", r.position)
+	}
+	decl := &ElementDecl{Name: string(name)}
+	switch {
+	case r.dtdKeyword("EMPTY"):
+		decl.ContentType = ContentEmpty
+	case r.dtdKeyword("ANY"):
+		decl.ContentType = ContentAny
+	case r.dtdPeek() == '(':
+		r.position++
+		r.dtdSpace()
+		if r.dtdKeyword("#PCDATA") {
+			decl.ContentType = ContentMixed
+			decl.Content, err = r.parseMixedContent()
+		} else {
+			decl.ContentType = ContentChildren
+			decl.Content, err = r.parseContentGroup()
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expected content specification at position %v", r.position)
+	}
+	if err := r.endDecl(); err != nil {
+		return err
+	}
+	if _, ok := dtd.Elements[decl.Name]; !ok {
+		dtd.Elements[decl.Name] = decl
+	}
+	return nil
+}
+
+// parseMixedContent parses the rest of a mixed content declaration; expects position
+// to be after "(#PCDATA"
+func (r *RunXML) parseMixedContent() (*ContentParticle, error) {
+	choice := &ContentParticle{Type: ParticleChoice}
+	choice.Children = append(choice.Children, &ContentParticle{Type: ParticleName, Name: "#PCDATA"})
+	for {
+		r.dtdSpace()
+		switch r.dtdPeek() {
+		case '|':
+			r.position++
+			r.dtdSpace()
+			name, err := r.dtdName()
+			if err != nil {
+				return nil, err
+			}
+			choice.Children = append(choice.Children, &ContentParticle{Type: ParticleName, Name: string(name)})
+		case ')':
+			r.position++
+			if r.dtdPeek() == '*' {
+				r.position++
+				choice.Occurrence = '*'
+			} else if len(choice.Children) > 1 {
+				return nil, fmt.Errorf("expected '*' after mixed content with element names at position %v", r.position)
+			}
+			return choice, nil
+		default:
+			return nil, fmt.Errorf("expected '|' or ')' in mixed content declaration at position %v", r.position)
+		}
+	}
+}
+
+// parseContentGroup parses a sequence or choice; expects position to be after the '('
+func (r *RunXML) parseContentGroup() (*ContentParticle, error) {
+	group := &ContentParticle{Type: ParticleSeq}
+	var sep byte
+	for {
+		r.dtdSpace()
+		var cp *ContentParticle
+		if r.dtdPeek() == '(' {
+			r.position++
+			var err error
+			if cp, err = r.parseContentGroup(); err != nil {
+				return nil, err
+			}
+		} else {
+			name, err := r.dtdName()
+			if err != nil {
+				return nil, err
+			}
+			cp = &ContentParticle{Type: ParticleName, Name: string(name), Occurrence: r.parseOccurrence()}
+		}
+		group.Children = append(group.Children, cp)
+		r.dtdSpace()
+		switch c := r.dtdPeek(); {
+		case c == ')':
+			r.position++
+			group.Occurrence = r.parseOccurrence()
+			return group, nil
+		case (c == ',' || c == '|') && (sep == 0 || sep == c):
+			if c == '|' {
+				group.Type = ParticleChoice
+			}
+			sep = c
+			r.position++
+		default:
+			return nil, fmt.Errorf("expected ',', '|' or ')' in content model at position %v", r.position)
+		}
+	}
+}
+
+// parseOccurrence parses an optional occurrence indicator
+func (r *RunXML) parseOccurrence() byte {
+	switch c := r.dtdPeek(); c {
+	case '?', '*', '+':
+		r.position++
+		return c
+	}
+	return 0
+}
+
+// parseAttlistDecl parses <!ATTLIST element attdefs>; expects position to be after "<!ATTLIST"
+func (r *RunXML) parseAttlistDecl(dtd *DTD) error {
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after <!ATTLIST at position %v", r.position)
+	}
+	element, err := r.dtdName()
+	if err != nil {
+		return err
+	}
+	elementName := string(element)
+	for {
+		ws := r.dtdSpace()
+		if r.dtdPeek() == '>' {
+			r.position++
+			return nil
+		}
+		if ws == 0 {
+			return fmt.Errorf("expected whitespace before attribute definition at position %v", r.position)
+		}
+		name, err := r.dtdName()
+		if err != nil {
+			return err
+		}
+		decl := &AttributeDecl{Element: elementName, Name: string(name)}
+		if r.dtdSpace() == 0 {
+			return fmt.Errorf("expected whitespace after attribute name at position %v", r.position)
+		}
+		if err := r.parseAttributeType(decl); err != nil {
+			return err
+		}
+		if r.dtdSpace() == 0 {
+			return fmt.Errorf("expected whitespace after attribute type at position %v", r.position)
+		}
+		if err := r.parseDefaultDecl(decl); err != nil {
+			return err
+		}
+		if dtd.incomplete {
+			continue // declarations after an unread parameter entity are not processed
+		}
+		decls := dtd.Attributes[elementName]
+		declared := false
+		for _, d := range decls {
+			declared = declared || d.Name == decl.Name
+		}
+		if !declared { // the first declaration is binding
+			dtd.Attributes[elementName] = append(decls, decl)
+		}
+	}
+}
+
+// parseAttributeType parses the type of an attribute definition
+func (r *RunXML) parseAttributeType(decl *AttributeDecl) error {
+	if r.dtdPeek() == '(' {
+		decl.Type = AttrEnumeration
+		var err error
+		decl.Enumeration, err = r.parseEnumeration(false)
+		return err
+	}
+	for t, kw := range attributeTypeNames[:AttrEnumeration] {
+		if bytes.HasPrefix(r.sliceToEnd(), []byte(kw)) &&
+			r.position+len(kw) < len(r.data) && lookupWhitespace[r.data[r.position+len(kw)]] == 1 {
+			r.position += len(kw)
+			decl.Type = AttributeType(t)
+			if decl.Type == AttrNotation {
+				r.dtdSpace()
+				var err error
+				decl.Enumeration, err = r.parseEnumeration(true)
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown attribute type at position %v", r.position)
+}
+
+// parseEnumeration parses (a|b|c); names are Names in a notation type and Nmtokens otherwise
+func (r *RunXML) parseEnumeration(names bool) ([]string, error) {
+	if r.dtdPeek() != '(' {
+		return nil, fmt.Errorf("expected '(' at position %v", r.position)
+	}
+	r.position++
+	var values []string
+	for {
+		r.dtdSpace()
+		var value []byte
+		var err error
+		if names {
+			value, err = r.dtdName()
+		} else {
+			value, err = r.dtdNmtoken()
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(value))
+		r.dtdSpace()
+		switch r.dtdPeek() {
+		case '|':
+			r.position++
+		case ')':
+			r.position++
+			return values, nil
+		default:
+			return nil, fmt.Errorf("expected '|' or ')' in enumeration at position %v", r.position)
+		}
+	}
+}
+
+// parseDefaultDecl parses #REQUIRED, #IMPLIED, or an optionally #FIXED default value
+func (r *RunXML) parseDefaultDecl(decl *AttributeDecl) error {
+	switch {
+	case r.dtdKeyword("#REQUIRED"):
+		decl.DefaultType = DefaultRequired
+		return nil
+	case r.dtdKeyword("#IMPLIED"):
+		decl.DefaultType = DefaultImplied
+		return nil
+	case r.dtdKeyword("#FIXED"):
+		decl.DefaultType = DefaultFixed
+		if r.dtdSpace() == 0 {
+			return fmt.Errorf("expected whitespace after #FIXED at position %v", r.position)
+		}
+	default:
+		decl.DefaultType = DefaultValue
+	}
+	q := r.dtdPeek()
+	lit, err := r.dtdLiteral()
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(lit, '<') >= 0 {
+		return fmt.Errorf("'<' not allowed in default value of attribute %q", decl.Name)
+	}
+	value, err := r.expandAttributeLiteral(lit, q)
+	if err != nil {
+		return fmt.Errorf("error in default value of attribute %q: %v", decl.Name, err)
+	}
+	if decl.Type != AttrCDATA {
+		value = normalizeTokens(value)
+	}
+	decl.Default = string(value)
+	decl.name = []byte(decl.Name)
+	decl.value = value
+	return nil
+}
+
+// expandAttributeLiteral expands references in a copy of an attribute value literal
+// quoted by q, the same way as in attribute values of elements
+func (r *RunXML) expandAttributeLiteral(lit []byte, q byte) ([]byte, error) {
+	buf := make([]byte, len(lit)+1)
+	copy(buf, lit)
+	buf[len(lit)] = q // the end quote stops the expansion
+	data, pos := r.data, r.position
+	r.data, r.position = buf, 0
+	var value []byte
+	var err error
+	if q == '\'' {
+		value, err = r.skipAndExpandCharacterRefs(lookupAttributeDataSQ, lookupAttributeDataSQPure)
+	} else {
+		value, err = r.skipAndExpandCharacterRefs(lookupAttributeDataDQ, lookupAttributeDataDQPure)
+	}
+	r.data, r.position = data, pos
+	return value, err
+}
+
+// parseEntityDecl parses <!ENTITY name def> and <!ENTITY % name def>;
+// expects position to be after "<!ENTITY"
+func (r *RunXML) parseEntityDecl(dtd *DTD, context int) error {
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after <!ENTITY at position %v", r.position)
+	}
+	decl := &EntityDecl{base: r.baseURI, extDecl: context != internalSubset}
+	if r.dtdPeek() == '%' {
+		r.position++
+		if r.dtdSpace() == 0 {
+			return fmt.Errorf("expected whitespace after '%%' at position %v", r.position)
+		}
+		decl.Parameter = true
+	}
+	name, err := r.dtdName()
+	if err != nil {
+		return err
+	}
+	if err := r.checkNCName(name); err != nil {
+		return err
+	}
+	decl.Name = string(name)
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after entity name at position %v", r.position)
+	}
+	if q := r.dtdPeek(); q == '"' || q == '\'' {
+		lit, err := r.dtdLiteral()
+		if err != nil {
+			return err
+		}
+		value, err := r.expandEntityValue(dtd, lit, context)
+		if err != nil {
+			return fmt.Errorf("error in value of entity %q: %v", decl.Name, err)
+		}
+		decl.Value = string(value)
+		decl.value = value
+	} else {
+		var found bool
+		if decl.PublicID, decl.SystemID, found, err = r.parseExternalID(true); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("expected entity value or external identifier at position %v", r.position)
+		}
+		if !decl.Parameter && r.dtdSpace() > 0 && r.dtdKeyword("NDATA") {
+			if r.dtdSpace() == 0 {
+				return fmt.Errorf("expected whitespace after NDATA at position %v", r.position)
+			}
+			notation, err := r.dtdName()
+			if err != nil {
+				return err
+			}
+			decl.Notation = string(notation)
+		}
+	}
+	if err := r.endDecl(); err != nil {
+		return err
+	}
+	if dtd.incomplete {
+		return nil // declarations after an unread parameter entity are not processed
+	}
+	entities := dtd.Entities
+	if decl.Parameter {
+		entities = dtd.ParameterEntities
+	}
+	if _, ok := entities[decl.Name]; !ok { // the first declaration is binding
+		entities[decl.Name] = decl
+	}
+	return nil
+}
+
+// expandEntityValue returns the replacement text of an entity value literal; parameter
+// entity and character references are replaced, while general entity references are
+// kept to be expanded where the entity is referenced
+func (r *RunXML) expandEntityValue(dtd *DTD, lit []byte, context int) ([]byte, error) {
+	value := make([]byte, 0, len(lit))
+	for i := 0; i < len(lit); {
+		switch c := lit[i]; c {
+		case '%':
+			if context == internalSubset {
+				return nil, fmt.Errorf("parameter entity reference in entity value in the internal subset")
+			}
+			name, n, err := refName(lit[i:])
+			if err != nil {
+				return nil, err
+			}
+			pe, err := r.openParameterEntity(dtd, string(name))
+			if err != nil {
+				return nil, err
+			}
+			if pe != nil {
+				value = append(value, pe.value...)
+				r.closeParameterEntity(dtd)
+			}
+			i += n
+		case '&':
+			if i+1 < len(lit) && lit[i+1] == '#' {
+				cp, n, err := r.decodeCharacterRef(lit[i:])
+				if err != nil {
+					return nil, err
+				}
+				value = utf8.AppendRune(value, cp)
+				i += n
+				continue
+			}
+			_, n, err := refName(lit[i:])
+			if err != nil {
+				return nil, err
+			}
+			value = append(value, lit[i:i+n]...) // bypassed
+			i += n
+		default:
+			value = append(value, c)
+			i++
+		}
+	}
+	return value, nil
+}
+
+// parseNotationDecl parses <!NOTATION name id>; expects position to be after "<!NOTATION"
+func (r *RunXML) parseNotationDecl(dtd *DTD) error {
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after <!NOTATION at position %v", r.position)
+	}
+	name, err := r.dtdName()
+	if err != nil {
+		return err
+	}
+	if err := r.checkNCName(name); err != nil {
+		return err
+	}
+	if r.dtdSpace() == 0 {
+		return fmt.Errorf("expected whitespace after notation name at position %v", r.position)
+	}
+	decl := &NotationDecl{Name: string(name)}
+	var found bool
+	if decl.PublicID, decl.SystemID, found, err = r.parseExternalID(false); err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("expected external or public identifier at position %v", r.position)
+	}
+	if err := r.endDecl(); err != nil {
+		return err
+	}
+	if _, ok := dtd.Notations[decl.Name]; !ok {
+		dtd.Notations[decl.Name] = decl
+	}
+	return nil
+}
+
+// parseExternalID parses SYSTEM "sys" or PUBLIC "pub" "sys". The system literal of a
+// public identifier is optional unless systemRequired is set. Returns found as false if
+// there is no identifier at the current position.
+func (r *RunXML) parseExternalID(systemRequired bool) (publicID, systemID string, found bool, err error) {
+	switch {
+	case r.dtdKeyword("SYSTEM"):
+		if r.dtdSpace() == 0 {
+			return "", "", false, fmt.Errorf("expected whitespace after SYSTEM at position %v", r.position)
+		}
+		sys, err := r.dtdLiteral()
+		return "", string(sys), true, err
+	case r.dtdKeyword("PUBLIC"):
+		if r.dtdSpace() == 0 {
+			return "", "", false, fmt.Errorf("expected whitespace after PUBLIC at position %v", r.position)
+		}
+		pub, err := r.dtdLiteral()
+		if err != nil {
+			return "", "", false, err
+		}
+		for _, c := range pub {
+			if !isPubidChar(c) {
+				return "", "", false, fmt.Errorf("illegal character %q in public identifier", c)
+			}
+		}
+		ws := r.dtdSpace()
+		if q := r.dtdPeek(); ws == 0 || q != '"' && q != '\'' {
+			if systemRequired {
+				return "", "", false, fmt.Errorf("expected system literal at position %v", r.position)
+			}
+			return string(pub), "", true, nil
+		}
+		sys, err := r.dtdLiteral()
+		return string(pub), string(sys), true, err
+	}
+	return "", "", false, nil
+}
+
+// parsePEReference includes the replacement text of a parameter entity referenced between
+// declarations; expects position to be at the '%'
+func (r *RunXML) parsePEReference(dtd *DTD) error {
+	name, n, err := refName(r.sliceToEnd())
+	if err != nil {
+		return fmt.Errorf("%v at position %v", err, r.position)
+	}
+	r.position += n
+	pe, err := r.openParameterEntity(dtd, string(name))
+	if pe == nil || err != nil {
+		return err
+	}
+	defer r.closeParameterEntity(dtd)
+	data, pos, base := r.data, r.position, r.baseURI
+	r.data, r.position = pe.value, 0
+	if pe.IsExternal() {
+		r.baseURI = resolveURI(pe.base, pe.SystemID)
+	}
+	err = r.parseDeclarations(dtd, externalSubset)
+	r.data, r.position, r.baseURI = data, pos, base
+	return err
+}
+
+// openParameterEntity looks up a referenced parameter entity and marks it as being
+// expanded. Returns nil if the entity can not be read, in which case the DTD is
+// incomplete. The caller must call closeParameterEntity when done with a non-nil entity.
+func (r *RunXML) openParameterEntity(dtd *DTD, name string) (*EntityDecl, error) {
+	dtd.peReferenced = true
+	pe := dtd.ParameterEntities[name]
+	if pe == nil {
+		if r.standalone {
+			return nil, fmt.Errorf("reference to undeclared parameter entity %q", name)
+		}
+		dtd.incomplete = true // not an error in a document with parameter entity references
+		return nil, nil
+	}
+	if pe.IsExternal() {
+		ok, err := r.loadEntity(pe)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			dtd.incomplete = true // access refused
+			return nil, nil
+		}
+	}
+	for _, open := range dtd.open {
+		if open == name {
+			return nil, fmt.Errorf("recursive reference to parameter entity %q", name)
+		}
+	}
+	dtd.open = append(dtd.open, name)
+	return pe, nil
+}
+
+// closeParameterEntity marks the last opened parameter entity as done
+func (r *RunXML) closeParameterEntity(dtd *DTD) {
+	dtd.open = dtd.open[:len(dtd.open)-1]
+}
+
+// expandDeclPEReferences returns a copy of the markup declaration at the current position
+// with the parameter entity references outside literals replaced, together with the
+// position after the declaration. Returns a nil declaration if there are no references.
+func (r *RunXML) expandDeclPEReferences(dtd *DTD) (int, []byte, error) {
+	decl := r.sliceToEnd()
+	if end := declEnd(decl); end >= 0 {
+		decl = decl[:end+1]
+	}
+	if !hasPEReference(decl) {
+		return 0, nil, nil
+	}
+	end := r.position + len(decl)
+	// replacement text may contain references itself, so repeat until there are none
+	for depth := 0; hasPEReference(decl); depth++ {
+		if depth > maxPEDepth {
+			return 0, nil, fmt.Errorf("parameter entities nested too deep in declaration at position %v", r.position)
+		}
+		var expanded []byte
+		var quote byte
+		for i := 0; i < len(decl); i++ {
+			c := decl[i]
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '%' && i+1 < len(decl) && lookupEntityName[decl[i+1]] == 1:
+				name, n, err := refName(decl[i:])
+				if err != nil {
+					return 0, nil, err
+				}
+				pe, err := r.openParameterEntity(dtd, string(name))
+				if err != nil {
+					return 0, nil, err
+				}
+				if pe == nil {
+					// the declaration can not be interpreted without the entity
+					return end, []byte{}, nil
+				}
+				r.closeParameterEntity(dtd)
+				expanded = append(expanded, ' ')
+				expanded = append(expanded, pe.value...)
+				expanded = append(expanded, ' ')
+				i += n - 1
+				continue
+			}
+			expanded = append(expanded, c)
+		}
+		decl = expanded
+	}
+	return end, decl, nil
+}
+
+// Maximum nesting of parameter entity references inside a declaration
+const maxPEDepth = 32
+
+// declEnd returns the index of the '>' closing the markup declaration at the start of b, or -1
+func declEnd(b []byte) int {
+	var quote byte
+	for i, c := range b {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// hasPEReference reports whether there is a parameter entity reference outside literals in decl
+func hasPEReference(decl []byte) bool {
+	var quote byte
+	for i, c := range decl {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '%' && i+1 < len(decl) && lookupEntityName[decl[i+1]] == 1:
+			return true
+		}
+	}
+	return false
+}
+
+// parseConditionalSection parses <![INCLUDE[...]]> and <![IGNORE[...]]>; expects
+// position to be after "<!["
+func (r *RunXML) parseConditionalSection(dtd *DTD) error {
+	r.dtdSpace()
+	var keyword string
+	if r.dtdPeek() == '%' {
+		name, n, err := refName(r.sliceToEnd())
+		if err != nil {
+			return fmt.Errorf("%v at position %v", err, r.position)
+		}
+		r.position += n
+		pe, err := r.openParameterEntity(dtd, string(name))
+		if err != nil {
+			return err
+		}
+		if pe == nil {
+			return fmt.Errorf("conditional section keyword %q can not be read", name)
+		}
+		r.closeParameterEntity(dtd)
+		keyword = string(bytes.TrimSpace(pe.value))
+	} else {
+		start := r.position
+		for r.position < len(r.data) && lookupDeclName[r.data[r.position]] == 1 {
+			r.position++
+		}
+		keyword = string(r.sliceFrom(start))
+	}
+	r.dtdSpace()
+	if r.dtdPeek() != '[' {
+		return fmt.Errorf("expected '[' in conditional section at position %v", r.position)
+	}
+	r.position++
+	switch keyword {
+	case "INCLUDE":
+		return r.parseDeclarations(dtd, includeSection)
+	case "IGNORE":
+		for depth := 1; depth > 0; {
+			switch {
+			case r.position >= len(r.data):
+				return fmt.Errorf("unexpected end of file in IGNORE section")
+			case r.dtdKeyword("<!["):
+				depth++
+			case r.dtdKeyword("]]>"):
+				depth--
+			default:
+				r.position++
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("expected INCLUDE or IGNORE in conditional section, found %q", keyword)
+}
+
+// skipDTDComment skips a comment; expects position to be after "<!--"
+func (r *RunXML) skipDTDComment() error {
+	if err := r.skipToChars([]byte("--")); err != nil {
+		return err
+	}
+	if !r.dtdKeyword("-->") {
+		return fmt.Errorf("invalid '--' inside comment at position %v", r.position)
+	}
+	return nil
+}
+
+// skipDTDPI skips a processing instruction; expects position to be after "<?"
+func (r *RunXML) skipDTDPI() error {
+	start := r.position
+	r.skip(lookupNodeName)
+	if target := r.sliceFrom(start); len(target) == 0 || bytes.EqualFold(target, []byte("xml")) {
+		return fmt.Errorf("invalid PI target at position %v", start)
+	}
+	if err := r.skipToChars([]byte("?>")); err != nil {
+		return err
+	}
+	r.position += 2
+	return nil
+}
+
+// endDecl skips optional whitespace and the '>' ending a markup declaration
+func (r *RunXML) endDecl() error {
+	r.dtdSpace()
+	if r.dtdPeek() != '>' {
+		return fmt.Errorf("expected '>' at end of declaration at position %v", r.position)
+	}
+	r.position++
+	return nil
+}
+
+// dtdSpace skips whitespace and returns the number of bytes skipped
+func (r *RunXML) dtdSpace() int {
+	start := r.position
+	for r.position < len(r.data) && lookupWhitespace[r.data[r.position]] == 1 {
+		r.position++
+	}
+	return r.position - start
+}
+
+// dtdPeek returns the current byte, or 0 at end of data
+func (r *RunXML) dtdPeek() byte {
+	if r.position < len(r.data) {
+		return r.data[r.position]
+	}
+	return 0
+}
+
+// dtdKeyword skips kw if the data at the current position starts with it
+func (r *RunXML) dtdKeyword(kw string) bool {
+	if bytes.HasPrefix(r.sliceToEnd(), []byte(kw)) {
+		r.position += len(kw)
+		return true
+	}
+	return false
+}
+
+// dtdName returns the name at the current position
+func (r *RunXML) dtdName() ([]byte, error) {
+	name, err := r.dtdNmtoken()
+	if err == nil && lookupNameStartExclude[name[0]] == 1 {
+		return nil, fmt.Errorf("invalid name %q at position %v", name, r.position-len(name))
+	}
+	return name, err
+}
+
+// dtdNmtoken returns the name token at the current position
+func (r *RunXML) dtdNmtoken() ([]byte, error) {
+	start := r.position
+	for r.position < len(r.data) && lookupDeclName[r.data[r.position]] == 1 {
+		r.position++
+	}
+	if start == r.position {
+		return nil, fmt.Errorf("expected name at position %v", r.position)
+	}
+	return r.sliceFrom(start), nil
+}
+
+// dtdLiteral returns the contents of the quoted literal at the current position
+func (r *RunXML) dtdLiteral() ([]byte, error) {
+	q := r.dtdPeek()
+	if q != '"' && q != '\'' {
+		return nil, fmt.Errorf("expected quoted literal at position %v", r.position)
+	}
+	end := bytes.IndexByte(r.data[r.position+1:], q)
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated literal at position %v", r.position)
+	}
+	lit := r.data[r.position+1 : r.position+1+end]
+	r.position += end + 2
+	return lit, nil
+}
+
+// refName returns the name of the entity reference (&name; or %name;) at the start of b,
+// and the length of the reference
+func refName(b []byte) ([]byte, int, error) {
+	i := 1
+	for i < len(b) && lookupEntityName[b[i]] == 1 {
+		i++
+	}
+	name := b[1:i]
+	if len(name) == 0 || lookupNameStartExclude[name[0]] == 1 {
+		return nil, 0, fmt.Errorf("expected entity name after %q", b[0])
+	}
+	if i >= len(b) || b[i] != ';' {
+		return nil, 0, fmt.Errorf("reference to entity %q is not terminated by ';'", name)
+	}
+	return name, i + 1, nil
+}
+
+// isPubidChar reports whether c is allowed in a public identifier
+func isPubidChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte(" \r\n-'()+,./:=?;!*#@$_%", c) >= 0
+}
+
+// defaultAttributes appends the declared attributes with a default value that are not
+// specified on the element, and normalizes the values of attributes with a tokenized type
+func (r *RunXML) defaultAttributes(element *GenericNode) {
+	for _, decl := range r.dtd.Attributes[string(element.Name)] {
+		var specified *AttributeNode
+		for a := element.firstAttribute; a != nil && specified == nil; a = a.next {
+			if string(a.Name) == decl.Name {
+				specified = a
+			}
+		}
+		if specified != nil && decl.Type != AttrCDATA {
+			specified.Value = normalizeTokens(specified.Value)
+		}
+		if specified == nil && (decl.DefaultType == DefaultFixed || decl.DefaultType == DefaultValue) {
+			a := r.newAttribute()
+			a.Name = decl.name
+			a.Value = decl.value
+			element.AppendAttribute(a)
+		}
+	}
+}
+
+// normalizeTokens normalizes the value of an attribute with a tokenized type in place,
+// removing leading and trailing spaces and collapsing sequences of spaces. The parser has
+// normalized literal whitespace to spaces; whitespace from character references remains.
+func normalizeTokens(v []byte) []byte {
+	n := 0
+	for _, c := range v {
+		if c == ' ' {
+			if n > 0 && v[n-1] != ' ' {
+				v[n] = ' '
+				n++
+			}
+			continue
+		}
+		v[n] = c
+		n++
+	}
+	if n > 0 && v[n-1] == ' ' {
+		n--
+	}
+	return v[:n]
+}
+
+// Name in declarations (anything but space \n \r \t ! " # % & ' ( ) * + , / ; < = > ? [ ] | \0)
+var lookupDeclName = &[256]byte{
+	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, // 0
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
+	0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, // 2
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 0, 1, 1, // 5
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, // 7
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 8
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 9
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // A
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // B
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // C
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // D
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // E
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
+}
@@ -0,0 +1,196 @@
+package runxml
+
+import (
+	"testing"
+)
+
+const dtdTestDoc = `<?xml version="1.0"?>
+<!DOCTYPE book PUBLIC "-//Example//DTD Book//EN" "book.dtd" [
+	<!-- declarations -->
+	<!ELEMENT book (title, chapter+, (appendix | index)?)>
+	<!ELEMENT title (#PCDATA)>
+	<!ELEMENT chapter (#PCDATA | em | strong)*>
+	<!ELEMENT br EMPTY>
+	<!ELEMENT any ANY>
+	<!ATTLIST book
+		id      ID             #REQUIRED
+		lang    NMTOKEN        "en"
+		status  (draft | final) 'draft'
+		format  NOTATION (pdf) #IMPLIED
+		version CDATA          #FIXED "1.&#48;">
+	<!ATTLIST book lang CDATA "no">
+	<!ENTITY % common "<!ENTITY copy '&#169; Example'>">
+	%common;
+	<!ENTITY author "J. &#68;oe &amp; &copy;">
+	<!ENTITY cover SYSTEM "cover.png" NDATA png>
+	<!ENTITY % ext PUBLIC "-//Example//ENTITIES//EN" "ext.ent">
+	<!NOTATION png SYSTEM "image/png">
+	<!NOTATION pdf PUBLIC "-//Adobe//PDF">
+	<?pi in dtd?>
+]>
+<book id="b1" status="final"><title>T</title></book>`
+
+func TestDTDDeclarations(t *testing.T) {
+	r := NewDefaultRunXML()
+	doc, err := r.Parse([]byte(dtdTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	dtd := doc.DTD()
+	if dtd == nil {
+		t.Fatal("expected DTD")
+	}
+	if dtd.Name != "book" || dtd.PublicID != "-//Example//DTD Book//EN" || dtd.SystemID != "book.dtd" {
+		t.Errorf("unexpected doctype %q %q %q", dtd.Name, dtd.PublicID, dtd.SystemID)
+	}
+	for name, expected := range map[string]string{
+		"book":    "(title,chapter+,(appendix|index)?)",
+		"title":   "(#PCDATA)",
+		"chapter": "(#PCDATA|em|strong)*",
+	} {
+		e := dtd.Elements[name]
+		if e == nil {
+			t.Errorf("element %v not declared", name)
+		} else if s := e.Content.String(); s != expected {
+			t.Errorf("element %v: expected content %v, found %v", name, expected, s)
+		}
+	}
+	if dtd.Elements["title"].ContentType != ContentMixed || dtd.Elements["book"].ContentType != ContentChildren ||
+		dtd.Elements["br"].ContentType != ContentEmpty || dtd.Elements["any"].ContentType != ContentAny {
+		t.Error("unexpected content types")
+	}
+
+	attrs := dtd.Attributes["book"]
+	if len(attrs) != 5 {
+		t.Fatalf("expected 5 attributes, found %v", len(attrs))
+	}
+	expected := []struct {
+		name  string
+		typ   AttributeType
+		def   DefaultType
+		value string
+	}{
+		{"id", AttrID, DefaultRequired, ""},
+		{"lang", AttrNmtoken, DefaultValue, "en"},
+		{"status", AttrEnumeration, DefaultValue, "draft"},
+		{"format", AttrNotation, DefaultImplied, ""},
+		{"version", AttrCDATA, DefaultFixed, "1.0"},
+	}
+	for i, e := range expected {
+		a := attrs[i]
+		if a.Name != e.name || a.Type != e.typ || a.DefaultType != e.def || a.Default != e.value {
+			t.Errorf("unexpected attribute declaration %+v", a)
+		}
+	}
+	if len(attrs[2].Enumeration) != 2 || attrs[2].Enumeration[1] != "final" {
+		t.Errorf("unexpected enumeration %v", attrs[2].Enumeration)
+	}
+
+	if e := dtd.Entities["author"]; e == nil || e.Value != "J. Doe &amp; &copy;" {
+		t.Errorf("unexpected entity %+v", e)
+	}
+	if e := dtd.Entities["copy"]; e == nil || e.Value != "© Example" {
+		t.Errorf("entity declared in parameter entity: unexpected %+v", e)
+	}
+	if e := dtd.Entities["cover"]; e == nil || e.SystemID != "cover.png" || e.Notation != "png" || !e.IsExternal() {
+		t.Errorf("unexpected unparsed entity %+v", e)
+	}
+	if e := dtd.ParameterEntities["ext"]; e == nil || !e.Parameter || e.PublicID != "-//Example//ENTITIES//EN" {
+		t.Errorf("unexpected parameter entity %+v", e)
+	}
+	if n := dtd.Notations["pdf"]; n == nil || n.PublicID != "-//Adobe//PDF" || n.SystemID != "" {
+		t.Errorf("unexpected notation %+v", n)
+	}
+}
+
+func TestDTDOnDocument(t *testing.T) {
+	r := NewDefaultRunXML()
+	doc, err := r.Parse([]byte(dtdTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	other, err := r.Parse([]byte(`<!DOCTYPE r [<!ELEMENT r EMPTY>]><r/>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if dtd := doc.GetFirstChild().DTD(); dtd == nil || dtd.Name != "book" || dtd.Elements["chapter"] == nil {
+		t.Errorf("DTD of the first document was not kept: %+v", dtd)
+	}
+	if dtd := other.DTD(); dtd == nil || dtd.Name != "r" {
+		t.Errorf("unexpected DTD %+v", dtd)
+	}
+	plain, err := r.Parse([]byte(`<r/>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if dtd := plain.DTD(); dtd != nil {
+		t.Errorf("expected no DTD, got %+v", dtd)
+	}
+}
+
+func TestDefaultAttributes(t *testing.T) {
+	r := NewDefaultRunXML()
+	doc, err := r.Parse([]byte(dtdTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var book *GenericNode
+	for n := doc.GetFirstChild(); n != nil; n = n.GetNextSibling() {
+		if n.NodeType == Element {
+			book = n
+		}
+	}
+	values := map[string]string{}
+	for _, a := range book.GetAttributes() {
+		values[string(a.Name)] = string(a.Value)
+	}
+	expected := map[string]string{"id": "b1", "status": "final", "lang": "en", "version": "1.0"}
+	if len(values) != len(expected) {
+		t.Errorf("expected attributes %v, found %v", expected, values)
+	}
+	for k, v := range expected {
+		if values[k] != v {
+			t.Errorf("attribute %v: expected %q, found %q", k, v, values[k])
+		}
+	}
+}
+
+func TestTokenizedAttributes(t *testing.T) {
+	xml := []byte("<!DOCTYPE r [<!ATTLIST r a NMTOKENS #IMPLIED b NMTOKENS ' x\ty '>]><r a='\n a\r\nb&#9;c  '/>")
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	values := map[string]string{}
+	for _, a := range doc.GetLastChild().GetAttributes() {
+		values[string(a.Name)] = string(a.Value)
+	}
+	if v := values["a"]; v != "a b\tc" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := values["b"]; v != "x y" {
+		t.Errorf("unexpected default value %q", v)
+	}
+}
+
+func TestMalformedDTD(t *testing.T) {
+	for _, xml := range []string{
+		`<!DOCTYPE doc [ <!ELEMENT doc (a,b|c)> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ELEMENT doc (#PCDATA|a)> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ATTLIST doc a CDATA "<"> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ENTITY % pe "x"> <!ENTITY e "%pe;"> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ENTITY % pe "<!ENTITY e 'x'>"> <!ELEMENT doc %pe;> ]><doc/>`,
+		`<?xml version="1.0" standalone="yes"?><!DOCTYPE doc [ %undeclared; ]><doc/>`,
+		`<?xml version="1.0" standalone="yes"?><!DOCTYPE doc [ <!ENTITY % a "%b;"> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ENTITY % a "&#37;a;"> %a; ]><doc/>`,
+		`<!DOCTYPE doc [ <!NOTATION n> ]><doc/>`,
+		`<!DOCTYPE doc [ <!ELEMENT doc EMPTY> <doc/>`,
+		`<!DOCTYPE doc []><!DOCTYPE doc []><doc/>`,
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
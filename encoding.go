@@ -0,0 +1,273 @@
+package runxml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// encodings are the encodings transcoded to UTF-8 before parsing, by lower case name
+var encodings = map[string]encoding.Encoding{
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-2":   charmap.ISO8859_2,
+	"iso-8859-3":   charmap.ISO8859_3,
+	"iso-8859-4":   charmap.ISO8859_4,
+	"iso-8859-5":   charmap.ISO8859_5,
+	"iso-8859-6":   charmap.ISO8859_6,
+	"iso-8859-7":   charmap.ISO8859_7,
+	"iso-8859-8":   charmap.ISO8859_8,
+	"iso-8859-9":   charmap.ISO8859_9,
+	"iso-8859-10":  charmap.ISO8859_10,
+	"iso-8859-13":  charmap.ISO8859_13,
+	"iso-8859-14":  charmap.ISO8859_14,
+	"iso-8859-15":  charmap.ISO8859_15,
+	"iso-8859-16":  charmap.ISO8859_16,
+	"windows-1250": charmap.Windows1250,
+	"windows-1251": charmap.Windows1251,
+	"windows-1252": charmap.Windows1252,
+	"windows-1253": charmap.Windows1253,
+	"windows-1254": charmap.Windows1254,
+	"windows-1255": charmap.Windows1255,
+	"windows-1256": charmap.Windows1256,
+	"windows-1257": charmap.Windows1257,
+	"windows-1258": charmap.Windows1258,
+	"shift_jis":    japanese.ShiftJIS,
+	"x-sjis":       japanese.ShiftJIS,
+	"euc-jp":       japanese.EUCJP,
+	"iso-2022-jp":  japanese.ISO2022JP,
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// Labels of the UTF-16 encodings detected from the byte order mark or the first characters
+const (
+	utf16BE = "UTF-16BE"
+	utf16LE = "UTF-16LE"
+)
+
+// detectEncoding detects the encoding of a document or external entity from its first bytes,
+// as described in appendix F of the XML specification. Returns the length of the byte order
+// mark and the label of the encoding; empty for UTF-8
+func detectEncoding(b []byte) (bom int, label string, err error) {
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		return len(bomUTF8), "", nil
+	case bytes.HasPrefix(b, []byte{0x00, 0x00, 0x00, 0x3C}), bytes.HasPrefix(b, []byte{0x3C, 0x00, 0x00, 0x00}),
+		bytes.HasPrefix(b, []byte{0x00, 0x00, 0xFE, 0xFF}), bytes.HasPrefix(b, []byte{0xFF, 0xFE, 0x00, 0x00}):
+		return 0, "", fmt.Errorf("unsupported encoding UCS-4")
+	case bytes.HasPrefix(b, bomUTF16BE):
+		return len(bomUTF16BE), utf16BE, nil
+	case bytes.HasPrefix(b, bomUTF16LE):
+		return len(bomUTF16LE), utf16LE, nil
+	case bytes.HasPrefix(b, []byte{0x00, 0x3C, 0x00, 0x3F}):
+		return 0, utf16BE, nil
+	case bytes.HasPrefix(b, []byte{0x3C, 0x00, 0x3F, 0x00}):
+		return 0, utf16LE, nil
+	case bytes.HasPrefix(b, []byte{0x4C, 0x6F, 0xA7, 0x94}):
+		// EBCDIC; the declaration is read in the invariant characters of code page 037
+		decl, _ := charmap.CodePage037.NewDecoder().Bytes(b[:min(len(b), 256)])
+		label := declaredEncoding(decl)
+		if len(label) == 0 {
+			return 0, "", fmt.Errorf("unsupported encoding EBCDIC")
+		}
+		return 0, string(label), nil
+	}
+	// ASCII compatible; the declaration names the encoding. The label is compared in place,
+	// so that parsing UTF-8 documents does not allocate.
+	decl := declaredEncoding(b)
+	switch {
+	case len(decl) == 0, equalFoldAny(decl, "utf-8", "utf8", "us-ascii", "ascii"):
+		return 0, "", nil
+	case equalFoldAny(decl, "utf-16", "utf-16be", "utf-16le"):
+		return 0, "", fmt.Errorf("document declared as %s is not encoded in UTF-16", decl)
+	}
+	return 0, string(decl), nil
+}
+
+// equalFoldAny reports whether b equals one of the labels, ignoring case
+func equalFoldAny(b []byte, labels ...string) bool {
+	for _, l := range labels {
+		if bytes.EqualFold(b, []byte(l)) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeDocument returns the data of a document or external entity in UTF-8 without byte order
+// mark, with line ends normalized
+func (r *RunXML) decodeDocument(b []byte) ([]byte, error) {
+	data, err := r.transcode(b)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeLineEnds(data), nil
+}
+
+// normalizeLineEnds translates CR LF sequences and CRs not followed by LF to LF in place, as
+// required before parsing (§2.11)
+func normalizeLineEnds(b []byte) []byte {
+	i := bytes.IndexByte(b, '\r')
+	if i < 0 {
+		return b
+	}
+	n := i
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c == '\r' {
+			c = '\n'
+			if i+1 < len(b) && b[i+1] == '\n' {
+				i++
+			}
+		}
+		b[n] = c
+		n++
+	}
+	return b[:n]
+}
+
+// lineEndReader normalizes the line ends of the data read from r, as normalizeLineEnds
+type lineEndReader struct {
+	r  io.Reader
+	cr bool // The data read so far ends with a CR, whose LF is dropped
+}
+
+func (l *lineEndReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if !l.cr && bytes.IndexByte(p[:n], '\r') < 0 {
+		return n, err
+	}
+	m := 0
+	for _, c := range p[:n] {
+		switch {
+		case c == '\n' && l.cr:
+			l.cr = false
+			continue
+		case c == '\r':
+			c = '\n'
+			l.cr = true
+		default:
+			l.cr = false
+		}
+		p[m] = c
+		m++
+	}
+	return m, err
+}
+
+// transcode returns the data of a document or external entity in UTF-8 without byte order mark
+func (r *RunXML) transcode(b []byte) ([]byte, error) {
+	bom, label, err := detectEncoding(b)
+	if err != nil {
+		return nil, err
+	}
+	b = b[bom:]
+	switch label {
+	case "":
+		return b, nil
+	case utf16BE:
+		return decodeUTF16(b, binary.BigEndian)
+	case utf16LE:
+		return decodeUTF16(b, binary.LittleEndian)
+	}
+	if enc := encodings[strings.ToLower(label)]; enc != nil {
+		out, err := enc.NewDecoder().Bytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode %v: %w", label, err)
+		}
+		return out, nil
+	}
+	cr, err := r.charsetReader(label, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(cr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %v: %w", label, err)
+	}
+	return out, nil
+}
+
+// decodeReader returns a reader of the document read from rd in UTF-8 without byte order mark
+func (r *RunXML) decodeReader(rd io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(rd, readBufferSize)
+	head, err := br.Peek(1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	bom, label, err := detectEncoding(head)
+	if err != nil {
+		return nil, err
+	}
+	br.Discard(bom)
+	switch label {
+	case "":
+		return br, nil
+	case utf16BE:
+		return transform.NewReader(br, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()), nil
+	case utf16LE:
+		return transform.NewReader(br, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()), nil
+	}
+	if enc := encodings[strings.ToLower(label)]; enc != nil {
+		return enc.NewDecoder().Reader(br), nil
+	}
+	return r.charsetReader(label, br)
+}
+
+// charsetReader converts input in an encoding not supported by the parser with the CharsetReader
+func (r *RunXML) charsetReader(label string, input io.Reader) (io.Reader, error) {
+	if r.CharsetReader == nil {
+		return nil, fmt.Errorf("unsupported encoding %q: no CharsetReader", label)
+	}
+	cr, err := r.CharsetReader(label, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert encoding %q: %w", label, err)
+	}
+	if cr == nil {
+		return nil, fmt.Errorf("CharsetReader returned no reader for encoding %q", label)
+	}
+	return cr, nil
+}
+
+// declaredEncoding returns the value of the encoding in the XML or text declaration
+// starting b, or nil
+func declaredEncoding(b []byte) []byte {
+	if !bytes.HasPrefix(b, []byte("<?xml")) || len(b) < 6 || lookupWhitespace[b[5]] != 1 {
+		return nil
+	}
+	decl := b[5:]
+	if end := bytes.Index(decl, []byte("?>")); end >= 0 {
+		decl = decl[:end]
+	}
+	i := bytes.Index(decl, []byte("encoding"))
+	if i < 0 {
+		return nil
+	}
+	decl = bytes.TrimLeft(decl[i+len("encoding"):], " \t\r\n")
+	if len(decl) == 0 || decl[0] != '=' {
+		return nil
+	}
+	decl = bytes.TrimLeft(decl[1:], " \t\r\n")
+	if len(decl) == 0 || decl[0] != '"' && decl[0] != '\'' {
+		return nil
+	}
+	end := bytes.IndexByte(decl[1:], decl[0])
+	if end < 0 {
+		return nil
+	}
+	return decl[1 : end+1]
+}
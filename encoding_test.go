@@ -0,0 +1,115 @@
+package runxml
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestEncodings(t *testing.T) {
+	for _, test := range []struct {
+		xml      []byte
+		expected string
+	}{
+		{[]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><doc a=\"\xe6\">bl\xe5</doc>"), "blå"},
+		{[]byte("<?xml version='1.0' encoding='windows-1252'?><doc a='\xe6'>\x80</doc>"), "€"},
+		{[]byte("<?xml version=\"1.0\" encoding=\"Shift_JIS\"?><doc a=\"\x93\xfa\">\x93\xfa\x96\x7b</doc>"), "日本"},
+		{[]byte("<?xml version=\"1.0\" encoding=\"EUC-JP\"?><doc a=\"\xc6\xfc\">\xc6\xfc\xcb\xdc</doc>"), "日本"},
+		{[]byte("\xef\xbb\xbf<doc a=\"b\">ø</doc>"), "ø"},
+		{[]byte("\x00<\x00?\x00x\x00m\x00l\x00 \x00v\x00e\x00r\x00s\x00i\x00o\x00n\x00=\x00'\x001\x00.\x000\x00'\x00?\x00>" +
+			"\x00<\x00d\x00o\x00c\x00 \x00a\x00=\x00'\x00b\x00'\x00>\x00\xf8\x00<\x00/\x00d\x00o\x00c\x00>"), "ø"},
+		{[]byte("\xff\xfe<\x00d\x00o\x00c\x00 \x00a\x00=\x00'\x00b\x00'\x00>\x00\xf8\x00<\x00/\x00d\x00o\x00c\x00>\x00"), "ø"},
+	} {
+		r := NewDefaultRunXML()
+		doc, err := r.Parse(test.xml)
+		if err != nil {
+			t.Errorf("%q: should not fail: %v", test.xml, err)
+			continue
+		}
+		if v := string(doc.GetLastChild().GetFirstChild().Value); v != test.expected {
+			t.Errorf("%q: expected %q, found %q", test.xml, test.expected, v)
+		}
+	}
+}
+
+func TestUnsupportedEncodings(t *testing.T) {
+	for _, xml := range []string{
+		`<?xml version="1.0" encoding="x-unknown"?><doc/>`,
+		`<?xml version="1.0" encoding="UTF-16"?><doc/>`,
+		"<\x00d\x00o\x00c\x00/\x00>\x00", // UTF-16 without byte order mark or declaration
+		"\x00\x00\x00<\x00\x00\x00d\x00\x00\x00/\x00\x00\x00>",
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%q: expected error", xml)
+		}
+	}
+}
+
+func TestLineEnds(t *testing.T) {
+	xml := "<doc a='x\r\ny\rz'>a\r\nb\rc&#13;\n\r</doc>"
+	utf16 := []byte{0xFF, 0xFE}
+	for _, c := range []byte(xml) {
+		utf16 = append(utf16, c, 0)
+	}
+	for _, b := range [][]byte{[]byte(xml), utf16} {
+		r := NewDefaultRunXML()
+		doc, err := r.Parse(b)
+		if err != nil {
+			t.Errorf("%q: should not fail: %v", b, err)
+			continue
+		}
+		root := doc.GetLastChild()
+		if v := string(root.GetFirstChild().Value); v != "a\nb\nc\r\n\n" {
+			t.Errorf("%q: unexpected text value %q", b, v)
+		}
+		if v := string(root.GetAttributes()[0].Value); v != "x y z" {
+			t.Errorf("%q: unexpected attribute value %q", b, v)
+		}
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	ebcdic, err := charmap.CodePage037.NewEncoder().Bytes([]byte(`<?xml version="1.0" encoding="IBM037"?><doc a="b">blå</doc>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewDefaultRunXML()
+	if _, err := r.Parse(ebcdic); err == nil || !strings.Contains(err.Error(), "IBM037") {
+		t.Errorf("expected unsupported encoding error, found %v", err)
+	}
+	var labels []string
+	r.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		labels = append(labels, charset)
+		switch strings.ToLower(charset) {
+		case "ibm037":
+			return charmap.CodePage037.NewDecoder().Reader(input), nil
+		case "x-ascii":
+			return input, nil
+		}
+		return nil, errors.New("unknown charset")
+	}
+	doc, err := r.Parse(ebcdic)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if v := string(doc.GetLastChild().GetFirstChild().Value); v != "blå" {
+		t.Errorf("expected %q, found %q", "blå", v)
+	}
+	if _, err := r.Parse([]byte(`<?xml version="1.0" encoding="x-ascii"?><doc/>`)); err != nil {
+		t.Error("should not fail", err)
+	}
+	if _, err := r.Parse([]byte(`<?xml version="1.0" encoding="x-unknown"?><doc/>`)); err == nil {
+		t.Error("expected error")
+	}
+	// Built-in encodings do not use the CharsetReader
+	if _, err := r.Parse([]byte(`<?xml version="1.0" encoding="ISO-8859-1"?><doc/>`)); err != nil {
+		t.Error("should not fail", err)
+	}
+	if strings.Join(labels, ",") != "IBM037,x-ascii,x-unknown" {
+		t.Errorf("unexpected charsets %v", labels)
+	}
+}
@@ -0,0 +1,283 @@
+package runxml
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Default limits on the expansion of declared entities, used when the corresponding
+// RunXML setting is zero
+const (
+	DefaultMaxEntityExpansion = 10 << 20 // Bytes of replacement text
+	DefaultMaxEntityDepth     = 20       // Nested entity references
+	DefaultMaxEntityRatio     = 10       // Replacement text bytes per document byte
+
+	entityRatioThreshold = 1 << 20 // The ratio is not checked below this amount of replacement text
+)
+
+// EntityLimitError is returned when the expansion of declared entities exceeds one of
+// the limits configured on RunXML
+type EntityLimitError struct {
+	Entity string // Name of the entity being expanded
+	Limit  string // Name of the exceeded setting: MaxEntityExpansion, MaxEntityDepth, or MaxEntityRatio
+	Value  int    // Value of the exceeded setting
+}
+
+func (e *EntityLimitError) Error() string {
+	return fmt.Sprintf("expanding entity %q exceeds %v (%v)", e.Entity, e.Limit, e.Value)
+}
+
+// Entity markup states, cached on the declaration by entityHasMarkup
+const (
+	markupUnknown int8 = iota
+	markupChecking
+	markupNo
+	markupYes
+)
+
+// Replacement text of the predefined entities
+var (
+	entityLt   = []byte("<")
+	entityGt   = []byte(">")
+	entityAmp  = []byte("&")
+	entityApos = []byte("'")
+	entityQuot = []byte("\"")
+)
+
+// predefinedEntity returns the replacement text of a predefined entity, or nil
+func predefinedEntity(name []byte) []byte {
+	switch string(name) {
+	case "lt":
+		return entityLt
+	case "gt":
+		return entityGt
+	case "amp":
+		return entityAmp
+	case "apos":
+		return entityApos
+	case "quot":
+		return entityQuot
+	}
+	return nil
+}
+
+// limit returns the effective value of a limit setting; negative values disable the limit
+func limit(setting, def int) int {
+	if setting == 0 {
+		return def
+	}
+	return setting
+}
+
+// undeclaredEntityIsError reports whether a reference to an undeclared entity violates
+// well-formedness. For documents with an external subset or parameter entity references
+// it is a validity error only, unless the document is standalone.
+func (r *RunXML) undeclaredEntityIsError() bool {
+	return r.dtd == nil || r.standalone || r.dtd.SystemID == "" && !r.dtd.peReferenced
+}
+
+// openEntity marks an entity as being expanded and accounts for its replacement text.
+// The caller must call closeEntity when done.
+func (r *RunXML) openEntity(decl *EntityDecl) error {
+	for _, open := range r.openEntities {
+		if open == decl {
+			return fmt.Errorf("recursive reference to entity %q", decl.Name)
+		}
+	}
+	if max := limit(r.MaxEntityDepth, DefaultMaxEntityDepth); max > 0 && len(r.openEntities) >= max {
+		return &EntityLimitError{Entity: decl.Name, Limit: "MaxEntityDepth", Value: max}
+	}
+	r.expanded += len(decl.value)
+	if max := limit(r.MaxEntityExpansion, DefaultMaxEntityExpansion); max > 0 && r.expanded > max {
+		return &EntityLimitError{Entity: decl.Name, Limit: "MaxEntityExpansion", Value: max}
+	}
+	if ratio := limit(r.MaxEntityRatio, DefaultMaxEntityRatio); ratio > 0 &&
+		r.expanded > entityRatioThreshold && r.expanded > ratio*r.docSize {
+		return &EntityLimitError{Entity: decl.Name, Limit: "MaxEntityRatio", Value: ratio}
+	}
+	r.openEntities = append(r.openEntities, decl)
+	return nil
+}
+
+// closeEntity marks the last opened entity as done
+func (r *RunXML) closeEntity() {
+	r.openEntities = r.openEntities[:len(r.openEntities)-1]
+}
+
+// entityHasMarkup reports whether the replacement text of an entity, or of an entity it
+// references, contains markup or references kept as EntityRef nodes, and must be parsed as
+// content rather than expanded as text
+func (r *RunXML) entityHasMarkup(decl *EntityDecl) bool {
+	switch decl.markup {
+	case markupNo:
+		return false
+	case markupYes, markupChecking: // a recursive reference is reported when it is included
+		return true
+	}
+	if decl.IsExternal() {
+		decl.markup = markupYes
+		return true
+	}
+	decl.markup = markupChecking
+	has := false
+	for i, v := 0, decl.value; i < len(v) && !has; i++ {
+		switch v[i] {
+		case '<':
+			has = true
+		case '&':
+			name, n, err := refName(v[i:])
+			if err != nil {
+				continue // reported when expanded
+			}
+			if predefinedEntity(name) == nil {
+				if ref := r.dtd.Entities[string(name)]; ref != nil {
+					has = r.entityHasMarkup(ref)
+				} else {
+					has = !r.undeclaredEntityIsError()
+				}
+			}
+			i += n - 1
+		}
+	}
+	decl.markup = markupNo
+	if has {
+		decl.markup = markupYes
+	}
+	return has
+}
+
+// expandEntityText appends the replacement text of an internal entity to dst, with
+// character and entity references expanded. In attribute values, literal whitespace of the
+// replacement text is normalized to spaces.
+func (r *RunXML) expandEntityText(decl *EntityDecl, dst []byte, inAttribute bool) ([]byte, error) {
+	if err := r.openEntity(decl); err != nil {
+		return nil, err
+	}
+	defer r.closeEntity()
+	v := decl.value
+	for i := 0; i < len(v); {
+		j := i
+		for j < len(v) && v[j] != '&' && v[j] != '<' {
+			j++
+		}
+		from := len(dst)
+		dst = append(dst, v[i:j]...)
+		if inAttribute {
+			for k, c := range dst[from:] {
+				if lookupWhitespace[c] == 1 {
+					dst[from+k] = ' '
+				}
+			}
+		}
+		if i = j; i == len(v) {
+			break
+		}
+		if v[i] == '<' {
+			return nil, fmt.Errorf("'<' in replacement text of entity %q", decl.Name)
+		}
+		if i+1 < len(v) && v[i+1] == '#' {
+			cp, n, err := r.decodeCharacterRef(v[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v in replacement text of entity %q", err, decl.Name)
+			}
+			dst = utf8.AppendRune(dst, cp)
+			i += n
+			continue
+		}
+		name, n, err := refName(v[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%v in replacement text of entity %q", err, decl.Name)
+		}
+		i += n
+		if p := predefinedEntity(name); p != nil {
+			dst = append(dst, p...)
+			continue
+		}
+		ref := r.dtd.Entities[string(name)]
+		switch {
+		case ref == nil:
+			// In content, entities referencing undeclared entities are included by includeEntity
+			return nil, fmt.Errorf("reference to undeclared entity %q in replacement text of entity %q", name, decl.Name)
+		case ref.Notation != "":
+			return nil, fmt.Errorf("reference to unparsed entity %q in replacement text of entity %q", name, decl.Name)
+		case r.standalone && ref.extDecl:
+			return nil, fmt.Errorf("reference to externally declared entity %q in standalone document", name)
+		case inAttribute && ref.IsExternal():
+			return nil, fmt.Errorf("reference to external entity %q in attribute value", name)
+		}
+		if dst, err = r.expandEntityText(ref, dst, inAttribute); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// includedEntity is an entity whose replacement text is being parsed as content
+type includedEntity struct {
+	decl     *EntityDecl
+	parent   *GenericNode // Element containing the reference
+	data     []byte       // Buffer containing the reference
+	position int          // Position after the reference
+	start    int          // Position of the reference
+	end      int          // Position of the end of the replacement text, reported by scanContent
+}
+
+// includeEntity continues parsing in the replacement text of the entity referenced in the
+// content of parent, until scanContent reports its end and endEntity returns to the reference.
+// A reference to an undeclared entity, which may be declared in the parts of the DTD that were
+// not read, is returned as an EntityRef node instead. Expects position to be at the '&' and
+// leaves it after the ';', or at the start of the replacement text.
+func (r *RunXML) includeEntity(parent *GenericNode) (*GenericNode, error) {
+	start := r.position
+	name, n, err := refName(r.sliceToEnd())
+	if err != nil {
+		return nil, fmt.Errorf("%v at position %v", err, start)
+	}
+	r.position += n
+	decl := r.dtd.Entities[string(name)]
+	if decl == nil {
+		ref := r.newNode(EntityRef)
+		ref.Name = name
+		return ref, nil
+	}
+	if decl.IsExternal() {
+		ok, err := r.loadEntity(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%w at position %v", err, start)
+		}
+		if !ok {
+			return nil, nil // access refused; the entity is not included
+		}
+	}
+	if err := r.openEntity(decl); err != nil {
+		return nil, fmt.Errorf("%w at position %v", err, start)
+	}
+	r.included = append(r.included, includedEntity{
+		decl: decl, parent: parent, data: r.data, position: r.position, start: start, end: len(decl.value),
+	})
+	// The '<' after the replacement text stops the scanning of text and whitespace at its end
+	buf := make([]byte, 0, len(decl.value)+1)
+	buf = append(buf, decl.value...)
+	buf = append(buf, '<')
+	r.data, r.position = buf, 0
+	return nil, nil
+}
+
+// endEntity continues parsing after the reference to the entity whose replacement text ended,
+// in the content of element
+func (r *RunXML) endEntity(element *GenericNode) error {
+	e := r.included[len(r.included)-1]
+	r.included = r.included[:len(r.included)-1]
+	r.closeEntity()
+	r.data, r.position = e.data, e.position
+	if e.parent != element {
+		return fmt.Errorf("replacement text of entity %q referenced at position %v is not well-balanced", e.decl.Name, e.start)
+	}
+	return nil
+}
+
+// inEntity reports whether the content of element being parsed is in the replacement text of
+// an entity referenced in it, where its end tag is not allowed
+func (r *RunXML) inEntity(element *GenericNode) bool {
+	return len(r.included) > 0 && r.included[len(r.included)-1].parent == element
+}
@@ -0,0 +1,209 @@
+package runxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEntityExpansion(t *testing.T) {
+	xml := []byte(`<!DOCTYPE r [
+	<!ENTITY name "World">
+	<!ENTITY greeting "Hello, &name;&#33;">
+	<!ENTITY amp2 "&#38;#38;">
+]>
+<r a="&greeting;" b='&amp2;&amp;'>&greeting; &lt;&name;&gt;</r>`)
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetLastChild()
+	if v := string(root.GetFirstChild().Value); v != "Hello, World! <World>" {
+		t.Errorf("unexpected text value %q", v)
+	}
+	attrs := root.GetAttributes()
+	if v := string(attrs[0].Value); v != "Hello, World!" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := string(attrs[1].Value); v != "&&" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+}
+
+func TestMarkupEntities(t *testing.T) {
+	xml := []byte(`<!DOCTYPE r [
+	<!ENTITY item "<i n='&num;'>&num;</i>">
+	<!ENTITY num "1">
+	<!ENTITY list "<l>&item;&item;</l>">
+	<!ENTITY lt "&#38;#60;">
+]>
+<r>a&list;b&lt;</r>`)
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetLastChild()
+	var sb strings.Builder
+	var walk func(n *GenericNode)
+	walk = func(n *GenericNode) {
+		for c := n.GetFirstChild(); c != nil; c = c.GetNextSibling() {
+			switch c.NodeType {
+			case Data:
+				sb.Write(c.Value)
+			case Element:
+				sb.WriteString("<" + string(c.Name))
+				for _, a := range c.GetAttributes() {
+					sb.WriteString(" " + string(a.Name) + "=" + string(a.Value))
+				}
+				sb.WriteString(">")
+				walk(c)
+				sb.WriteString("</" + string(c.Name) + ">")
+			}
+		}
+	}
+	walk(root)
+	if v := sb.String(); v != "a<l><i n=1>1</i><i n=1>1</i></l>b<" {
+		t.Errorf("unexpected content %q", v)
+	}
+}
+
+func TestUndeclaredEntities(t *testing.T) {
+	// The entities may be declared in the external subset, which is not read
+	xml := []byte(`<!DOCTYPE r SYSTEM "r.dtd" [<!ENTITY b "b&f;">]><r>a&e;&amp;e;&b;</r>`)
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var nodes []string
+	for n := doc.GetLastChild().GetFirstChild(); n != nil; n = n.GetNextSibling() {
+		nodes = append(nodes, fmt.Sprintf("%v %s %s", n.NodeType, n.Name, n.Value))
+	}
+	expected := []string{"Data  a", "EntityRef e ", "Data  &e;", "Data  b", "EntityRef f "}
+	if !slices.Equal(nodes, expected) {
+		t.Errorf("unexpected nodes %q", nodes)
+	}
+	var b bytes.Buffer
+	if _, err := doc.GetLastChild().WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if v := b.String(); v != "<r>a&e;&amp;e;b&f;</r>" {
+		t.Errorf("unexpected output %q", v)
+	}
+	for _, xml := range []string{
+		`<?xml version="1.0" standalone="yes"?><!DOCTYPE r SYSTEM "r.dtd"><r>&e;</r>`,
+		`<!DOCTYPE r SYSTEM "r.dtd"><r a="&e;"/>`,
+		`<!DOCTYPE r SYSTEM "r.dtd" [<!ENTITY a "&e;">]><r a="&a;"/>`,
+	} {
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
+
+func TestEntityAttributeWhitespace(t *testing.T) {
+	xml := []byte("<!DOCTYPE r [<!ENTITY ws \"x&#9;y\">]><r a=\"&ws;\" b='&ws;&#10;z'>&ws;</r>")
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetLastChild()
+	attrs := root.GetAttributes()
+	if v := string(attrs[0].Value); v != "x y" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := string(attrs[1].Value); v != "x y\nz" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := string(root.GetFirstChild().Value); v != "x\ty" {
+		t.Errorf("unexpected text value %q", v)
+	}
+}
+
+func TestMalformedEntities(t *testing.T) {
+	for _, xml := range []string{
+		`<!DOCTYPE r [ <!ENTITY a "&b;"> <!ENTITY b "&a;"> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "&b;"> <!ENTITY b "&a;"> ]><r x="&a;"/>`,
+		`<!DOCTYPE r [ <!ENTITY a "<x>&a;</x>"> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "<x>"> ]><r>&a;</x></r>`,
+		`<!DOCTYPE r [ <!ENTITY a "</r><r>"> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "<x/>"> ]><r x="&a;"/>`,
+		`<!DOCTYPE r [ <!ENTITY a "&#60;"> ]><r x="&a;"/>`,
+		`<!DOCTYPE r [ <!ENTITY a SYSTEM "a.xml"> ]><r x="&a;"/>`,
+		`<!DOCTYPE r [ <!ENTITY a SYSTEM "a.png" NDATA png> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "&b;"> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "&#38;b"> ]><r>&a;</r>`,
+		`<!DOCTYPE r [ <!ENTITY a "&#38;#0;"> ]><r>&a;</r>`,
+		`<r x="a<b"/>`,
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
+
+// billionLaughs returns a document with entities nested levels deep, each referencing
+// the previous one ten times
+func billionLaughs(levels int, attribute bool) []byte {
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE lolz [<!ENTITY lol0 "lol">`)
+	for i := 1; i < levels; i++ {
+		sb.WriteString("<!ENTITY lol" + string(rune('0'+i)) + ` "`)
+		for j := 0; j < 10; j++ {
+			sb.WriteString("&lol" + string(rune('0'+i-1)) + ";")
+		}
+		sb.WriteString(`">`)
+	}
+	last := "&lol" + string(rune('0'+levels-1)) + ";"
+	if attribute {
+		sb.WriteString(`]><lolz a="` + last + `"/>`)
+	} else {
+		sb.WriteString(`]><lolz>` + last + `</lolz>`)
+	}
+	return []byte(sb.String())
+}
+
+func TestEntityLimits(t *testing.T) {
+	tests := []struct {
+		doc   []byte
+		setup func(r *RunXML)
+		limit string
+	}{
+		{billionLaughs(10, false), func(r *RunXML) {}, "MaxEntityRatio"},
+		{billionLaughs(10, true), func(r *RunXML) {}, "MaxEntityRatio"},
+		{billionLaughs(10, false), func(r *RunXML) { r.MaxEntityRatio = -1 }, "MaxEntityExpansion"},
+		{billionLaughs(4, false), func(r *RunXML) { r.MaxEntityExpansion = 1000 }, "MaxEntityExpansion"},
+		{billionLaughs(4, true), func(r *RunXML) { r.MaxEntityDepth = 3 }, "MaxEntityDepth"},
+	}
+	for _, test := range tests {
+		r := NewDefaultRunXML()
+		test.setup(r)
+		_, err := r.Parse(test.doc)
+		var limitErr *EntityLimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("expected EntityLimitError, found %v", err)
+			continue
+		}
+		if limitErr.Limit != test.limit {
+			t.Errorf("expected %v to be exceeded, found %v", test.limit, limitErr.Limit)
+		}
+	}
+	// Limits can be raised or disabled
+	r := NewDefaultRunXML()
+	r.MaxEntityExpansion = -1
+	r.MaxEntityRatio = -1
+	doc, err := r.Parse(billionLaughs(6, false))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if n := len(doc.GetLastChild().GetFirstChild().Value); n != 3*100000 {
+		t.Errorf("expected %v bytes of text, found %v", 3*100000, n)
+	}
+}
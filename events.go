@@ -0,0 +1,234 @@
+package runxml
+
+// Handler receives the nodes of a document parsed by ParseEvents, in document order. The
+// byte slices refer to the parser's buffer, like the values of nodes; the attributes are
+// reused after StartElement returns. A handler error stops parsing.
+type Handler interface {
+	XMLDecl(version, encoding, standalone []byte) error
+	Doctype(text []byte, dtd *DTD) error
+	StartElement(name []byte, attributes []*AttributeNode) error
+	EndElement(name []byte) error
+	CharData(data []byte) error
+	CDATA(data []byte) error
+	Comment(data []byte) error
+	ProcessingInstruction(target, instruction []byte) error
+	EntityRef(name []byte) error
+}
+
+// NopHandler ignores all nodes. It can be embedded in handlers interested in some of them.
+type NopHandler struct{}
+
+// XMLDecl ignores the XML declaration
+func (NopHandler) XMLDecl(version, encoding, standalone []byte) error { return nil }
+
+// Doctype ignores the document type declaration
+func (NopHandler) Doctype(text []byte, dtd *DTD) error { return nil }
+
+// StartElement ignores the start of an element
+func (NopHandler) StartElement(name []byte, attributes []*AttributeNode) error { return nil }
+
+// EndElement ignores the end of an element
+func (NopHandler) EndElement(name []byte) error { return nil }
+
+// CharData ignores text
+func (NopHandler) CharData(data []byte) error { return nil }
+
+// CDATA ignores CDATA sections
+func (NopHandler) CDATA(data []byte) error { return nil }
+
+// Comment ignores comments
+func (NopHandler) Comment(data []byte) error { return nil }
+
+// ProcessingInstruction ignores processing instructions
+func (NopHandler) ProcessingInstruction(target, instruction []byte) error { return nil }
+
+// EntityRef ignores references to entities declared in the parts of the DTD that were not read
+func (NopHandler) EntityRef(name []byte) error { return nil }
+
+// eventState holds the nodes reused by ParseEvents and Tokenizer instead of building a tree
+type eventState struct {
+	handler    Handler          // Handler of ParseEvents
+	compact    *compactBuilder  // Builder of ParseCompact
+	reuse      bool             // Nodes are reused
+	document   GenericNode      // The document node
+	node       GenericNode      // The current node, other than elements and the document
+	elements   []*GenericNode   // The open elements by depth, whose parents are the enclosing elements
+	marks      []int            // Attributes in use before each open element
+	depth      int              // Number of open elements
+	attributes []*AttributeNode // Attributes of the open elements, reused once they are closed
+	used       int              // Number of attributes in use
+	list       []*AttributeNode // Attributes passed to StartElement
+}
+
+// clear drops the references of the reused nodes to the previous document
+func (e *eventState) clear() {
+	e.document = GenericNode{}
+	e.node = GenericNode{}
+	for _, n := range e.elements {
+		*n = GenericNode{}
+	}
+	for _, a := range e.attributes {
+		*a = AttributeNode{}
+	}
+}
+
+// ParseEvents parses the entire byte slice, passing its nodes to h instead of building a tree.
+// No nodes are allocated per element; the elements and attributes are reused, while keeping
+// the enclosing elements as parents for namespace lookups during StartElement.
+func (r *RunXML) ParseEvents(b []byte, h Handler) error {
+	data, err := r.decodeDocument(b)
+	if err != nil {
+		return err
+	}
+	r.reset(r.BaseURI)
+	r.data = data
+	r.docSize = len(b)
+	r.events.handler = h
+	r.events.reuse = true
+	defer func() {
+		r.events.handler = nil
+		r.events.reuse = false
+	}()
+	_, err = r.parseDocument()
+	return err
+}
+
+// newNode returns a new node, or the reused node in ParseEvents and Tokenizer
+func (r *RunXML) newNode(nodeType NodeType) *GenericNode {
+	e := &r.events
+	switch {
+	case !e.reuse:
+		return r.nodeArena.newNode(nodeType)
+	case nodeType == Document:
+		e.document = GenericNode{NodeType: Document}
+		return &e.document
+	}
+	e.node = GenericNode{NodeType: nodeType}
+	return &e.node
+}
+
+// newElement returns a new element node, or the reused node at the depth of the element in
+// ParseEvents and Tokenizer
+func (r *RunXML) newElement() *GenericNode {
+	e := &r.events
+	if !e.reuse {
+		return r.nodeArena.newNode(Element)
+	}
+	if e.depth == len(e.elements) {
+		e.elements = append(e.elements, new(GenericNode))
+		e.marks = append(e.marks, 0)
+	}
+	n := e.elements[e.depth]
+	*n = GenericNode{NodeType: Element}
+	e.marks[e.depth] = e.used
+	e.depth++
+	return n
+}
+
+// newAttribute returns a new attribute node, or a reused one in ParseEvents and Tokenizer
+func (r *RunXML) newAttribute() *AttributeNode {
+	e := &r.events
+	if !e.reuse {
+		return r.attributeArena.get()
+	}
+	if e.used == len(e.attributes) {
+		e.attributes = append(e.attributes, new(AttributeNode))
+	}
+	a := e.attributes[e.used]
+	*a = AttributeNode{}
+	e.used++
+	return a
+}
+
+// appendNode adds a parsed node to its parent, to the document of ParseCompact, or passes it
+// to the handler of ParseEvents.
+// Elements are passed by startElement and endElement while they are parsed.
+func (r *RunXML) appendNode(parent, child *GenericNode) error {
+	if child == nil {
+		return nil
+	}
+	if c := r.events.compact; c != nil {
+		switch child.NodeType {
+		case Element:
+			return nil
+		case Declaration:
+			r.events.used = 0 // the declaration precedes all elements
+		}
+		_, err := c.add(child)
+		return err
+	}
+	h := r.events.handler
+	if h == nil {
+		parent.AppendNode(child)
+		return nil
+	}
+	switch child.NodeType {
+	case Data:
+		return h.CharData(child.Value)
+	case Cdata:
+		return h.CDATA(child.Value)
+	case Comment:
+		return h.Comment(child.Value)
+	case Pi:
+		return h.ProcessingInstruction(child.Name, child.Value)
+	case EntityRef:
+		return h.EntityRef(child.Name)
+	case Doctype:
+		return h.Doctype(child.Value, r.dtd)
+	case Declaration:
+		var version, encoding, standalone []byte
+		for a := child.firstAttribute; a != nil; a = a.next {
+			switch string(a.Name) {
+			case "version":
+				version = a.Value
+			case "encoding":
+				encoding = a.Value
+			case "standalone":
+				standalone = a.Value
+			}
+		}
+		r.events.used = 0 // the declaration precedes all elements
+		return h.XMLDecl(version, encoding, standalone)
+	}
+	return nil
+}
+
+// startElement passes the start of an element to the handler of ParseEvents, or adds it to
+// the document of ParseCompact
+func (r *RunXML) startElement(element *GenericNode) error {
+	if c := r.events.compact; c != nil {
+		return c.startElement(element)
+	}
+	if r.events.handler == nil {
+		return nil
+	}
+	return r.events.handler.StartElement(element.Name, r.attributeList(element))
+}
+
+// attributeList returns the attributes of an element in a reused slice
+func (r *RunXML) attributeList(element *GenericNode) []*AttributeNode {
+	e := &r.events
+	e.list = e.list[:0]
+	for a := element.firstAttribute; a != nil; a = a.next {
+		e.list = append(e.list, a)
+	}
+	return e.list
+}
+
+// endElement passes the end of an element to the handler of ParseEvents, and releases the
+// element and its attributes for reuse
+func (r *RunXML) endElement(element *GenericNode) error {
+	e := &r.events
+	if !e.reuse {
+		return nil
+	}
+	e.depth--
+	e.used = e.marks[e.depth]
+	if e.compact != nil {
+		e.compact.endElement()
+	}
+	if e.handler == nil {
+		return nil
+	}
+	return e.handler.EndElement(element.Name)
+}
@@ -0,0 +1,159 @@
+package runxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingHandler records the nodes passed by ParseEvents
+type recordingHandler struct {
+	strings.Builder
+}
+
+func (h *recordingHandler) XMLDecl(version, encoding, standalone []byte) error {
+	fmt.Fprintf(h, "xmldecl %s %s %s\n", version, encoding, standalone)
+	return nil
+}
+
+func (h *recordingHandler) Doctype(text []byte, dtd *DTD) error {
+	fmt.Fprintf(h, "doctype %s\n", dtd.Name)
+	return nil
+}
+
+func (h *recordingHandler) StartElement(name []byte, attributes []*AttributeNode) error {
+	fmt.Fprintf(h, "start %s", name)
+	for _, a := range attributes {
+		fmt.Fprintf(h, " %s=%q", a.Name, a.Value)
+		if ns := a.NamespaceURI(); ns != nil {
+			fmt.Fprintf(h, "{%s}", ns)
+		}
+	}
+	h.WriteString("\n")
+	return nil
+}
+
+func (h *recordingHandler) EndElement(name []byte) error {
+	fmt.Fprintf(h, "end %s\n", name)
+	return nil
+}
+
+func (h *recordingHandler) CharData(data []byte) error {
+	fmt.Fprintf(h, "text %q\n", data)
+	return nil
+}
+
+func (h *recordingHandler) CDATA(data []byte) error {
+	fmt.Fprintf(h, "cdata %q\n", data)
+	return nil
+}
+
+func (h *recordingHandler) Comment(data []byte) error {
+	fmt.Fprintf(h, "comment %q\n", data)
+	return nil
+}
+
+func (h *recordingHandler) ProcessingInstruction(target, instruction []byte) error {
+	fmt.Fprintf(h, "pi %s %q\n", target, instruction)
+	return nil
+}
+
+func (h *recordingHandler) EntityRef(name []byte) error {
+	fmt.Fprintf(h, "entityref %s\n", name)
+	return nil
+}
+
+func TestParseEvents(t *testing.T) {
+	xml := []byte(`<?xml version="1.0" standalone="yes"?>
+<!DOCTYPE doc [
+	<!ATTLIST item type CDATA "default">
+	<!ENTITY part "<part>&amp;</part>">
+]>
+<!-- comment -->
+<doc xmlns:x="urn:x">
+	<item x:id="1">a &lt; b</item><?pi data?>
+	<item type="other"><![CDATA[<cdata>]]></item>&part;
+</doc>`)
+	expected := `xmldecl 1.0  yes
+doctype doc
+comment " comment "
+start doc xmlns:x="urn:x"{http://www.w3.org/2000/xmlns/}
+start item x:id="1"{urn:x} type="default"
+text "a < b"
+end item
+pi pi "data"
+start item type="other"
+cdata "<cdata>"
+end item
+start part
+text "&"
+end part
+end doc
+`
+	h := &recordingHandler{}
+	if err := NewDefaultRunXML().ParseEvents(xml, h); err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if h.String() != expected {
+		t.Errorf("unexpected events:\n%s", h.String())
+	}
+}
+
+func TestParseEventsEntityRef(t *testing.T) {
+	// The external subset is not read, so the entity may be declared there
+	xml := []byte(`<!DOCTYPE doc SYSTEM "doc.dtd"><doc>a&e;b</doc>`)
+	expected := `doctype doc
+start doc
+text "a"
+entityref e
+text "b"
+end doc
+`
+	h := &recordingHandler{}
+	if err := NewDefaultRunXML().ParseEvents(xml, h); err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if h.String() != expected {
+		t.Errorf("unexpected events:\n%s", h.String())
+	}
+}
+
+// stopHandler returns an error at the first element
+type stopHandler struct {
+	NopHandler
+}
+
+var errStop = errors.New("stop")
+
+func (stopHandler) StartElement(name []byte, attributes []*AttributeNode) error {
+	return errStop
+}
+
+func TestParseEventsError(t *testing.T) {
+	err := NewDefaultRunXML().ParseEvents([]byte(`<doc><item/></doc>`), stopHandler{})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected error %v, found %v", errStop, err)
+	}
+}
+
+func TestParseEventsAllocations(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("<doc>")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, `<item id="%d" type="x"><name>item</name></item>`, i)
+	}
+	b.WriteString("</doc>")
+	xml := b.Bytes()
+	r := NewDefaultRunXML()
+	var h NopHandler
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := r.ParseEvents(xml, h); err != nil {
+			t.Fatal("should not fail", err)
+		}
+	})
+	if allocs > 10 {
+		t.Errorf("expected the parser to reuse its nodes, found %v allocations", allocs)
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/robfordww/runxml
+
+go 1.24
+
+require golang.org/x/text v0.21.0
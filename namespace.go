@@ -0,0 +1,180 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Namespace names bound to the reserved prefixes xml and xmlns
+const (
+	XMLNamespace   = "http://www.w3.org/XML/1998/namespace"
+	XMLNSNamespace = "http://www.w3.org/2000/xmlns/"
+)
+
+var (
+	xmlNamespace   = []byte(XMLNamespace)
+	xmlnsNamespace = []byte(XMLNSNamespace)
+)
+
+// splitQName returns the prefix and local part of a qualified name
+func splitQName(name []byte) (prefix, local []byte) {
+	if i := bytes.IndexByte(name, ':'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return nil, name
+}
+
+// Prefix returns the namespace prefix of the element name, or nil
+func (g *GenericNode) Prefix() []byte {
+	prefix, _ := splitQName(g.Name)
+	return prefix
+}
+
+// LocalName returns the element name without namespace prefix
+func (g *GenericNode) LocalName() []byte {
+	_, local := splitQName(g.Name)
+	return local
+}
+
+// NamespaceURI returns the namespace name of the element, or nil if it is in no namespace
+func (g *GenericNode) NamespaceURI() []byte {
+	return g.LookupNamespace(g.Prefix())
+}
+
+// LookupNamespace returns the namespace name bound to prefix by the namespace declarations
+// in scope of the node, or nil if the prefix is not bound. A nil prefix looks up the
+// default namespace.
+func (g *GenericNode) LookupNamespace(prefix []byte) []byte {
+	switch string(prefix) {
+	case "xml":
+		return xmlNamespace
+	case "xmlns":
+		return xmlnsNamespace
+	}
+	for n := g; n != nil && n.NodeType == Element; n = n.Parent {
+		for a := n.firstAttribute; a != nil; a = a.next {
+			if declPrefix, ok := namespaceDecl(a.Name); ok && bytes.Equal(declPrefix, prefix) {
+				if len(a.Value) == 0 {
+					return nil // undeclared
+				}
+				return a.Value
+			}
+		}
+	}
+	return nil
+}
+
+// Prefix returns the namespace prefix of the attribute name, or nil
+func (a *AttributeNode) Prefix() []byte {
+	prefix, _ := splitQName(a.Name)
+	return prefix
+}
+
+// LocalName returns the attribute name without namespace prefix
+func (a *AttributeNode) LocalName() []byte {
+	_, local := splitQName(a.Name)
+	return local
+}
+
+// NamespaceURI returns the namespace name of the attribute. Attributes without prefix
+// are in no namespace, except for the default namespace declaration xmlns.
+func (a *AttributeNode) NamespaceURI() []byte {
+	prefix := a.Prefix()
+	if prefix == nil {
+		if string(a.Name) == "xmlns" {
+			return xmlnsNamespace
+		}
+		return nil
+	}
+	if a.Parent == nil {
+		return nil
+	}
+	return a.Parent.LookupNamespace(prefix)
+}
+
+// namespaceDecl reports whether an attribute name is a namespace declaration, and returns
+// the declared prefix; nil for the default namespace
+func namespaceDecl(name []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(name, xmlnsPrefix) {
+		return nil, false
+	}
+	if len(name) == len(xmlnsPrefix) {
+		return nil, true
+	}
+	if name[len(xmlnsPrefix)] != ':' {
+		return nil, false
+	}
+	return name[len(xmlnsPrefix)+1:], true
+}
+
+var xmlnsPrefix = []byte("xmlns")
+
+// checkQName reports names that are not qualified names, having more than one colon or
+// an empty prefix or local part
+func checkQName(name []byte) error {
+	prefix, local := splitQName(name)
+	if prefix != nil && len(prefix) == 0 || len(local) == 0 || bytes.IndexByte(local, ':') >= 0 {
+		return fmt.Errorf("%q is not a qualified name", name)
+	}
+	return nil
+}
+
+// checkNCName reports names of PI targets, entities and notations containing a colon
+func (r *RunXML) checkNCName(name []byte) error {
+	if r.StrictNamespaces && bytes.IndexByte(name, ':') >= 0 {
+		return fmt.Errorf("colon in name %q", name)
+	}
+	return nil
+}
+
+// checkNamespaces reports the namespace errors of an element and its attributes:
+// malformed qualified names, undeclared prefixes, illegal declarations of the reserved
+// prefixes and namespaces, and attributes with the same local and namespace name
+func (r *RunXML) checkNamespaces(element *GenericNode) error {
+	if err := checkQName(element.Name); err != nil {
+		return err
+	}
+	if prefix := element.Prefix(); prefix != nil {
+		if string(prefix) == "xmlns" {
+			return fmt.Errorf("element %q has the prefix xmlns", element.Name)
+		}
+		if element.LookupNamespace(prefix) == nil {
+			return fmt.Errorf("undeclared namespace prefix %q of element %q", prefix, element.Name)
+		}
+	}
+	for a := element.firstAttribute; a != nil; a = a.next {
+		if err := checkQName(a.Name); err != nil {
+			return err
+		}
+		if prefix, ok := namespaceDecl(a.Name); ok {
+			if err := r.checkNamespaceDecl(prefix, a.Value); err != nil {
+				return err
+			}
+		} else if prefix := a.Prefix(); prefix != nil && a.NamespaceURI() == nil {
+			return fmt.Errorf("undeclared namespace prefix %q of attribute %q", prefix, a.Name)
+		}
+		for b := element.firstAttribute; b != a; b = b.next {
+			if bytes.Equal(a.LocalName(), b.LocalName()) && bytes.Equal(a.NamespaceURI(), b.NamespaceURI()) {
+				return fmt.Errorf("attributes %q and %q of element %q have the same name", b.Name, a.Name, element.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNamespaceDecl reports illegal namespace declarations
+func (r *RunXML) checkNamespaceDecl(prefix, uri []byte) error {
+	switch {
+	case string(prefix) == "xmlns":
+		return fmt.Errorf("the prefix xmlns must not be declared")
+	case string(prefix) == "xml":
+		if !bytes.Equal(uri, xmlNamespace) {
+			return fmt.Errorf("the prefix xml must be bound to %v", XMLNamespace)
+		}
+	case bytes.Equal(uri, xmlNamespace) || bytes.Equal(uri, xmlnsNamespace):
+		return fmt.Errorf("the namespace %s must not be declared", uri)
+	case prefix != nil && len(uri) == 0 && !r.xml11:
+		return fmt.Errorf("the prefix %q must not be undeclared in XML 1.0", prefix)
+	}
+	return nil
+}
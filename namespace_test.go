@@ -0,0 +1,87 @@
+package runxml
+
+import (
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	xml := []byte(`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns="urn:default">
+	<soap:Body xml:lang="en" id="b">
+		<item xmlns="" soap:mustUnderstand="true"/>
+	</soap:Body>
+</soap:Envelope>`)
+	r := NewDefaultRunXML()
+	r.StrictNamespaces = true
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	envelope := doc.GetFirstChild()
+	body := envelope.GetFirstChild()
+	item := body.GetFirstChild()
+	for _, test := range []struct {
+		node                     *GenericNode
+		prefix, local, namespace string
+	}{
+		{envelope, "soap", "Envelope", "http://www.w3.org/2003/05/soap-envelope"},
+		{body, "soap", "Body", "http://www.w3.org/2003/05/soap-envelope"},
+		{item, "", "item", ""},
+	} {
+		if v := string(test.node.Prefix()); v != test.prefix {
+			t.Errorf("%s: expected prefix %q, found %q", test.node.Name, test.prefix, v)
+		}
+		if v := string(test.node.LocalName()); v != test.local {
+			t.Errorf("%s: expected local name %q, found %q", test.node.Name, test.local, v)
+		}
+		if v := string(test.node.NamespaceURI()); v != test.namespace {
+			t.Errorf("%s: expected namespace %q, found %q", test.node.Name, test.namespace, v)
+		}
+	}
+	if v := string(body.LookupNamespace(nil)); v != "urn:default" {
+		t.Errorf("expected default namespace %q, found %q", "urn:default", v)
+	}
+	attrs := append(body.GetAttributes(), item.GetAttributes()...)
+	attrs = append(attrs, envelope.GetAttributes()...)
+	for i, expected := range []struct{ prefix, local, namespace string }{
+		{"xml", "lang", XMLNamespace},
+		{"", "id", ""},
+		{"", "xmlns", XMLNSNamespace},
+		{"soap", "mustUnderstand", "http://www.w3.org/2003/05/soap-envelope"},
+		{"xmlns", "soap", XMLNSNamespace},
+		{"", "xmlns", XMLNSNamespace},
+	} {
+		a := attrs[i]
+		if v := string(a.Prefix()); v != expected.prefix {
+			t.Errorf("%s: expected prefix %q, found %q", a.Name, expected.prefix, v)
+		}
+		if v := string(a.LocalName()); v != expected.local {
+			t.Errorf("%s: expected local name %q, found %q", a.Name, expected.local, v)
+		}
+		if v := string(a.NamespaceURI()); v != expected.namespace {
+			t.Errorf("%s: expected namespace %q, found %q", a.Name, expected.namespace, v)
+		}
+	}
+}
+
+func TestStrictNamespaces(t *testing.T) {
+	for _, xml := range []string{
+		`<a:doc/>`,
+		`<doc a:x="1"/>`,
+		`<doc xmlns:a="urn:a" xmlns:b="urn:a" a:x="1" b:x="2"/>`,
+		`<a:b:doc xmlns:a="urn:a"/>`,
+		`<doc xmlns:="urn:a"/>`,
+		`<doc xmlns:a="urn:a"><a:x xmlns:a=""/></doc>`,
+		`<doc xmlns:xml="urn:a"/>`,
+		`<doc xmlns:x="http://www.w3.org/2000/xmlns/"/>`,
+		`<?a:b?><doc/>`,
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err != nil {
+			t.Errorf("%s: should not fail without strict namespaces: %v", xml, err)
+		}
+		r.StrictNamespaces = true
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package runxml
 
 // nodeArena is a preallokated memory regions; to increase speed by preventing
-// several sequential small allocations
-type nodeArena []GenericNode
+// several sequential small allocations. The slabs are kept for reuse after rewind.
+type nodeArena struct {
+	slabs [][]GenericNode
+	slab  int // Index of the current slab
+	next  int // Index of the next node in the current slab
+}
 
 // Memory allocation parameter.  Start with STARTSIZE and increase by 2x
 // until MAXSIZE
@@ -11,40 +15,125 @@ const (
 	startsize int = 100
 )
 
-var currentSize = startsize
+// slabSize returns the size of the slab following n slabs
+func slabSize(n int) int {
+	if n >= 8 {
+		return maxsize
+	}
+	return min(maxsize, startsize<<n)
+}
+
+// arenaMark is a position in an arena, which nodes allocated after it are rewound to
+type arenaMark struct {
+	slab int // Index of the slab
+	next int // Index of the next node in the slab
+}
+
+// start returns the index in slab i of the first node allocated after the mark
+func (m arenaMark) start(i int) int {
+	if i == m.slab {
+		return m.next
+	}
+	return 0
+}
 
 // get an GenericNode node from the arena
 func (na *nodeArena) get() *GenericNode {
+	for na.slab < len(na.slabs) && na.next == len(na.slabs[na.slab]) {
+		na.slab++
+		na.next = 0
+	}
 	// create new structs if empty
-	if len(*na) == 0 {
-		*na = make([]GenericNode, currentSize)
-		currentSize *= 2
-		currentSize = min(maxsize, currentSize)
-	}
-	n := &(*na)[len(*na)-1]
-	*na = (*na)[:len(*na)-1]
-	/*n := &(*na)[0] // possible optimization
-	*na = (*na)[1:]*/
+	if na.slab == len(na.slabs) {
+		na.slabs = append(na.slabs, make([]GenericNode, slabSize(len(na.slabs))))
+	}
+	n := &na.slabs[na.slab][na.next]
+	na.next++
 	return n
 }
 
-// attributeArena is a preallokated memory regions; to increase speed by preventing
-// several sequential small allocations
-type attributeArena []AttributeNode
+// used reports whether nodes have been allocated since the last rewind
+func (na *nodeArena) used() bool {
+	return na.slab > 0 || na.next > 0
+}
+
+// rewind clears the allocated nodes, and reuses them for the next allocations
+func (na *nodeArena) rewind() {
+	na.rewindTo(arenaMark{})
+}
+
+// mark returns the position of the next allocation, for rewindTo
+func (na *nodeArena) mark() arenaMark {
+	return arenaMark{na.slab, na.next}
+}
 
-var currAttrSize = startsize
+// rewindTo clears the nodes allocated since m, and reuses them for the next allocations
+func (na *nodeArena) rewindTo(m arenaMark) {
+	for i := m.slab; i < na.slab && i < len(na.slabs); i++ {
+		clear(na.slabs[i][m.start(i):])
+	}
+	if na.slab < len(na.slabs) {
+		clear(na.slabs[na.slab][m.start(na.slab):na.next])
+	}
+	na.slab, na.next = m.slab, m.next
+}
+
+// release drops the slabs, so the nodes of the next document are allocated
+// separately from the previous ones
+func (na *nodeArena) release() {
+	*na = nodeArena{}
+}
+
+// attributeArena is a preallokated memory regions; to increase speed by preventing
+// several sequential small allocations. The slabs are kept for reuse after rewind.
+type attributeArena struct {
+	slabs [][]AttributeNode
+	slab  int // Index of the current slab
+	next  int // Index of the next attribute in the current slab
+}
 
 // get an Attribute node from the arena
 func (aa *attributeArena) get() *AttributeNode {
-	//return &fake
-	if len(*aa) == 0 {
-		*aa = make([]AttributeNode, currAttrSize)
-		currAttrSize *= 2
-		currAttrSize = min(maxsize, currAttrSize)
-	}
-	n := &(*aa)[len(*aa)-1] // last elem
-	*aa = (*aa)[:len(*aa)-1]
-	//n := &(*aa)[0]
-	//*aa = (*aa)[1:]
-	return n
+	for aa.slab < len(aa.slabs) && aa.next == len(aa.slabs[aa.slab]) {
+		aa.slab++
+		aa.next = 0
+	}
+	if aa.slab == len(aa.slabs) {
+		aa.slabs = append(aa.slabs, make([]AttributeNode, slabSize(len(aa.slabs))))
+	}
+	a := &aa.slabs[aa.slab][aa.next]
+	aa.next++
+	return a
+}
+
+// used reports whether attributes have been allocated since the last rewind
+func (aa *attributeArena) used() bool {
+	return aa.slab > 0 || aa.next > 0
+}
+
+// rewind clears the allocated attributes, and reuses them for the next allocations
+func (aa *attributeArena) rewind() {
+	aa.rewindTo(arenaMark{})
+}
+
+// mark returns the position of the next allocation, for rewindTo
+func (aa *attributeArena) mark() arenaMark {
+	return arenaMark{aa.slab, aa.next}
+}
+
+// rewindTo clears the attributes allocated since m, and reuses them for the next allocations
+func (aa *attributeArena) rewindTo(m arenaMark) {
+	for i := m.slab; i < aa.slab && i < len(aa.slabs); i++ {
+		clear(aa.slabs[i][m.start(i):])
+	}
+	if aa.slab < len(aa.slabs) {
+		clear(aa.slabs[aa.slab][m.start(aa.slab):aa.next])
+	}
+	aa.slab, aa.next = m.slab, m.next
+}
+
+// release drops the slabs, so the attributes of the next document are allocated
+// separately from the previous ones
+func (aa *attributeArena) release() {
+	*aa = attributeArena{}
 }
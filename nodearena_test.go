@@ -0,0 +1,136 @@
+package runxml
+
+import (
+	"sync"
+	"testing"
+)
+
+// messagePayload is a representative message of a message bus
+var messagePayload = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:m="urn:example:orders">
+	<env:Header>
+		<m:MessageID>7d3c9a5e-1f0b-4c1e-9a0e-2f5c6d7e8f90</m:MessageID>
+		<m:Timestamp>2024-03-01T12:00:00Z</m:Timestamp>
+	</env:Header>
+	<env:Body>
+		<!-- order placed by the web shop -->
+		<m:Order id="10045" currency="EUR" priority="high">
+			<m:Customer ref="c-311">Smith &amp; Sons Ltd.</m:Customer>
+			<m:Line sku="A-100" qty="2" price="9.95"/>
+			<m:Line sku="B-220" qty="1" price="24.50"/>
+			<m:Line sku="C-003" qty="10" price="0.99"/>
+			<m:Note><![CDATA[Deliver <before> noon]]></m:Note>
+		</m:Order>
+	</env:Body>
+</env:Envelope>`)
+
+func TestReset(t *testing.T) {
+	buf := make([]byte, len(messagePayload))
+	r := NewDefaultRunXML()
+	parse := func() {
+		copy(buf, messagePayload)
+		doc, err := r.Parse(buf)
+		if err != nil {
+			t.Fatal("should not fail", err)
+		}
+		order := doc.GetLastChild().GetLastChild().GetLastChild()
+		if string(order.LocalName()) != "Order" || string(order.firstAttribute.Value) != "10045" {
+			t.Fatalf("unexpected element %s", order.Name)
+		}
+		r.Reset()
+	}
+	parse()
+	if allocs := testing.AllocsPerRun(100, parse); allocs > 0 {
+		t.Errorf("expected no allocations by the parser after Reset, found %v", allocs)
+	}
+	// Without Reset, the nodes of the documents are separate
+	first, _ := r.Parse([]byte(`<a/>`))
+	second, _ := r.Parse([]byte(`<b/>`))
+	if string(first.GetFirstChild().Name) != "a" || string(second.GetFirstChild().Name) != "b" {
+		t.Error("nodes of the first document were reused")
+	}
+}
+
+func TestArenaRewindTo(t *testing.T) {
+	var na nodeArena
+	na.get().Name = []byte("kept")
+	m := na.mark()
+	first := make([]*GenericNode, 1000)
+	for i := range first {
+		first[i] = na.get()
+		first[i].Name = []byte("released")
+	}
+	slabs := len(na.slabs)
+	for range 10 {
+		na.rewindTo(m)
+		for i := range first {
+			n := na.get()
+			if n != first[i] {
+				t.Fatal("expected the nodes after the mark to be reused")
+			}
+			if n.Name != nil {
+				t.Fatal("expected a reused node to be cleared")
+			}
+		}
+	}
+	if len(na.slabs) != slabs {
+		t.Errorf("expected %v slabs, found %v", slabs, len(na.slabs))
+	}
+	if string(na.slabs[0][0].Name) != "kept" {
+		t.Error("node before the mark was cleared")
+	}
+	var aa attributeArena
+	aa.get().Value = []byte("kept")
+	am := aa.mark()
+	a := aa.get()
+	a.Value = []byte("released")
+	aa.rewindTo(am)
+	if aa.get() != a || a.Value != nil || string(aa.slabs[0][0].Value) != "kept" {
+		t.Error("expected the attributes after the mark to be cleared and reused")
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	buf := make([]byte, len(messagePayload))
+	r := NewDefaultRunXML()
+	b.SetBytes(int64(len(messagePayload)))
+	b.ReportAllocs()
+	for b.Loop() {
+		copy(buf, messagePayload)
+		if _, err := r.Parse(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseReset(b *testing.B) {
+	buf := make([]byte, len(messagePayload))
+	r := NewDefaultRunXML()
+	b.SetBytes(int64(len(messagePayload)))
+	b.ReportAllocs()
+	for b.Loop() {
+		copy(buf, messagePayload)
+		if _, err := r.Parse(buf); err != nil {
+			b.Fatal(err)
+		}
+		r.Reset()
+	}
+}
+
+func BenchmarkParsePool(b *testing.B) {
+	pool := sync.Pool{New: func() any { return NewDefaultRunXML() }}
+	b.SetBytes(int64(len(messagePayload)))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, len(messagePayload))
+		for pb.Next() {
+			copy(buf, messagePayload)
+			r := pool.Get().(*RunXML)
+			if _, err := r.Parse(buf); err != nil {
+				b.Fatal(err)
+			}
+			r.Reset()
+			pool.Put(r)
+		}
+	})
+}
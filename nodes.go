@@ -9,6 +9,9 @@ package runxml
 
 import (
 	"fmt"
+	"iter"
+	"slices"
+	"strings"
 )
 
 // NodeType is the datatype descriping all possible node types
@@ -24,6 +27,7 @@ const (
 	Declaration                 //!< A declaration node. Name and value are empty. Declaration parameters (version, encoding and standalone) are in node attributes.
 	Doctype                     //!< A DOCTYPE node. Name is empty. Value contains DOCTYPE text.
 	Pi                          //!< A PI node. Name contains target. Value contains instructions.
+	EntityRef                   //!< A reference to an entity declared in the parts of the DTD that were not read. Name contains entity name. Value is empty.
 )
 
 // base contains the common fields of nodes
@@ -43,12 +47,11 @@ type GenericNode struct {
 	lastAttribute  *AttributeNode // pointer to last attribute node
 	prev           *GenericNode   // pointer to previous sibling of node
 	next           *GenericNode   // pointer to next sibling of node
+	dtd            *DTD           // document type definition of a document node
 }
 
-// Mempool for allocation
-var na nodeArena
-
-func newNode(nodeType NodeType) *GenericNode {
+// newNode allocates a node from the arena
+func (na *nodeArena) newNode(nodeType NodeType) *GenericNode {
 	n := na.get()
 	n.NodeType = nodeType
 	return n
@@ -159,28 +162,17 @@ func (g *GenericNode) String() string {
 
 // PrintChildren prints a representation of the node, including its children
 func (g *GenericNode) PrintChildren() {
-	var depthmap map[*GenericNode]int
-	depthmap = make(map[*GenericNode]int)
-
-	for n := range g.SendChildElements() {
-		if n.Parent == nil {
-			depthmap[n] = 0
-		}
-		var depth int
-		var ok bool
-		if depth, ok = depthmap[n]; !ok {
-			depthmap[n] = depthmap[n.Parent] + 1
-			depth = depthmap[n]
-		}
-		indent := ""
-		for i := 0; i < depth; i++ {
-			indent += "-"
+	for n := range g.Descendants() {
+		depth := 1
+		for a := n.Parent; a != g && a != nil; a = a.Parent {
+			depth++
 		}
+		indent := strings.Repeat("-", depth)
 		fmt.Print(indent)
 		fmt.Printf("NodeType: \"%v\" Name: \"%s\" Value: \"%s\" Parent: %p FirstNode: %p\n", n.NodeType, n.Name, n.Value, n.Parent, n.firstChild)
 		// Print Attributes
 		fmt.Print(indent)
-		for _, a := range n.GetAttributes() {
+		for a := range n.Attributes() {
 			fmt.Println(a)
 		}
 	}
@@ -189,64 +181,117 @@ func (g *GenericNode) PrintChildren() {
 // CountChildren returns the nodes number of childre. The node itself is not included.
 func (g *GenericNode) CountChildren() int {
 	count := 0
-	for range g.SendChildElements() {
+	for range g.Descendants() {
 		count++
 	}
 	return count
 }
 
+// Children returns an iterator over the direct children of the node, but not their
+// children. This is useful for breadth first parsing.
+func (g *GenericNode) Children() iter.Seq[*GenericNode] {
+	return func(yield func(*GenericNode) bool) {
+		for n := g.firstChild; n != nil; {
+			next := n.next // n may be removed by yield
+			if !yield(n) {
+				return
+			}
+			n = next
+		}
+	}
+}
+
+// Descendants returns an iterator over all nodes below the node in document order,
+// depth first. The node itself is not included.
+func (g *GenericNode) Descendants() iter.Seq[*GenericNode] {
+	return func(yield func(*GenericNode) bool) {
+		for n := g.firstChild; n != nil; {
+			if !yield(n) {
+				return
+			}
+			if n.firstChild != nil {
+				n = n.firstChild
+				continue
+			}
+			// no more children, look for the next sibling of the node or its ancestors
+			for n != g && n.next == nil {
+				n = n.Parent
+			}
+			if n == g {
+				return
+			}
+			n = n.next
+		}
+	}
+}
+
+// Ancestors returns an iterator over the parent of the node, its parent, and so on up to
+// the document node
+func (g *GenericNode) Ancestors() iter.Seq[*GenericNode] {
+	return func(yield func(*GenericNode) bool) {
+		for n := g.Parent; n != nil; n = n.Parent {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// FollowingSiblings returns an iterator over the siblings after the node
+func (g *GenericNode) FollowingSiblings() iter.Seq[*GenericNode] {
+	return func(yield func(*GenericNode) bool) {
+		for n := g.next; n != nil; n = n.next {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// Attributes returns an iterator over the attributes of the node
+func (g *GenericNode) Attributes() iter.Seq[*AttributeNode] {
+	return func(yield func(*AttributeNode) bool) {
+		for a := g.firstAttribute; a != nil; a = a.next {
+			if !yield(a) {
+				return
+			}
+		}
+	}
+}
+
 // SendCloseChildren returns a channel of pointers to  all direct children,
 // but not their children. This is useful for breadth first parsing
+//
+// Deprecated: Use Children, which does not start a goroutine that is left blocked
+// when the channel is not drained.
 func (g *GenericNode) SendCloseChildren() (ret chan *GenericNode) {
 	ret = make(chan *GenericNode, 8)
-	// traverse the siblings of the child
-	go func(ret chan<- *GenericNode) {
-		if g.firstChild == nil {
-			// no children
-			close(ret)
-			return
-		}
-		for n := g.firstChild; ; n = n.next {
+	go func() {
+		for n := range g.Children() {
 			ret <- n
-			// if it is the last node, break
-			if n == g.lastChild {
-				break
-			}
 		}
 		close(ret)
-	}(ret)
+	}()
 	return ret
 }
 
 // SendChildElements returns a channel of pointers to
 // all child elements of the node
+//
+// Deprecated: Use Descendants, which does not start a goroutine that is left blocked
+// when the channel is not drained.
 func (g *GenericNode) SendChildElements() (ret chan *GenericNode) {
 	if g == nil {
 		panic("node is nil")
 	}
-	if g.firstChild == nil {
-		close(ret)
-		return
-	}
 	ret = make(chan *GenericNode, 100)
-	var trav func(g *GenericNode)
-	trav = func(g *GenericNode) {
-		ret <- g // return yoursself
-		if g.firstChild != nil {
-			trav(g.firstChild)
-		}
-		// no more children, look for next sibling
-		if g.next != nil {
-			trav(g.next)
-		}
-		// no more siblings
-		return
-	}
 	go func() {
-		trav(g.firstChild)
+		for n := range g.Descendants() {
+			ret <- n
+		}
 		close(ret)
 	}()
-	return
+	return ret
 }
 
 // GetFirstChild returns the first child of the node,
@@ -275,6 +320,25 @@ func (g *GenericNode) GetPreviousSibling() *GenericNode {
 	return g.prev
 }
 
+// Text returns the text of the data and CDATA children of the node. The text of a single
+// child is returned without copying.
+func (g *GenericNode) Text() []byte {
+	var text []byte
+	n := 0
+	for c := g.firstChild; c != nil; c = c.next {
+		if c.NodeType != Data && c.NodeType != Cdata {
+			continue
+		}
+		if n == 0 {
+			text = c.Value
+		} else {
+			text = append(slices.Clip(text), c.Value...)
+		}
+		n++
+	}
+	return text
+}
+
 // GetAttributes returns a slice of pointers to the attributes of the node
 func (g *GenericNode) GetAttributes() []*AttributeNode {
 	retAttrrib := make([]*AttributeNode, 0, 10)
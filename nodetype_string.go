@@ -4,9 +4,9 @@ package runxml
 
 import "fmt"
 
-const _NodeType_name = "DocumentElementDataCdataCommentDeclarationDoctypePi"
+const _NodeType_name = "DocumentElementDataCdataCommentDeclarationDoctypePiEntityRef"
 
-var _NodeType_index = [...]uint8{0, 8, 15, 19, 24, 31, 42, 49, 51}
+var _NodeType_index = [...]uint8{0, 8, 15, 19, 24, 31, 42, 49, 51, 60}
 
 func (i NodeType) String() string {
 	if i < 0 || i >= NodeType(len(_NodeType_index)-1) {
@@ -1,76 +1,370 @@
 package runxml
 
-import "fmt"
-
-// Document    NodeType = iota //!< A document node. Name and value are empty.
-// 	Element                     //!< An element node. Name contains element name. Value contains text of first data node.
-// 	Data                        //!< A data node. Name is empty. Value contains data text.
-// 	Cdata                       //!< A CDATA node. Name is empty. Value contains data text.
-// 	Comment                     //!< A comment node. Name is empty. Value contains comment text.
-// 	Declaration                 //!< A declaration node. Name and value are empty. Declaration parameters (version, encoding and standalone) are in node attributes.
-// 	Doctype                     //!< A DOCTYPE node. Name is empty. Value contains DOCTYPE text.
-// 	Pi                          //!< A PI node. Name contains target. Value contains instructions.
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
 
 // PrintXML writes to stdout an XML representation of the node structure.
 func (g *GenericNode) PrintXML() {
-	p := printer{pretty: false}
-	p.printStructure(g)
+	g.WriteTo(os.Stdout)
 }
 
 // PrintXMLPretty writes to stdout an XML representation of the node structure and inserting
 // indenting and line breaking characters for prettier formatting
 func (g *GenericNode) PrintXMLPretty() {
-	p := printer{pretty: true}
-	p.printStructure(g) // Not implemented yet
+	pr := Printer{Indent: "  ", CollapseEmpty: true}
+	pr.Print(os.Stdout, g)
+}
+
+// Printer writes the XML representation of nodes with line breaks and indentation. Elements
+// containing text are written as they are, so whitespace of mixed content is preserved, as
+// is the content of elements with xml:space="preserve".
+type Printer struct {
+	Indent        string // Indentation of each level of elements
+	MaxWidth      int    // Line width beyond which a start tag has one attribute per line; 0 for no limit
+	CollapseEmpty bool   // Write elements without content as <a/> instead of <a></a>
+}
+
+// Print writes the node and its descendants to w, and returns the number of bytes written
+func (pr *Printer) Print(w io.Writer, g *GenericNode) (int64, error) {
+	p := pr.printer()
+	p.w = w
+	p.printStructure(g)
+	p.flush()
+	return p.n, p.err
+}
+
+// Append appends the node and its descendants to b
+func (pr *Printer) Append(b []byte, g *GenericNode) ([]byte, error) {
+	p := pr.printer()
+	p.buf = b
+	p.printStructure(g)
+	if p.err != nil {
+		return b, p.err
+	}
+	return p.buf, nil
+}
+
+func (pr *Printer) printer() printer {
+	return printer{pretty: true, indent: pr.Indent, maxWidth: pr.MaxWidth, collapseEmpty: pr.CollapseEmpty}
+}
+
+// WriteTo writes the XML representation of the node and its descendants to w, and returns
+// the number of bytes written. The output is UTF-8: an encoding declared by the XML
+// declaration is written as UTF-8. Text and attribute values are escaped, so parsing the
+// output results in the same nodes. Nodes which cannot be represented, such as comments
+// containing "--", return an error.
+func (g *GenericNode) WriteTo(w io.Writer) (int64, error) {
+	p := printer{w: w, collapseEmpty: true}
+	p.printStructure(g)
+	p.flush()
+	return p.n, p.err
+}
+
+// MarshalText returns the XML representation of the node and its descendants
+func (g *GenericNode) MarshalText() ([]byte, error) {
+	return g.AppendText(nil)
+}
+
+// AppendText appends the XML representation of the node and its descendants to b
+func (g *GenericNode) AppendText(b []byte) ([]byte, error) {
+	p := printer{buf: b, collapseEmpty: true}
+	p.printStructure(g)
+	if p.err != nil {
+		return b, p.err
+	}
+	return p.buf, nil
 }
 
 // printer holds variables for printer settings
 type printer struct {
-	pretty      bool
-	indentvalue int
-}
-
-// PrintXML writes a textual representation from children of g
-func (p *printer) printStructure(gn *GenericNode) {
-	// traverse siblings
-	for s := gn; s != nil; s = s.next {
-		switch s.NodeType {
-		case Declaration:
-			// print attributes
-		case Element:
+	w             io.Writer // Destination, or nil to keep the output in buf
+	buf           []byte
+	n             int64 // Bytes written to w
+	err           error
+	pretty        bool
+	indent        string
+	maxWidth      int
+	collapseEmpty bool
+	indentvalue   int  // Depth of the current node
+	preserve      bool // Whitespace of the current element is significant
+}
+
+// printBufferSize is the size at which the printer flushes its buffer to the writer
+const printBufferSize = 4096
+
+// printStructure writes a textual representation of the node
+func (p *printer) printStructure(s *GenericNode) {
+	if p.err != nil {
+		return
+	}
+	switch s.NodeType {
+	case Document:
+		for c := s.firstChild; c != nil; c = c.next {
+			p.printStructure(c)
 			if p.pretty {
-				fmt.Println("")
+				p.buf = append(p.buf, '\n')
+			}
+		}
+	case Declaration:
+		p.buf = append(p.buf, "<?xml"...)
+		for a := s.firstAttribute; a != nil; a = a.next {
+			value := a.Value
+			if string(a.Name) == "encoding" && !equalFoldAny(value, "utf-8", "utf8") {
+				value = []byte("UTF-8")
+			}
+			p.buf = append(p.buf, ' ')
+			p.printAttribute(a.Name, value)
+		}
+		p.buf = append(p.buf, "?>"...)
+	case Element:
+		if len(s.Name) == 0 {
+			p.err = fmt.Errorf("element without name")
+			return
+		}
+		p.printStartTag(s)
+		if s.firstChild == nil {
+			if p.collapseEmpty {
+				p.buf = append(p.buf, "/>"...)
+				break
+			}
+			p.buf = append(p.buf, '>')
+		} else {
+			p.buf = append(p.buf, '>')
+			p.printContent(s)
+		}
+		p.buf = append(p.buf, "</"...)
+		p.buf = append(p.buf, s.Name...)
+		p.buf = append(p.buf, '>')
+	case Data:
+		v := s.Value
+		if len(v) > 0 && len(bytes.TrimLeft(v, " \t\r\n")) == 0 {
+			// Text of whitespace only is dropped by the parser, unless written as a reference
+			p.buf = fmt.Appendf(p.buf, "&#x%X;", v[0])
+			v = v[1:]
+		}
+		p.buf = appendEscaped(p.buf, v, false)
+	case Cdata:
+		p.buf = AppendCDATA(p.buf, s.Value)
+	case Comment:
+		if bytes.Contains(s.Value, []byte("--")) || bytes.HasSuffix(s.Value, []byte("-")) {
+			p.err = fmt.Errorf("comment %q cannot contain \"--\" or end with '-'", s.Value)
+			return
+		}
+		p.buf = append(p.buf, "<!--"...)
+		p.buf = append(p.buf, s.Value...)
+		p.buf = append(p.buf, "-->"...)
+	case Doctype:
+		p.buf = append(p.buf, "<!DOCTYPE"...)
+		if len(s.Value) > 0 && lookupWhitespace[s.Value[0]] == 0 {
+			p.buf = append(p.buf, ' ')
+		}
+		p.buf = append(p.buf, s.Value...)
+		p.buf = append(p.buf, '>')
+	case EntityRef:
+		if len(s.Name) == 0 {
+			p.err = fmt.Errorf("entity reference without name")
+			return
+		}
+		p.buf = append(p.buf, '&')
+		p.buf = append(p.buf, s.Name...)
+		p.buf = append(p.buf, ';')
+	case Pi:
+		if len(s.Name) == 0 || bytes.Contains(s.Value, []byte("?>")) {
+			p.err = fmt.Errorf("processing instruction %q %q cannot be written", s.Name, s.Value)
+			return
+		}
+		p.buf = append(p.buf, "<?"...)
+		p.buf = append(p.buf, s.Name...)
+		if len(s.Value) > 0 {
+			p.buf = append(p.buf, ' ')
+			p.buf = append(p.buf, s.Value...)
+		}
+		p.buf = append(p.buf, "?>"...)
+	default:
+		p.err = fmt.Errorf("unknown node type %v", s.NodeType)
+	}
+	if p.w != nil && len(p.buf) >= printBufferSize {
+		p.flush()
+	}
+}
+
+// printStartTag writes the start tag of an element, without the closing '>' or "/>". Tags
+// wider than the maximum width have each attribute on a line of its own.
+func (p *printer) printStartTag(s *GenericNode) {
+	start := len(p.buf)
+	p.buf = append(p.buf, '<')
+	p.buf = append(p.buf, s.Name...)
+	for a := s.firstAttribute; a != nil; a = a.next {
+		p.buf = append(p.buf, ' ')
+		p.printAttribute(a.Name, a.Value)
+	}
+	if !p.pretty || p.maxWidth <= 0 || s.firstAttribute == nil {
+		return
+	}
+	width := p.indentvalue*utf8.RuneCountInString(p.indent) + utf8.RuneCount(p.buf[start:]) + 1
+	if s.firstChild == nil && p.collapseEmpty {
+		width++
+	}
+	if width <= p.maxWidth {
+		return
+	}
+	p.buf = p.buf[:start+1+len(s.Name)]
+	p.indentvalue++
+	for a := s.firstAttribute; a != nil; a = a.next {
+		p.newline()
+		p.printAttribute(a.Name, a.Value)
+	}
+	p.indentvalue--
+}
+
+// printContent writes the children of an element. Elements without text have each child on
+// an indented line of its own when printing pretty.
+func (p *printer) printContent(s *GenericNode) {
+	preserve := p.preserve
+	for a := s.firstAttribute; a != nil; a = a.next {
+		if string(a.Name) == "xml:space" {
+			p.preserve = string(a.Value) == "preserve"
+		}
+	}
+	indent := p.pretty && !p.preserve
+	for c := s.firstChild; c != nil && indent; c = c.next {
+		indent = c.NodeType != Data && c.NodeType != Cdata
+	}
+	p.indentvalue++
+	for c := s.firstChild; c != nil; c = c.next {
+		if indent {
+			p.newline()
+		}
+		p.printStructure(c)
+	}
+	p.indentvalue--
+	if indent {
+		p.newline()
+	}
+	p.preserve = preserve
+}
+
+// newline writes a line break and the indentation of the current depth
+func (p *printer) newline() {
+	p.buf = append(p.buf, '\n')
+	for range p.indentvalue {
+		p.buf = append(p.buf, p.indent...)
+	}
+}
+
+// printAttribute writes an attribute
+func (p *printer) printAttribute(name, value []byte) {
+	p.buf = append(p.buf, name...)
+	p.buf = append(p.buf, `="`...)
+	p.buf = appendEscaped(p.buf, value, true)
+	p.buf = append(p.buf, '"')
+}
+
+// flush writes the buffer to the writer
+func (p *printer) flush() {
+	if p.err == nil && len(p.buf) > 0 {
+		n, err := p.w.Write(p.buf)
+		p.n += int64(n)
+		p.err = err
+	}
+	p.buf = p.buf[:0]
+}
+
+// appendEscaped appends the text with the characters escaped that would otherwise be read as
+// markup, or be normalized by the parser. Attribute values also escape quotes and whitespace.
+func appendEscaped(b, text []byte, attribute bool) []byte {
+	last := 0
+	for i, c := range text {
+		if esc := escapeByte(c, attribute); esc != "" {
+			b = append(b, text[last:i]...)
+			b = append(b, esc...)
+			last = i + 1
+		}
+	}
+	return append(b, text[last:]...)
+}
+
+// escapeByte returns the escaped form of an ASCII character, or "" if it is written as it is
+func escapeByte(c byte, attribute bool) string {
+	switch c {
+	case '&':
+		return "&amp;"
+	case '<':
+		return "&lt;"
+	case '>':
+		return "&gt;"
+	case '\r':
+		return "&#xD;"
+	}
+	if !attribute {
+		return ""
+	}
+	switch c {
+	case '"':
+		return "&quot;"
+	case '\t':
+		return "&#x9;"
+	case '\n':
+		return "&#xA;"
+	}
+	return ""
+}
+
+// AppendEscaped appends the text escaped as character data, or as an attribute value, for
+// writing XML without a node structure. Characters not allowed in XML and invalid UTF-8 are
+// replaced by U+FFFD. Character data of whitespace only begins with a character reference, as
+// the parser drops whitespace between markup.
+func AppendEscaped[T ~string | ~[]byte](b []byte, text T, attribute bool) []byte {
+	if !attribute && len(text) > 0 {
+		space := true
+		for i := 0; i < len(text) && space; i++ {
+			space = text[i] == ' ' || text[i] == '\t' || text[i] == '\n' || text[i] == '\r'
+		}
+		if space {
+			b = fmt.Appendf(b, "&#x%X;", text[0])
+			text = text[1:]
+		}
+	}
+	last := 0
+	for i := 0; i < len(text); {
+		c, size := text[i], 1
+		esc := escapeByte(c, attribute)
+		if c >= utf8.RuneSelf {
+			var r rune
+			r, size = utf8.DecodeRuneInString(string(text[i:min(i+utf8.UTFMax, len(text))]))
+			if (r == utf8.RuneError && size == 1) || !isChar(r) {
+				esc = "\uFFFD"
 			}
-			// can have children and siblings which must be handled
-			fmt.Print("<" + string(s.Name) + ">")
-			p.traverseDepth(s)
-			fmt.Print("</" + string(s.Name) + ">")
-		case Data:
-			// just print and return
-			fmt.Print(string(s.Value))
-		case Cdata:
-			//  cdata needs to be embedded in a CDATA structure
-			fmt.Print(`<![CDATA[` + string(s.Value) + `]]`)
-		case Comment:
-			fmt.Print("<!--" + string(s.Value) + "-->")
-		case Doctype:
-			fmt.Print("<!DOCTYPE " + string(s.Value) + ">")
-			p.traverseDepth(s)
-		case Pi:
-			fmt.Print("<?" + string(s.Name) + " " + string(s.Value))
-		case Document:
-			p.traverseDepth(s)
-		default:
-			panic("unknown node type")
-		}
-
-	}
-
-}
-
-func (p *printer) traverseDepth(g *GenericNode) {
-	if g.firstChild != nil {
-		p.indentvalue++
-		p.printStructure(g.firstChild)
+		} else if esc == "" && !isChar(rune(c)) {
+			esc = "\uFFFD"
+		}
+		if esc != "" {
+			b = append(b, text[last:i]...)
+			b = append(b, esc...)
+			last = i + size
+		}
+		i += size
+	}
+	return append(b, text[last:]...)
+}
+
+// AppendCDATA appends the text as a CDATA section. Occurrences of "]]>", which would end the
+// section, are split across two sections.
+func AppendCDATA[T ~string | ~[]byte](b []byte, text T) []byte {
+	b = append(b, "<![CDATA["...)
+	last := 0
+	for i := 2; i < len(text); i++ {
+		if text[i] == '>' && text[i-1] == ']' && text[i-2] == ']' {
+			b = append(b, text[last:i]...)
+			b = append(b, "]]><![CDATA["...)
+			last = i
+		}
 	}
+	b = append(b, text[last:]...)
+	return append(b, "]]>"...)
 }
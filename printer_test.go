@@ -1,3 +1,238 @@
 package runxml
 
-// TODO
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	for _, test := range []struct {
+		xml, expected string
+	}{
+		{`<r/>`, `<r/>`},
+		{`<r></r>`, `<r/>`},
+		{`<?xml version="1.0" encoding="utf-8" standalone='yes'?><r/>`,
+			`<?xml version="1.0" encoding="utf-8" standalone="yes"?><r/>`},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><r>caf` + "\xe9" + `</r>`,
+			`<?xml version="1.0" encoding="UTF-8"?><r>café</r>`},
+		{`<r a='"x" &amp; &lt;y&gt;' b="'">1 &lt; 2 &amp;&amp; 3 &gt; 2 "'</r>`,
+			`<r a="&quot;x&quot; &amp; &lt;y&gt;" b="'">1 &lt; 2 &amp;&amp; 3 &gt; 2 "'</r>`},
+		{`<r a="&#9;&#10;&#13;">&#13;&#10;</r>`, `<r a="&#x9;&#xA;&#xD;">&#xD;` + "\n" + `</r>`},
+		{`<r><![CDATA[<&>]]></r>`, `<r><![CDATA[<&>]]></r>`},
+		{`<!--c--><r><!-- x --></r><!--d-->`, `<!--c--><r><!-- x --></r><!--d-->`},
+		{`<?pi?><r><?target some data ?></r>`, `<?pi?><r><?target some data ?></r>`},
+		{`<!DOCTYPE r [<!ENTITY e "&#38;#60;">]><r>&e;</r>`, `<!DOCTYPE r [<!ENTITY e "&#38;#60;">]><r>&lt;</r>`},
+		{`<p:r xmlns:p="urn:x"><p:a p:b="1">t<b/>u</p:a></p:r>`, `<p:r xmlns:p="urn:x"><p:a p:b="1">t<b/>u</p:a></p:r>`},
+	} {
+		doc, err := NewDefaultRunXML().Parse([]byte(test.xml))
+		if err != nil {
+			t.Error(test.xml, err)
+			continue
+		}
+		var buf bytes.Buffer
+		n, err := doc.WriteTo(&buf)
+		if err != nil {
+			t.Error(test.xml, err)
+		} else if buf.String() != test.expected {
+			t.Errorf("%v: expected %v, found %v", test.xml, test.expected, buf.String())
+		} else if n != int64(buf.Len()) {
+			t.Errorf("%v: expected %v bytes written, found %v", test.xml, buf.Len(), n)
+		}
+	}
+	// A single node and its descendants
+	doc, _ := NewDefaultRunXML().Parse([]byte(`<r><a x="1"><b>2</b></a><c/></r>`))
+	if b, err := doc.GetFirstChild().GetFirstChild().MarshalText(); err != nil || string(b) != `<a x="1"><b>2</b></a>` {
+		t.Errorf("unexpected text %q %v", b, err)
+	}
+	if b, err := doc.GetFirstChild().GetLastChild().AppendText([]byte("x")); err != nil || string(b) != `x<c/>` {
+		t.Errorf("unexpected text %q %v", b, err)
+	}
+}
+
+func TestWriteToConstructed(t *testing.T) {
+	r := &GenericNode{NodeType: Element, base: base{Name: []byte("r")}}
+	r.AppendNode(&GenericNode{NodeType: Cdata, base: base{Value: []byte("a]]>b")}})
+	b, err := r.MarshalText()
+	if err != nil || string(b) != `<r><![CDATA[a]]]]><![CDATA[>b]]></r>` {
+		t.Errorf("unexpected text %q %v", b, err)
+	}
+	for _, n := range []*GenericNode{
+		{NodeType: Comment, base: base{Value: []byte("a--b")}},
+		{NodeType: Comment, base: base{Value: []byte("a-")}},
+		{NodeType: Pi, base: base{Name: []byte("p"), Value: []byte("?>")}},
+		{NodeType: Pi},
+		{NodeType: Element},
+	} {
+		if _, err := n.MarshalText(); err == nil {
+			t.Errorf("%v: expected error", n)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	doc, err := NewDefaultRunXML().Parse([]byte(`<?xml version="1.0"?><!--c--><config>
+<server name="main" host="example.com" port="8080"><empty></empty><p>Some <b>bold</b> text </p>
+<pre xml:space="preserve"><a><b/></a></pre><list><item/><!-- x --><?pi y?></list></server></config>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		printer  Printer
+		expected string
+	}{
+		{Printer{Indent: "  "}, `<?xml version="1.0"?>
+<!--c-->
+<config>
+  <server name="main" host="example.com" port="8080">
+    <empty></empty>
+    <p>Some <b>bold</b> text </p>
+    <pre xml:space="preserve"><a><b></b></a></pre>
+    <list>
+      <item></item>
+      <!-- x -->
+      <?pi y?>
+    </list>
+  </server>
+</config>
+`},
+		{Printer{Indent: "\t", MaxWidth: 40, CollapseEmpty: true}, `<?xml version="1.0"?>
+<!--c-->
+<config>
+	<server
+		name="main"
+		host="example.com"
+		port="8080">
+		<empty/>
+		<p>Some <b>bold</b> text </p>
+		<pre xml:space="preserve"><a><b/></a></pre>
+		<list>
+			<item/>
+			<!-- x -->
+			<?pi y?>
+		</list>
+	</server>
+</config>
+`},
+	} {
+		var sb strings.Builder
+		n, err := test.printer.Print(&sb, doc)
+		if err != nil {
+			t.Error(err)
+		} else if sb.String() != test.expected {
+			t.Errorf("expected\n%v\nfound\n%v", test.expected, sb.String())
+		} else if n != int64(sb.Len()) {
+			t.Errorf("expected %v bytes written, found %v", sb.Len(), n)
+		}
+	}
+	// Nested elements within xml:space="default" are indented again
+	doc, _ = NewDefaultRunXML().Parse([]byte(`<a xml:space="preserve"><b xml:space="default"><c/></b></a>`))
+	pr := Printer{Indent: " ", CollapseEmpty: true}
+	if b, err := pr.Append(nil, doc.GetFirstChild()); err != nil || string(b) != "<a xml:space=\"preserve\"><b xml:space=\"default\">\n  <c/>\n </b></a>" {
+		t.Errorf("unexpected text %q %v", b, err)
+	}
+}
+
+func TestAppendEscaped(t *testing.T) {
+	for _, test := range []struct {
+		text      string
+		attribute bool
+		expected  string
+	}{
+		{`a<b>&"c"` + "\t\n\r", false, `a&lt;b&gt;&amp;"c"` + "\t\n&#xD;"},
+		{`a<b>&"c"` + "\t\n\r", true, `a&lt;b&gt;&amp;&quot;c&quot;&#x9;&#xA;&#xD;`},
+		{"x\x00y\x1b\xffz\uFFFE é😀", false, "x\uFFFDy\uFFFD\uFFFDz\uFFFD é😀"},
+		{" \n", false, "&#x20;\n"},
+		{" ", true, " "},
+		{"", false, ""},
+	} {
+		if s := string(AppendEscaped([]byte("-"), test.text, test.attribute)); s != "-"+test.expected {
+			t.Errorf("%q: expected %q, found %q", test.text, "-"+test.expected, s)
+		}
+		if s := string(AppendEscaped(nil, []byte(test.text), test.attribute)); s != test.expected {
+			t.Errorf("%q: expected %q, found %q", test.text, test.expected, s)
+		}
+	}
+	if s := string(AppendCDATA(nil, "]]>a]]]>>")); s != "<![CDATA[]]]]><![CDATA[>a]]]]]><![CDATA[>>]]>" {
+		t.Errorf("unexpected CDATA section %q", s)
+	}
+}
+
+// TestWriteToRoundTrip parses the output of WriteTo and Printer for the valid documents of
+// the conformance tests, and compares the nodes with those of the original document
+func TestWriteToRoundTrip(t *testing.T) {
+	pr := Printer{Indent: "\t", MaxWidth: 40}
+	numFiles := 0
+	for _, dir := range testDirs {
+		files, _ := filepath.Glob(dir.path)
+		for _, fn := range files {
+			if dir.exclusion[filepath.Base(fn)] {
+				continue
+			}
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewDefaultRunXML()
+			r.EntityResolver = NewFileResolver("xmltestfiles")
+			r.BaseURI = filepath.ToSlash(must(filepath.Abs(fn)))
+			doc, err := r.Parse(b)
+			if err != nil {
+				t.Fatal(fn, err)
+			}
+			mergeData(doc)
+			if decl := doc.GetFirstChild(); decl.NodeType == Declaration {
+				for a := range decl.Attributes() {
+					if string(a.Name) == "encoding" && !strings.EqualFold(string(a.Value), "utf-8") {
+						a.Value = []byte("UTF-8") // the output is UTF-8
+					}
+				}
+			}
+			var expected strings.Builder
+			writeTree(&expected, doc)
+			var buf bytes.Buffer
+			if _, err := doc.WriteTo(&buf); err != nil {
+				t.Error(fn, err)
+				continue
+			}
+			pretty, err := pr.Append(nil, doc)
+			if err != nil {
+				t.Error(fn, err)
+				continue
+			}
+			for _, output := range [][]byte{buf.Bytes(), pretty} {
+				text := string(output)
+				r2 := NewDefaultRunXML()
+				r2.EntityResolver = r.EntityResolver
+				r2.BaseURI = r.BaseURI
+				doc2, err := r2.Parse(output)
+				if err != nil {
+					t.Errorf("%v: %v\n%v", fn, err, text)
+					continue
+				}
+				var sb strings.Builder
+				writeTree(&sb, doc2)
+				if sb.String() != expected.String() {
+					t.Errorf("%v: written document differs:\n%v\n%v\n%v", fn, text, sb.String(), expected.String())
+				}
+			}
+			numFiles++
+		}
+	}
+	t.Log("Files tested", numFiles)
+}
+
+// mergeData joins adjacent data nodes, which are read as one node from the output of WriteTo
+func mergeData(n *GenericNode) {
+	for c := n.GetFirstChild(); c != nil; c = c.GetNextSibling() {
+		for c.NodeType == Data && c.next != nil && c.next.NodeType == Data {
+			c.Value = append(slices.Clip(c.Value), c.next.Value...)
+			n.RemoveNode(c.next)
+			n.Value = c.Value
+		}
+		mergeData(c)
+	}
+}
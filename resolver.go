@@ -0,0 +1,160 @@
+package runxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EntityResolver provides the contents of external entities and external DTD subsets.
+// The system identifier is as written in the document; relative identifiers are
+// relative to the base URI, the URI of the document or entity containing the declaration.
+type EntityResolver interface {
+	ResolveEntity(publicID, systemID, baseURI string) (io.ReadCloser, error)
+}
+
+// ErrEntityRefused is returned, possibly wrapped, by resolvers refusing access to an entity.
+// The parser continues without the entity, as a non-validating parser not reading it. The
+// same applies to entities that do not exist, reported by errors matching fs.ErrNotExist.
+var ErrEntityRefused = errors.New("access to external entity refused")
+
+// NoResolver refuses access to all external entities. It is used when RunXML has no EntityResolver.
+type NoResolver struct{}
+
+// ResolveEntity implements EntityResolver
+func (NoResolver) ResolveEntity(publicID, systemID, baseURI string) (io.ReadCloser, error) {
+	return nil, ErrEntityRefused
+}
+
+// FileResolver resolves external entities to files below a root directory. Relative
+// paths are relative to the root; files outside the root are refused.
+type FileResolver struct {
+	Root string // Root directory
+}
+
+// NewFileResolver returns a FileResolver rooted at dir
+func NewFileResolver(dir string) *FileResolver {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return &FileResolver{Root: dir}
+}
+
+// ResolveEntity implements EntityResolver
+func (f *FileResolver) ResolveEntity(publicID, systemID, baseURI string) (io.ReadCloser, error) {
+	u, err := url.Parse(resolveURI(baseURI, systemID))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" && u.Scheme != "file" || u.Host != "" {
+		return nil, fmt.Errorf("%w: %q is not a file", ErrEntityRefused, systemID)
+	}
+	path := filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.Root, path)
+	}
+	rel, err := filepath.Rel(f.Root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("%w: %q is outside of %v", ErrEntityRefused, systemID, f.Root)
+	}
+	return os.OpenInRoot(f.Root, rel)
+}
+
+// resolveURI resolves a system identifier against a base URI. A relative base gives a relative result.
+func resolveURI(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	resolved := b.ResolveReference(u)
+	if !b.IsAbs() && !strings.HasPrefix(b.Path, "/") && !strings.HasPrefix(u.Path, "/") {
+		resolved.Path = strings.TrimPrefix(resolved.Path, "/")
+	}
+	return resolved.String()
+}
+
+// readExternal reads an external entity through the entity resolver, and returns it in
+// UTF-8 without byte order mark and text declaration
+func (r *RunXML) readExternal(publicID, systemID, baseURI string) ([]byte, error) {
+	resolver := r.EntityResolver
+	if resolver == nil {
+		resolver = NoResolver{}
+	}
+	rc, err := resolver.ResolveEntity(publicID, systemID, baseURI)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read external entity %q: %w", systemID, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err == nil {
+		b, err = r.decodeDocument(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read external entity %q: %w", systemID, err)
+	}
+	n, err := textDeclEnd(b)
+	if err != nil {
+		return nil, fmt.Errorf("in external entity %q: %w", systemID, err)
+	}
+	return b[n:], nil
+}
+
+// entityUnavailable reports whether a resolver error means the entity is not read
+func entityUnavailable(err error) bool {
+	return errors.Is(err, ErrEntityRefused) || errors.Is(err, fs.ErrNotExist)
+}
+
+// textDeclEnd returns the length of the byte order mark and text declaration starting b.
+// A text declaration has an optional version and a required encoding.
+func textDeclEnd(b []byte) (int, error) {
+	i := 0
+	if bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}) {
+		i = 3
+	}
+	if !bytes.HasPrefix(b[i:], []byte("<?xml")) || len(b) <= i+5 || lookupWhitespace[b[i+5]] != 1 {
+		return i, nil
+	}
+	end := bytes.Index(b[i:], []byte("?>"))
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated text declaration")
+	}
+	decl := b[i+5 : i+end]
+	if bytes.Contains(decl, []byte("standalone")) {
+		return 0, fmt.Errorf("standalone not allowed in text declaration")
+	}
+	if !bytes.Contains(decl, []byte("encoding")) {
+		return 0, fmt.Errorf("missing encoding in text declaration")
+	}
+	return i + end + 2, nil
+}
+
+// loadEntity reads the replacement text of an external entity, unless already read.
+// Returns false if access to the entity is refused.
+func (r *RunXML) loadEntity(decl *EntityDecl) (bool, error) {
+	if decl.loaded {
+		return true, nil
+	}
+	text, err := r.readExternal(decl.PublicID, decl.SystemID, decl.base)
+	if entityUnavailable(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	decl.value = text
+	decl.loaded = true
+	return true, nil
+}
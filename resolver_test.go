@@ -0,0 +1,116 @@
+package runxml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const resolverTestDoc = `<!DOCTYPE doc PUBLIC "-//Example//DTD Doc//EN" "doc.dtd">
+<doc>&chapter;</doc>`
+
+// docText returns the text of the first data node in the document element
+func docText(t *testing.T, doc *GenericNode) string {
+	root := doc.GetLastChild()
+	for n := root.GetFirstChild(); n != nil; n = n.GetNextSibling() {
+		if n.NodeType == Data {
+			return string(n.Value)
+		}
+		if n.NodeType == Element && n.GetFirstChild() != nil {
+			return string(n.GetFirstChild().Value)
+		}
+	}
+	t.Fatal("no text in document element")
+	return ""
+}
+
+func TestNoResolver(t *testing.T) {
+	// The external subset is not read, so the entity may be declared there
+	r := NewDefaultRunXML()
+	doc, err := r.Parse([]byte(resolverTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if n := doc.GetLastChild().GetFirstChild(); n.NodeType != EntityRef || string(n.Name) != "chapter" {
+		t.Errorf("unexpected node %v %q", n.NodeType, n.Name)
+	}
+}
+
+func TestFileResolver(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"doc.xml":          resolverTestDoc,
+		"doc.dtd":          `<?xml encoding="UTF-8"?><!ENTITY % ents SYSTEM "ents/chapter.ent"> %ents;`,
+		"ents/chapter.ent": `<!ENTITY chapter SYSTEM "chapter.xml">`,
+		"ents/chapter.xml": `<?xml version="1.0" encoding="UTF-8"?><chapter>Chapter &#49;</chapter>`,
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewDefaultRunXML()
+	r.EntityResolver = NewFileResolver(dir)
+	doc, err := r.ParseFile(filepath.Join(dir, "doc.xml"))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if v := docText(t, doc); v != "Chapter 1" {
+		t.Errorf("unexpected text %q", v)
+	}
+	// Relative to the root when parsing data without base URI
+	doc, err = r.Parse([]byte(resolverTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if v := docText(t, doc); v != "Chapter 1" {
+		t.Errorf("unexpected text %q", v)
+	}
+	for _, systemID := range []string{"../doc.dtd", "/etc/passwd", "http://example.com/doc.dtd"} {
+		_, err := r.EntityResolver.ResolveEntity("", systemID, "")
+		if !errors.Is(err, ErrEntityRefused) {
+			t.Errorf("%v: expected access to be refused, found %v", systemID, err)
+		}
+	}
+}
+
+func TestCatalogResolver(t *testing.T) {
+	catalog := []byte(`<?xml version="1.0"?>
+<catalog xmlns="urn:oasis:names:tc:entity:xmlns:xml:catalog" prefer="public">
+	<public publicId="-//Example//DTD   Doc//EN" uri="http://example.com/dtd/doc.dtd"/>
+	<group xml:base="http://example.com/entities/">
+		<system systemId="chapter.xml" uri="chapter-1.xml"/>
+		<rewriteSystem systemIdStartString="urn:example:" rewritePrefix="urn/"/>
+	</group>
+</catalog>`)
+	entities := map[string][]byte{
+		"http://example.com/dtd/doc.dtd":            []byte(`<!ENTITY chapter SYSTEM "chapter.xml">`),
+		"http://example.com/entities/chapter-1.xml": []byte(`<chapter>Chapter 1</chapter>`),
+		"http://example.com/entities/urn/title":     []byte(`Title`),
+	}
+	c, err := NewCatalogResolver(catalog, entities)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	r := NewDefaultRunXML()
+	r.EntityResolver = c
+	doc, err := r.Parse([]byte(resolverTestDoc))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if v := docText(t, doc); v != "Chapter 1" {
+		t.Errorf("unexpected text %q", v)
+	}
+	doc, err = r.Parse([]byte(`<!DOCTYPE doc [ <!ENTITY title SYSTEM "urn:example:title"> ]><doc>&title;</doc>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if v := docText(t, doc); v != "Title" {
+		t.Errorf("unexpected text %q", v)
+	}
+	if _, err := c.ResolveEntity("", "other.dtd", ""); !errors.Is(err, ErrEntityRefused) {
+		t.Errorf("expected access to be refused, found %v", err)
+	}
+}
@@ -3,16 +3,46 @@ package runxml
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"path/filepath"
+	"unicode"
+	"unicode/utf8"
 )
 
 // RunXML is the parser instance that tracks the holds all state info
 type RunXML struct {
 	ValidateClosingTag bool
-	nodeArena          nodeArena      // Optimizing memory allocations
-	attributeArena     attributeArena // Optimizing memory allocations
-	data               []byte         // Data buffer
-	position           int            // Internal read position
+	MaxEntityExpansion int            // Maximum bytes of entity replacement text per document; 0 for default, negative for no limit
+	MaxEntityDepth     int            // Maximum nesting of entity references; 0 for default, negative for no limit
+	MaxEntityRatio     int            // Maximum entity replacement text per document byte; 0 for default, negative for no limit
+	EntityResolver     EntityResolver // Provides external entities and DTD subsets; nil refuses all external access
+	BaseURI            string         // URI of the document passed to Parse, for resolving external entities
+	StrictNamespaces   bool           // Report namespace errors, such as undeclared prefixes
+	PreserveWhitespace bool           // Keep text of whitespace only between markup, which is dropped by default
+
+	// CharsetReader, if non-nil, converts documents and external entities in encodings
+	// not supported by the parser to UTF-8, as the field of encoding/xml.Decoder
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+
+	nodeArena      nodeArena                // Optimizing memory allocations of the current document
+	attributeArena attributeArena           // Optimizing memory allocations of the current document
+	data           []byte                   // Data buffer
+	position       int                      // Internal read position
+	docSize        int                      // Size of the document being parsed, or read so far
+	input          io.Reader                // Unread part of the document passed to ParseReader
+	inputEOF       bool                     // The entire input has been read
+	emit           func(*GenericNode) error // Receives the children of the document element in ParseReaderFunc
+	stream         streamState              // Memory of the emitted children, reused by ParseReaderFunc
+	events         eventState               // Handler and reused nodes of ParseEvents and Tokenizer
+	baseURI        string                   // URI of the document or external entity being parsed
+	xml11          bool                     // Document declared version 1.1
+	standalone     bool                     // Document declared standalone="yes"
+	hasDoctype     bool                     // Document has a document type declaration
+	dtd            *DTD                     // Declarations of the document type declaration
+	expanded       int                      // Bytes of entity replacement text expanded so far
+	openEntities   []*EntityDecl            // Entities being expanded
+	included       []includedEntity         // Entities whose replacement text is being parsed as content
 	// Config settings
 }
 
@@ -29,18 +59,84 @@ func (r *RunXML) ParseFile(fn string) (*GenericNode, error) {
 	if err != nil {
 		return nil, err
 	}
-	return r.Parse(bs)
+	if abs, err := filepath.Abs(fn); err == nil {
+		fn = abs
+	}
+	return r.parse(bs, filepath.ToSlash(fn))
 }
 
 // Parse parses the entire byte slice.
 // Returns a pointer to GenericNode, representing the entire XML DOM-tree
 func (r *RunXML) Parse(b []byte) (*GenericNode, error) {
+	return r.parse(b, r.BaseURI)
+}
+
+// Reset returns the nodes and attributes of the previous document to the parser, which
+// reuses their memory for the next document. The previous document must not be used after
+// Reset. A RunXML that has been reset can be put in a sync.Pool, as it keeps no references
+// to the data of the previous document.
+func (r *RunXML) Reset() {
+	r.nodeArena.rewind()
+	r.attributeArena.rewind()
+	r.events.clear()
+	clear(r.openEntities[:cap(r.openEntities)])
+	clear(r.included[:cap(r.included)])
+	r.reset(r.BaseURI)
+}
+
+// parse parses a document with the given base URI
+func (r *RunXML) parse(b []byte, baseURI string) (*GenericNode, error) {
+	data, err := r.decodeDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	r.reset(baseURI)
+	r.data = data
+	r.docSize = len(b)
+	return r.parseDocument()
+}
+
+// reset clears the state of the previous document
+func (r *RunXML) reset(baseURI string) {
+	// The nodes of the previous document are owned by the caller, unless returned by Reset
+	if r.nodeArena.used() {
+		r.nodeArena.release()
+	}
+	if r.attributeArena.used() {
+		r.attributeArena.release()
+	}
 	r.position = 0
-	r.data = b
-	doc := newNode(Document)
-	// Skip possible BOM
-	r.skipBOM()
-	for r.position < len(r.data) {
+	r.baseURI = baseURI
+	r.data = nil
+	r.docSize = 0
+	r.input = nil
+	r.inputEOF = false
+	r.emit = nil
+	r.stream = streamState{}
+	r.events.handler = nil
+	r.events.compact = nil
+	r.events.reuse = false
+	r.events.depth = 0
+	r.events.used = 0
+	r.xml11 = false
+	r.standalone = false
+	r.hasDoctype = false
+	r.dtd = nil
+	r.expanded = 0
+	r.openEntities = r.openEntities[:0]
+	r.included = r.included[:0]
+}
+
+// parseDocument parses the nodes of the document
+func (r *RunXML) parseDocument() (*GenericNode, error) {
+	doc := r.newNode(Document)
+	for {
+		if err := r.fill(); err != nil {
+			return doc, err
+		}
+		if r.position >= len(r.data) {
+			break
+		}
 		// skip spaces
 		r.skip(lookupWhitespace)
 		if r.position == len(r.data)-1 {
@@ -49,11 +145,19 @@ func (r *RunXML) Parse(b []byte) (*GenericNode, error) {
 		c := r.getCurrentByte()
 		if c == '<' {
 			r.position++
-			node, err := r.parseNode()
+			node, err := r.parseNode(doc)
 			if err != nil {
 				return node, r.contextError(err)
 			}
-			doc.AppendNode(node)
+			if node != nil && node.NodeType == Cdata {
+				return doc, r.contextError(fmt.Errorf("CDATA section not allowed outside the document element"))
+			}
+			if err := r.appendNode(doc, node); err != nil {
+				return doc, r.contextError(err)
+			}
+			if node != nil && node.NodeType == Doctype {
+				doc.dtd = r.dtd
+			}
 		} else {
 			return doc, r.contextError(fmt.Errorf("expected '<', but found %q", rune(r.data[r.position])))
 		}
@@ -62,7 +166,7 @@ func (r *RunXML) Parse(b []byte) (*GenericNode, error) {
 }
 
 // parseNode is the highest level parsing method; expects position to be after a '<'
-func (r *RunXML) parseNode() (*GenericNode, error) {
+func (r *RunXML) parseNode(parent *GenericNode) (*GenericNode, error) {
 	//log.Println("parsing node at position", r.position, string(r.sliceForward(20)))
 	c := r.data[r.position]
 	switch c {
@@ -73,7 +177,7 @@ func (r *RunXML) parseNode() (*GenericNode, error) {
 		}
 		x := r.sliceFrom(r.position - 3)
 		//log.Println("PARSEX", string(x))
-		if bytes.Compare([]byte("xml"), bytes.ToLower(x)) == 0 &&
+		if bytes.EqualFold(x, []byte("xml")) &&
 			lookupWhitespace[r.getCurrentByte()] == 1 {
 			r.getNextByte() // skip to next byte
 			//log.Println("PARSE")
@@ -130,7 +234,7 @@ func (r *RunXML) parseNode() (*GenericNode, error) {
 		}
 	default:
 		// log.Println("Parselement")
-		return r.parseElement()
+		return r.parseElement(parent)
 	}
 
 	/*// skip undefined node types <!
@@ -148,7 +252,7 @@ func (r *RunXML) parseAttributes(element *GenericNode) error {
 		start := r.position
 		r.position++
 		r.skip(lookupAttributeName)
-		attrNode := r.attributeArena.get() // Fetch new node
+		attrNode := r.newAttribute() // Fetch new node
 		attrNode.Name = r.sliceFrom(start)
 		element.AppendAttribute(attrNode)
 
@@ -170,19 +274,23 @@ func (r *RunXML) parseAttributes(element *GenericNode) error {
 		// Extract attribute value, and expand char refs in it
 		start = r.position
 		var value []byte
+		var err error
 		if q == '\'' {
-			value = r.skipAndExpandCharacterRefs(lookupAttributeDataSQ, lookupAttributeDataSQPure)
+			value, err = r.skipAndExpandCharacterRefs(lookupAttributeDataSQ, lookupAttributeDataSQPure)
 		} else if q == '"' {
-			value = r.skipAndExpandCharacterRefs(lookupAttributeDataDQ, lookupAttributeDataDQPure)
+			value, err = r.skipAndExpandCharacterRefs(lookupAttributeDataDQ, lookupAttributeDataDQPure)
 		} else {
 			panic("should never happen")
 		}
-		if value == nil {
-			return fmt.Errorf("error parsing attribute value")
+		if err != nil {
+			return fmt.Errorf("error parsing attribute value: %w", err)
 		}
 		// Set attribute value
 		attrNode.Value = value
 		// Make sure end quote is present
+		if r.getCurrentByte() == '<' {
+			return fmt.Errorf("'<' not allowed in attribute value at position %v", r.position)
+		}
 		if r.getCurrentByte() != q {
 			return fmt.Errorf("expected %v as end quote", q)
 		}
@@ -194,14 +302,36 @@ func (r *RunXML) parseAttributes(element *GenericNode) error {
 }
 
 // parseElement parses element node
-func (r *RunXML) parseElement() (*GenericNode, error) {
+func (r *RunXML) parseElement(parent *GenericNode) (*GenericNode, error) {
+	currentElement, empty, err := r.parseStartTag(parent)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.startElement(currentElement); err != nil {
+		return nil, err
+	}
+	if !empty {
+		if err := r.parseNodeContents(currentElement); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.endElement(currentElement); err != nil {
+		return nil, err
+	}
+	return currentElement, nil
+}
+
+// parseStartTag parses the name and attributes of an element, and the end of its start tag.
+// Reports whether it is an empty-element tag.
+func (r *RunXML) parseStartTag(parent *GenericNode) (*GenericNode, bool, error) {
 	//fmt.Println("parse elem", r.position)
-	currentElement := newNode(Element)
+	currentElement := r.newElement()
+	currentElement.Parent = parent // for namespace lookups before the element is appended
 	// Extract element name
 	start := r.position
 	r.skip(lookupNodeName)
 	if start == r.position {
-		return nil, fmt.Errorf("error parsing node name")
+		return nil, false, fmt.Errorf("error parsing node name")
 	}
 	//log.Println("parse elem post lookup", r.position)
 	currentElement.Name = r.data[start:r.position]
@@ -213,128 +343,189 @@ func (r *RunXML) parseElement() (*GenericNode, error) {
 	// Parse attributes
 	err := r.parseAttributes(currentElement)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if r.dtd != nil {
+		r.defaultAttributes(currentElement)
+	}
+	if r.StrictNamespaces {
+		if err := r.checkNamespaces(currentElement); err != nil {
+			return nil, false, err
+		}
 	}
 
 	// Determine ending type
 	c := r.getCurrentByte()
 	if c == '>' {
 		r.position++
-		if err := r.parseNodeContents(currentElement); err != nil {
-			return nil, err
-		}
+		return currentElement, false, nil
 	} else if c == '/' {
 		if r.getNextByte() != '>' {
-			return nil, fmt.Errorf("expected '>' after '/' at position %v", r.position)
+			return nil, false, fmt.Errorf("expected '>' after '/' at position %v", r.position)
 		}
 		r.position++
-	} else {
-		return nil, fmt.Errorf("unknown end type error")
+		return currentElement, true, nil
 	}
-	return currentElement, nil
+	return nil, false, fmt.Errorf("unknown end type error")
 }
 
 // parseNodeContents Parse contents of the node - children elements, data etc.
 func (r *RunXML) parseNodeContents(cn *GenericNode) error {
 	// For all children and text
 	for {
-		contentStart := r.position
-		r.skip(lookupWhitespace)
-	AfterDataNode:
-		c := r.getCurrentByte()
-		// Determine what comes next: node closing, child node, data node, or 0?
-		switch c {
-		// New child node, closing, or data
-		case '<': // closing, child or error
-			if r.getNextByte() == '/' {
-				// Node closing
-				r.position++ // Skip to first char of closing tag
-				if r.ValidateClosingTag {
-					start := r.position
-					r.skip(lookupNodeName)
-					closeTag := r.sliceFrom(start)
-					if bytes.Compare(closeTag, cn.Name) != 0 {
-						return fmt.Errorf("unexpected closing tag %v", closeTag)
-					}
-				} else {
-					r.skip(lookupNodeName) // close regardless
-				}
-				r.skip(lookupWhitespace) // Skip remaining whitespace after nodename
-				if r.getCurrentByte() != '>' {
-					return fmt.Errorf("expected '>'")
-				}
-				r.position++ // Skip '>'
-				return nil
+		if err := r.emitChildren(cn); err != nil {
+			return err
+		}
+		if err := r.fill(); err != nil {
+			return err
+		}
+		kind, value, err := r.scanContent()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case contentText:
+			if err := r.appendDataNode(cn, value); err != nil {
+				return err
 			}
-			// Child node
-			//log.Println("child node")
-			child, err := r.parseNode()
+		case contentMarkup:
+			child, err := r.parseNode(cn)
 			if err != nil {
 				return err
 			}
-			if child != nil {
-				cn.AppendNode(child)
+			if child != nil && child.NodeType == Declaration {
+				return fmt.Errorf("XML declaration not allowed in content")
 			}
-
-		// Data node in node, create data node
-		default:
-			// this was a data node, so we shoudl reset r position to the start of
-			// the datanote to include all data in the node
-			r.position = contentStart
-			err := r.appendDataNode(cn)
+			if err := r.appendNode(cn, child); err != nil {
+				return err
+			}
+		case contentEndTag:
+			if err := r.parseEndTag(cn); err != nil {
+				return err
+			}
+			return r.emitChildren(cn)
+		case contentReference:
+			ref, err := r.includeEntity(cn)
 			if err != nil {
 				return err
 			}
-			goto AfterDataNode
+			if err := r.appendNode(cn, ref); err != nil {
+				return err
+			}
+		case contentEntityEnd:
+			if err := r.endEntity(cn); err != nil {
+				return err
+			}
 		}
 	}
 }
 
-// parseDocType returns the Doctype Node
+// contentKind is the kind of the next part of the content of an element
+type contentKind int
+
+// contentKind enum values
+const (
+	contentText      contentKind = iota // Text with references expanded, or whitespace kept by PreserveWhitespace
+	contentMarkup                       // A child node; position is after its '<'
+	contentEndTag                       // The end tag of the element; position is at its name
+	contentReference                    // A reference to an entity containing markup, or undeclared; position is at its '&'
+	contentEntityEnd                    // The end of the replacement text of the entity being included
+)
+
+// scanContent returns the kind of the next part of the content of an element, and the text
+// of contentText. Shared by parseNodeContents and Tokenizer.
+func (r *RunXML) scanContent() (contentKind, []byte, error) {
+	if r.position >= len(r.data) {
+		return 0, nil, fmt.Errorf("unexpected end of file")
+	}
+	contentStart := r.position
+	r.skip(lookupWhitespace)
+	entityEnd := len(r.included) > 0 && r.position == r.included[len(r.included)-1].end
+	switch r.getCurrentByte() {
+	case '<':
+		if r.PreserveWhitespace && r.position > contentStart {
+			return contentText, r.sliceFrom(contentStart), nil
+		}
+		if entityEnd {
+			return contentEntityEnd, nil, nil
+		}
+		if r.getNextByte() == '/' {
+			r.position++ // Skip to first char of closing tag
+			return contentEndTag, nil, nil
+		}
+		return contentMarkup, nil, nil
+	case 0:
+		return 0, nil, fmt.Errorf("unexpected NUL character at position %v", r.position)
+	}
+	// Text includes the whitespace before it
+	r.position = contentStart
+	value, err := r.skipAndExpandCharacterRefs(lookupText, lookupTextPureNoWS)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to append data node: %w", err)
+	}
+	switch {
+	case len(value) > 0:
+		return contentText, value, nil
+	case r.getCurrentByte() == '&':
+		return contentReference, nil, nil // data ended at a reference to an entity containing markup
+	}
+	return r.scanContent() // references expanded to nothing
+}
+
+// parseEndTag checks the end tag of element; expects position to be at its name and leaves it
+// after the '>'
+func (r *RunXML) parseEndTag(element *GenericNode) error {
+	if r.inEntity(element) {
+		return fmt.Errorf("end tag of element %q not allowed in replacement text of entity", element.Name)
+	}
+	if r.ValidateClosingTag {
+		start := r.position
+		r.skip(lookupNodeName)
+		closeTag := r.sliceFrom(start)
+		if bytes.Compare(closeTag, element.Name) != 0 {
+			return fmt.Errorf("unexpected closing tag %v", closeTag)
+		}
+	} else {
+		r.skip(lookupNodeName) // close regardless
+	}
+	r.skip(lookupWhitespace) // Skip remaining whitespace after nodename
+	if r.getCurrentByte() != '>' {
+		return fmt.Errorf("expected '>'")
+	}
+	r.position++ // Skip '>'
+	return nil
+}
+
+// parseDocType returns the Doctype Node. The declarations are available from the DTD of the document
 func (r *RunXML) parseDocType() (*GenericNode, error) {
+	if r.hasDoctype {
+		return nil, fmt.Errorf("duplicate DOCTYPE")
+	}
+	r.hasDoctype = true
 	start := r.position
-	// skip to > , we haven't closed the tag yet
-	// since doctype can contain other elements, it can be somewhat tricky to detect in an efficient
-	// way when it ends
-	for r.getCurrentByte() != '>' {
-		if r.getCurrentByte() == '[' { // beginning of elements
-			r.skipBytes(1) // skip the '['
-			//quoteType := '"'
-			for depth, insideElement := 1, false; depth > 0; {
-				switch r.getCurrentByte() {
-				case '[':
-					if !insideElement { // only count if not in a quote
-						depth++
-					}
-				case ']':
-					if !insideElement {
-						depth--
-					}
-				case '>':
-					insideElement = false
-				}
-				if bytes.HasPrefix(r.sliceToEnd(), []byte("<!")) {
-					insideElement = true
-				}
-				r.getNextByte()
-			}
-		} else {
-			err := r.skipBytes(1)
-			if err != nil {
-				return nil, err
-			}
-		}
+	dtd, err := r.parseDocTypeDecl()
+	if err != nil {
+		return nil, err
 	}
-	dt := newNode(Doctype)
+	r.dtd = dtd
+	dt := r.newNode(Doctype)
 	dt.Value = r.sliceFrom(start)
 	r.skipBytes(1)
 	return dt, nil
 }
 
 func (r *RunXML) parseXMLDeclaration() (*GenericNode, error) {
-	nd := newNode(Declaration)
+	nd := r.newNode(Declaration)
 	r.skip(lookupWhitespace)
 	r.parseAttributes(nd)
+	for a := nd.firstAttribute; a != nil; a = a.next {
+		switch string(a.Name) {
+		case "version":
+			r.xml11 = string(a.Value) == "1.1"
+		case "standalone":
+			r.standalone = string(a.Value) == "yes"
+		}
+	}
 	// expect closing tags after attributes
 	if !bytes.HasPrefix(r.sliceToEnd(), []byte("?>")) {
 		r.position += 2
@@ -351,8 +542,11 @@ func (r *RunXML) parsePI() (*GenericNode, error) {
 	if start == r.position {
 		return nil, fmt.Errorf("expected PI target")
 	}
-	pin := newNode(Pi)
+	pin := r.newNode(Pi)
 	pin.Name = r.sliceFrom(start)
+	if err := r.checkNCName(pin.Name); err != nil {
+		return nil, err
+	}
 	r.skip(lookupWhitespace)
 	start = r.position
 	// skip to ?>
@@ -367,12 +561,13 @@ func (r *RunXML) parsePI() (*GenericNode, error) {
 // parseCDATA creates a CDATA node
 func (r *RunXML) parseCDATA() (*GenericNode, error) {
 	start := r.position // expects after <![CDATA[
-	err := r.skipToChars([]byte("]]"))
+	err := r.skipToChars([]byte("]]>"))
 	if err != nil {
 		return nil, err
 	}
-	cd := newNode(Cdata)
+	cd := r.newNode(Cdata)
 	cd.Value = r.sliceFrom(start)
+	r.position += 3 // skip ]]>
 	return cd, nil
 }
 
@@ -393,7 +588,7 @@ func (r *RunXML) parseComment() (*GenericNode, error) {
 		// there is '--' inside comment; not allowed in specs.
 		return nil, fmt.Errorf("invalid '--' inside comment")
 	}
-	comment := newNode(Comment)
+	comment := r.newNode(Comment)
 	comment.Value = r.data[start : r.position-2]
 	//log.Printf("DEBUG: %#v\n", comment)
 	r.skipBytes(1)
@@ -465,32 +660,6 @@ func (r *RunXML) skip(table *[256]byte) {
 	r.position-- // lower position to not crash at end of data
 }
 
-func (r *RunXML) skipBOM() {
-	// UTF8
-	if bytes.HasPrefix(r.data, []byte{0xEF, 0xBB, 0xBF}) {
-		r.position += 3
-	} else if bytes.HasPrefix(r.data, []byte{0xFF, 0xFE}) {
-		//log.Println("warning, utf16le")
-		// UTF 16 LE
-		var err error
-		r.data, err = decodeUTF16(r.data)
-		if err != nil {
-			panic(err)
-		}
-		r.position += 3
-	} else if bytes.HasPrefix(r.data, []byte{0xFE, 0xFF}) {
-		//log.Println("warning, utf16be")
-		// UTF 16 BE
-		var err error
-		r.data, err = decodeUTF16(r.data)
-		if err != nil {
-			panic(err)
-		}
-		r.position += 3
-	}
-
-}
-
 func (r *RunXML) sliceFrom(start int) []byte {
 	return r.data[start:r.position]
 }
@@ -507,72 +676,187 @@ func (r *RunXML) sliceToEnd() []byte {
 }
 
 // skip and expand charaters is both used to parse attribute values and node data while expanding entities
-// since this function can overwrite the buffer, it returns a slice of the active area
-func (r *RunXML) skipAndExpandCharacterRefs(stopPred, stopPredPure *[256]byte) []byte {
+// since this function can overwrite the buffer, it returns a slice of the active area. When declared entities
+// expand to more than fits in the buffer, the value is returned in a newly allocated slice instead.
+// In node data, the expansion stops at a reference to an entity containing markup, or to an undeclared entity
+// kept as an EntityRef node, leaving position at its '&'. In attribute values, literal whitespace is normalized
+// to spaces, while whitespace from character references is kept.
+func (r *RunXML) skipAndExpandCharacterRefs(stopPred, stopPredPure *[256]byte) ([]byte, error) {
 	start := r.position
 	r.skip(stopPredPure) // fast path if no '&' is found
 	trail := r.position
+	inAttribute := stopPred != lookupText
+	var out []byte // used instead of the buffer once an expansion does not fit in-situ
+	var ch [utf8.UTFMax]byte
+Scan:
 	for c := r.getCurrentByte(); stopPred[c] == 1; {
 		if c == '&' {
-			c = r.getNextByte()
-			switch c {
-			// &amp; &apos;
-			case 'a':
-				if err := r.skipBytes(1); err == nil && bytes.HasPrefix(r.sliceToEnd(), []byte("mp;")) {
-					r.position += 2
-				} else if bytes.HasPrefix(r.sliceToEnd(), []byte("pos;")) {
-					r.data[trail] = '\\' // overwrite
-					r.position += 3
-				}
-			// &quot;
-			case 'q':
-				if err := r.skipBytes(1); err == nil && bytes.HasPrefix(r.sliceToEnd(), []byte("uot;")) {
-					r.position += 3
-				}
-			// &gt;
-			case 'g':
-				if err := r.skipBytes(1); err == nil && bytes.HasPrefix(r.sliceToEnd(), []byte("t;")) {
-					r.data[trail] = '>' // overwrite
-					r.position++
-				}
-			// &lt;
-			case 'l':
-				if err := r.skipBytes(1); err == nil && bytes.HasPrefix(r.sliceToEnd(), []byte("t;")) {
-					r.data[trail] = '<' // overwrite
-					r.position++
-				}
-			default: // in case we cant find any entity
-				trail++ // move after to r.position
+			ref := r.position
+			var value []byte
+			var markup bool
+			var err error
+			switch r.getNextByte() {
+			// &#...; &#x...;
+			case '#':
+				value, err = r.expandCharacterRef(ch[:0])
 			case 0:
-				panic("end of file")
+				err = fmt.Errorf("unexpected end of file in reference")
+			// &lt; &gt; &amp; &apos; &quot; and declared entities
+			default:
+				value, markup, err = r.expandEntityRef(inAttribute)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if markup {
+				r.position = ref
+				break Scan
+			}
+			if out == nil && len(value) <= r.position+1-trail {
+				trail += copy(r.data[trail:], value)
+			} else {
+				if out == nil {
+					out = make([]byte, trail-start, 2*(trail-start)+len(value))
+					copy(out, r.data[start:trail])
+				}
+				out = append(out, value...)
+			}
+		} else {
+			if inAttribute && lookupWhitespace[c] == 1 {
+				c = ' '
+			}
+			if out != nil {
+				out = append(out, c)
+			} else {
+				r.data[trail] = c
+				trail++
 			}
-			// &#...; - assumes ASCII -- not implemented
-		} else if trail < r.position { // if tail is lagging the position, we meed to copy
-			r.data[trail] = r.data[r.position]
 		}
 		if c = r.getNextByte(); c == 0 {
-			return nil // error
+			return nil, fmt.Errorf("unexpected end of file")
 		}
-		trail++
 	}
-	return r.data[start:trail]
+	if out != nil {
+		return out, nil
+	}
+	return r.data[start:trail], nil
 }
 
-// appendDataNode adds a data node to the parent node.
-func (r *RunXML) appendDataNode(parent *GenericNode) error {
-	value := r.skipAndExpandCharacterRefs(lookupText, lookupTextPureNoWS)
-	if value == nil {
-		return fmt.Errorf("unable to append data node")
+// expandEntityRef returns the value of an entity reference. Expects position to be at the first
+// character of the name and leaves it at the terminating ';'. References to declared entities
+// containing markup, and to undeclared entities that are not an error, are not expanded in node
+// data; instead markup is returned as true.
+func (r *RunXML) expandEntityRef(inAttribute bool) (value []byte, markup bool, err error) {
+	start := r.position - 1 // the '&'
+	name, n, err := refName(r.data[start:])
+	if err != nil {
+		return nil, false, fmt.Errorf("%v at position %v", err, start)
+	}
+	r.position = start + n - 1
+	if v := predefinedEntity(name); v != nil {
+		return v, false, nil
+	}
+	var decl *EntityDecl
+	if r.dtd != nil {
+		decl = r.dtd.Entities[string(name)]
 	}
-	node := newNode(Data)
+	switch {
+	case decl == nil:
+		// An attribute value cannot keep the reference apart from its text
+		if r.undeclaredEntityIsError() || inAttribute {
+			return nil, false, fmt.Errorf("reference to undeclared entity %q at position %v", name, start)
+		}
+		// The entity may be declared in the parts of the DTD that were not read;
+		// includeEntity keeps the reference as an EntityRef node
+		return nil, true, nil
+	case decl.Notation != "":
+		return nil, false, fmt.Errorf("reference to unparsed entity %q at position %v", name, start)
+	case r.standalone && decl.extDecl:
+		return nil, false, fmt.Errorf("reference to externally declared entity %q in standalone document at position %v", name, start)
+	case inAttribute && decl.IsExternal():
+		return nil, false, fmt.Errorf("reference to external entity %q in attribute value at position %v", name, start)
+	case !inAttribute && r.entityHasMarkup(decl):
+		return nil, true, nil
+	}
+	value, err = r.expandEntityText(decl, nil, inAttribute)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w at position %v", err, start)
+	}
+	return value, false, nil
+}
+
+// expandCharacterRef appends the UTF-8 encoding of a character reference to dst.
+// Expects position to be at the '#' and leaves it at the terminating ';'.
+func (r *RunXML) expandCharacterRef(dst []byte) ([]byte, error) {
+	start := r.position - 1 // the '&'
+	cp, n, err := r.decodeCharacterRef(r.data[start:])
+	if err != nil {
+		return nil, fmt.Errorf("%v at position %v", err, start)
+	}
+	r.position = start + n - 1
+	return utf8.AppendRune(dst, cp), nil
+}
+
+// decodeCharacterRef decodes the character reference (&#...; or &#x...;) at the
+// start of b. Returns the referenced character and the length of the reference.
+func (r *RunXML) decodeCharacterRef(b []byte) (rune, int, error) {
+	i := 2 // skip "&#"
+	base := rune(10)
+	if i < len(b) && b[i] == 'x' {
+		base = 16
+		i++
+	}
+	var cp rune
+	digits := 0
+	for ; i < len(b); i++ {
+		d := rune(lookupHexDigit[b[i]])
+		if d >= base {
+			break
+		}
+		if cp <= unicode.MaxRune {
+			cp = cp*base + d
+		}
+		digits++
+	}
+	if i >= len(b) || b[i] != ';' || digits == 0 {
+		return 0, 0, fmt.Errorf("malformed character reference")
+	}
+	if !isChar(cp) && !(r.xml11 && isRestrictedChar(cp)) {
+		return 0, 0, fmt.Errorf("character reference %q does not refer to a legal character", b[:i+1])
+	}
+	return cp, i + 1, nil
+}
+
+// isChar reports whether c is matched by the Char production of the XML 1.0 specification
+func isChar(c rune) bool {
+	return c == 0x09 || c == 0x0A || c == 0x0D ||
+		c >= 0x20 && c <= 0xD7FF ||
+		c >= 0xE000 && c <= 0xFFFD ||
+		c >= 0x10000 && c <= 0x10FFFF
+}
+
+// isRestrictedChar reports whether c is a control character that XML 1.1
+// allows, but only as a character reference
+func isRestrictedChar(c rune) bool {
+	return c >= 0x01 && c <= 0x1F || c >= 0x7F && c <= 0x9F
+}
+
+// appendDataNode adds a data node to the parent node.
+func (r *RunXML) appendDataNode(parent *GenericNode, value []byte) error {
+	node := r.newNode(Data)
 	node.Value = value
 	parent.Value = value
-	parent.AppendNode(node)
-	//fmt.Println("adding datanode", node, node.Name, node.Value, string(node.Parent.Name))
-	return nil
+	return r.appendNode(parent, node)
 }
 
 func (r *RunXML) contextError(v error) error {
+	// Errors in the replacement text of entities are reported at their references
+	for i := len(r.included) - 1; i >= 0; i-- {
+		e := r.included[i]
+		v = fmt.Errorf("in entity %q referenced at position %v: %w", e.decl.Name, e.start, v)
+		r.data, r.position = e.data, e.position
+	}
+	r.included = r.included[:0]
 	const contextSize = 40
 	start := max(r.position-contextSize, 0)
 	stop := min(r.position, len(r.data))
@@ -581,9 +865,9 @@ func (r *RunXML) contextError(v error) error {
 	stop = min(r.position+contextSize, len(r.data))
 	rightcontext := r.data[start:stop]
 	if r.position > len(r.data)-1 {
-		return fmt.Errorf("%v\n%v", v, string(leftcontext))
+		return fmt.Errorf("%w\n%v", v, string(leftcontext))
 	}
-	return fmt.Errorf("%v\n%v{%s}%v", v, string(leftcontext), string(r.getCurrentByte()),
+	return fmt.Errorf("%w\n%v{%s}%v", v, string(leftcontext), string(r.getCurrentByte()),
 		string(rightcontext))
 }
 
@@ -649,13 +933,13 @@ var lookupAttributeName = &[256]byte{
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
 }
 
-// Attribute data with single quote (anything but ' \0)
+// Attribute data with single quote (anything but ' < \0)
 var lookupAttributeDataSQ = &[256]byte{
 	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
 	0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
 	1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, // 2
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, // 3
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 5
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
@@ -670,13 +954,13 @@ var lookupAttributeDataSQ = &[256]byte{
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
 }
 
-// Attribute data with single quote that does not require processing (anything but ' \0 &)
+// Attribute data with single quote that does not require processing (anything but ' < \0 & \t \n \r)
 var lookupAttributeDataSQPure = &[256]byte{
 	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
-	0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, // 0
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
 	1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, // 2
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, // 3
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 5
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
@@ -691,13 +975,13 @@ var lookupAttributeDataSQPure = &[256]byte{
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
 }
 
-// Attribute data with single quote that does not require processing (anything but " \0)
+// Attribute data with double quote (anything but " < \0)
 var lookupAttributeDataDQ = &[256]byte{
 	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
 	0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
 	1, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 2
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, // 3
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 5
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
@@ -712,13 +996,13 @@ var lookupAttributeDataDQ = &[256]byte{
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
 }
 
-// Attribute data with double quote that does not require processing (anything but " \0 &)
+// Attribute data with double quote that does not require processing (anything but " < \0 & \t \n \r)
 var lookupAttributeDataDQPure = &[256]byte{
 	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
-	0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 0
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, // 0
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
 	1, 1, 0, 1, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 2
-	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, // 3
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 5
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
@@ -776,6 +1060,69 @@ var lookupText = &[256]byte{
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
 }
 
+// Entity name (anything but space \n \r \t " & ' ; < > \0)
+var lookupEntityName = &[256]byte{
+	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 1, 0, 1, 1, // 0
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 1
+	0, 1, 0, 1, 1, 1, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1, // 2
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 1, 0, 1, // 3
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 4
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 5
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 6
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 7
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 8
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // 9
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // A
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // B
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // C
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // D
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // E
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, // F
+}
+
+// Name characters that are not allowed as the first character of a name (- . 0-9)
+var lookupNameStartExclude = &[256]byte{
+	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 0
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 1
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, // 2
+	1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, // 3
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 4
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 5
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 6
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 7
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 8
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 9
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // A
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // B
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // C
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // D
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // E
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // F
+}
+
+// Value of hexadecimal digits; 0xFF for anything else
+var lookupHexDigit = &[256]byte{
+	// 0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 0
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 1
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 2
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 3
+	0xFF, 10, 11, 12, 13, 14, 15, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 4
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 5
+	0xFF, 10, 11, 12, 13, 14, 15, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 6
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 7
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 8
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // 9
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // A
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // B
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // C
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // D
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // E
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // F
+}
+
 func max(x, y int) int {
 	if x >= y {
 		return x
@@ -1,7 +1,11 @@
 package runxml
 
 import (
+	"fmt"
+	"iter"
 	"log"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -36,6 +40,43 @@ func TestHandelingOfSpaces(t *testing.T) {
 	}
 }
 
+func TestPreserveWhitespace(t *testing.T) {
+	xml := "<root>\n  <a> x </a> <b/><c>y</c>\n</root>"
+	r := NewDefaultRunXML()
+	r.PreserveWhitespace = true
+	doc, err := r.Parse([]byte(xml))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var s []string
+	for n := range doc.Descendants() {
+		s = append(s, fmt.Sprintf("%q", n.Name)+fmt.Sprintf("%q", n.Value))
+	}
+	expected := `"root""\n" """\n  " "a"" x " """ x " """ " "b""" "c""y" """y" """\n"`
+	if strings.Join(s, " ") != expected {
+		t.Errorf("expected %v, found %v", expected, strings.Join(s, " "))
+	}
+	r = NewDefaultRunXML()
+	r.PreserveWhitespace = true
+	tz, err := r.NewTokenizer([]byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text []string
+	for {
+		tok, err := tz.Next()
+		if err != nil {
+			break
+		}
+		if tok.Type == DataToken {
+			text = append(text, string(tok.Value))
+		}
+	}
+	if strings.Join(text, "|") != "\n  | x | |y|\n" {
+		t.Errorf("unexpected text tokens %q", text)
+	}
+}
+
 func TestSimpleXML2(t *testing.T) {
 	// wrong start of xml
 	xml := []byte(`<dogregister version="1"> <dog><name alive='false'>Fido</name></dog> 
@@ -78,6 +119,95 @@ func TestSendCloseChildren(t *testing.T) {
 	if count != 2 {
 		t.Error("wrong number of children")
 	}
+	// Childless nodes close the channel
+	for range doc.firstChild.firstChild.firstChild.firstChild.SendChildElements() {
+		t.Error("expected no nodes")
+	}
+}
+
+func TestIterators(t *testing.T) {
+	xml := []byte(`<r><a x="1" y="2">1</a>
+		<b><b2>77</b2><b3>33</b3></b>
+		<a>2</a></r>`)
+	doc, err := NewDefaultRunXML().Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	names := func(seq iter.Seq[*GenericNode]) string {
+		var s []string
+		for n := range seq {
+			if n.NodeType == Element {
+				s = append(s, string(n.Name))
+			} else {
+				s = append(s, string(n.Value))
+			}
+		}
+		return strings.Join(s, " ")
+	}
+	root := doc.GetFirstChild()
+	if s := names(root.Children()); s != "a b a" {
+		t.Errorf("unexpected children %q", s)
+	}
+	if s := names(doc.Descendants()); s != "r a 1 b b2 77 b3 33 a 2" {
+		t.Errorf("unexpected descendants %q", s)
+	}
+	b := root.GetFirstChild().GetNextSibling()
+	if s := names(b.Descendants()); s != "b2 77 b3 33" {
+		t.Errorf("unexpected descendants %q", s)
+	}
+	if s := names(b.GetFirstChild().GetFirstChild().Ancestors()); s != "b2 b r " {
+		t.Errorf("unexpected ancestors %q", s)
+	}
+	if s := names(root.GetFirstChild().FollowingSiblings()); s != "b a" {
+		t.Errorf("unexpected following siblings %q", s)
+	}
+	var attrs []string
+	for a := range root.GetFirstChild().Attributes() {
+		attrs = append(attrs, string(a.Name))
+	}
+	if strings.Join(attrs, " ") != "x y" {
+		t.Errorf("unexpected attributes %q", attrs)
+	}
+	// Iteration stops early
+	count := 0
+	for range doc.Descendants() {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("expected to stop after 3 nodes, found %v", count)
+	}
+	// Nodes may be removed while iterating over the children
+	for n := range root.Children() {
+		root.RemoveNode(n)
+	}
+	if root.GetFirstChild() != nil {
+		t.Error("expected all children to be removed")
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for range doc.Descendants() {
+		}
+		for range b.Children() {
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("expected no allocations, found %v", allocs)
+	}
+}
+
+func TestText(t *testing.T) {
+	doc, err := NewDefaultRunXML().Parse([]byte(`<r><a>1</a><b>x<![CDATA[<y>]]><c>z</c>&#65;</b><d/></r>`))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	for n, expected := range []string{"1", "x<y>A", ""} {
+		c, _ := doc.QuerySelector(fmt.Sprintf("r > :nth-child(%d)", n+1))
+		if text := string(c.Text()); text != expected {
+			t.Errorf("expected %q, found %q", expected, text)
+		}
+	}
 }
 
 func TestFirstChildAndSiblings(t *testing.T) {
@@ -124,3 +254,125 @@ func TestFirstChildAndSiblings(t *testing.T) {
 		t.Error("next sibling should be nil")
 	}
 }
+
+func TestCharacterReferences(t *testing.T) {
+	xml := []byte(`<r a="caf&#233; &#x1F600;">&#65;&#x42;&#x43; &#233;t&#xE9; &#x1F600;!</r>`)
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetFirstChild()
+	if v := string(root.GetFirstChild().Value); v != "ABC été 😀!" {
+		t.Errorf("expected character references to be expanded, found %q", v)
+	}
+	if v := string(root.GetAttributes()[0].Value); v != "café 😀" {
+		t.Errorf("expected character references in attribute to be expanded, found %q", v)
+	}
+}
+
+func TestIllegalCharacterReferences(t *testing.T) {
+	for _, xml := range []string{
+		`<r>&#0;</r>`,
+		`<r>&#x1;</r>`,
+		`<r>&#xD800;</r>`,
+		`<r>&#xFFFE;</r>`,
+		`<r>&#x110000;</r>`,
+		`<r>&#99999999999999999999;</r>`,
+		`<r>&#;</r>`,
+		`<r>&#x;</r>`,
+		`<r>&#12a;</r>`,
+		`<r a="&#65"></r>`,
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+	// Control characters are allowed as references in XML 1.1
+	r := NewDefaultRunXML()
+	if _, err := r.Parse([]byte(`<?xml version="1.1"?><r>&#x1;</r>`)); err != nil {
+		t.Error("should not fail", err)
+	}
+}
+
+func TestPredefinedEntities(t *testing.T) {
+	xml := []byte(`<r a='&lt;&gt;&amp;&apos;&quot;' b="x&amp;&amp;y">&lt;a href=&quot;x&quot;&gt;Tom&apos;s &amp; Jerry&apos;s&lt;/a&gt;</r>`)
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetFirstChild()
+	if v := string(root.GetFirstChild().Value); v != `<a href="x">Tom's & Jerry's</a>` {
+		t.Errorf("unexpected text value %q", v)
+	}
+	attrs := root.GetAttributes()
+	if v := string(attrs[0].Value); v != `<>&'"` {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := string(attrs[1].Value); v != `x&&y` {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+}
+
+func TestMalformedEntityReferences(t *testing.T) {
+	for _, xml := range []string{
+		`<r>&foo;</r>`,
+		`<r>&amp</r>`,
+		`<r>& </r>`,
+		`<r>&;</r>`,
+		`<r>&1a;</r>`,
+		`<r a="&foo;"/>`,
+		`<r a="&"/>`,
+		`<r a="&lt"/>`,
+	} {
+		r := NewDefaultRunXML()
+		if _, err := r.Parse([]byte(xml)); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
+
+func TestAttributeWhitespaceNormalization(t *testing.T) {
+	xml := []byte("<r a='x\ty\nz\rw' b=\"&#9;&#10;&#13;&lt;\tv\"/>")
+	r := NewDefaultRunXML()
+	doc, err := r.Parse(xml)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	attrs := doc.GetFirstChild().GetAttributes()
+	if v := string(attrs[0].Value); v != "x y z w" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+	if v := string(attrs[1].Value); v != "\t\n\r< v" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+}
+
+func TestConcurrentParse(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := NewDefaultRunXML()
+			for j := 0; j < 50; j++ {
+				xml := fmt.Appendf(nil, `<doc id="%d"><a x="1"/><b>%d</b></doc>`, i, j)
+				doc, err := r.Parse(xml)
+				if err != nil {
+					t.Error("should not fail", err)
+					return
+				}
+				root := doc.GetFirstChild()
+				if v := string(root.GetAttributes()[0].Value); v != fmt.Sprint(i) {
+					t.Errorf("expected id %v, found %v", i, v)
+				}
+				if v := string(root.GetLastChild().Value); v != fmt.Sprint(j) {
+					t.Errorf("expected value %v, found %v", j, v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
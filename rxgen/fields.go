@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// fieldMode is the XML construct a struct field is mapped to
+type fieldMode int
+
+const (
+	modeElement fieldMode = iota
+	modeAttr
+	modeCDATA
+	modeCharData
+	modeInnerXML
+	modeComment
+	modeAny
+)
+
+// field is a field of a struct mapped to XML
+type field struct {
+	name      string   // Selector of the field, through embedded structs
+	xmlName   string   // Name of the element or attribute
+	xmlns     string   // Namespace of the element or attribute
+	parents   []string // Elements enclosing the element, of a tag "a>b>c"
+	mode      fieldMode
+	omitEmpty bool
+	typ       *valueType
+	depth     int // Depth of the embedded struct declaring the field
+}
+
+// structInfo is the mapping of a struct to XML
+type structInfo struct {
+	fields   []field
+	xmlName  *field // XMLName field, which names the element
+	nameType bool   // The XMLName field is an xml.Name, recording the name of the element
+	skipped  bool   // Fields of unsupported types are left out
+}
+
+// structInfo returns the mapping of a struct to XML, which follows the rules of encoding/xml
+func (g *Generator) structInfo(decl *typeDecl) *structInfo {
+	if decl.info == nil {
+		decl.info = &structInfo{}
+		g.collectFields(decl, decl.info, decl.spec.Type.(*ast.StructType), "", 0)
+	}
+	return decl.info
+}
+
+// collectFields adds the fields of a struct, and those of its embedded structs, to info. The
+// selectors of the fields begin with prefix.
+func (g *Generator) collectFields(decl *typeDecl, info *structInfo, st *ast.StructType, prefix string, depth int) {
+	for _, f := range st.Fields.List {
+		var tags reflect.StructTag
+		if f.Tag != nil {
+			tags = reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+		}
+		tag := tags.Get("xml")
+		if tag == "-" {
+			continue
+		}
+		names := f.Names
+		if len(names) == 0 {
+			// The fields of embedded structs are promoted
+			t, pointer := f.Type, false
+			if star, ok := t.(*ast.StarExpr); ok {
+				t, pointer = star.X, true
+			}
+			var name string
+			switch t := t.(type) {
+			case *ast.Ident:
+				name = t.Name
+				if embedded := g.pkg.types[name]; embedded != nil {
+					if est, ok := embedded.spec.Type.(*ast.StructType); ok {
+						if pointer {
+							log.Printf("warning: %s: embedded pointer %s unsupported, fields skipped", decl.name, name)
+							info.skipped = true
+						} else {
+							g.collectFields(decl, info, est, prefix+name+".", depth+1)
+						}
+						continue
+					}
+				}
+			case *ast.SelectorExpr:
+				name = t.Sel.Name
+			}
+			names = []*ast.Ident{ast.NewIdent(name)}
+		}
+		for _, ident := range names {
+			if !ident.IsExported() {
+				continue
+			}
+			fd, err := parseTag(ident.Name, tag)
+			if err != nil {
+				log.Fatalf("%s.%s: %s", decl.name, ident.Name, err)
+			}
+			fd.name, fd.depth = prefix+ident.Name, depth
+			if ident.Name == "XMLName" {
+				if info.xmlName == nil || depth < info.xmlName.depth {
+					info.xmlName = &fd
+					info.nameType = types.ExprString(f.Type) == "xml.Name"
+				}
+				continue
+			}
+			fd.typ = g.resolve(f.Type)
+			if layout := tags.Get("layout"); layout != "" && !fd.typ.setLayout(layout) {
+				log.Fatalf("%s.%s: layout tag on a field of type %s", decl.name, ident.Name, fd.typ.name)
+			}
+			name, xmlns := g.xmlNameTag(fd.typ)
+			switch {
+			case fd.xmlName != "":
+				if fd.mode == modeElement && name != "" && name != fd.xmlName {
+					log.Fatalf("%s.%s: name %q in tag conflicts with name %q in %s.XMLName", decl.name, ident.Name, fd.xmlName, name, g.elementType(fd.typ).name)
+				}
+			case name != "":
+				fd.xmlName, fd.xmlns = name, xmlns
+			default:
+				fd.xmlName = ident.Name
+				if elem := g.elementType(fd.typ); elem != nil && elem.element != "" {
+					fd.xmlName = elem.element
+				}
+			}
+			if !fd.supported() {
+				if fd.mode == modeAny {
+					log.Printf("warning: %s.%s: option any unsupported, field skipped", decl.name, fd.name)
+				} else {
+					log.Printf("warning: %s.%s: unsupported type %s, field skipped", decl.name, fd.name, fd.typ.name)
+				}
+				info.skipped = true
+				continue
+			}
+			addField(decl, info, fd)
+		}
+	}
+}
+
+// parseTag interprets the xml tag of a field as encoding/xml does. The name is left empty
+// if the tag does not give one.
+func parseTag(fieldName, tag string) (field, error) {
+	var fd field
+	original := tag
+	if ns, t, ok := strings.Cut(tag, " "); ok {
+		fd.xmlns, tag = ns, t
+	}
+	tokens := strings.Split(tag, ",")
+	tag = tokens[0]
+	modes, anyFlag := 0, false
+	for _, flag := range tokens[1:] {
+		switch flag {
+		case "attr":
+			fd.mode = modeAttr
+		case "cdata":
+			fd.mode = modeCDATA
+		case "chardata":
+			fd.mode = modeCharData
+		case "innerxml":
+			fd.mode = modeInnerXML
+		case "comment":
+			fd.mode = modeComment
+		case "any":
+			anyFlag = true
+			continue
+		case "omitempty":
+			fd.omitEmpty = true
+			continue
+		default:
+			continue
+		}
+		modes++
+	}
+	special := modes > 0 || anyFlag
+	if modes > 1 || special && (fieldName == "XMLName" || tag != "" && fd.mode != modeAttr) ||
+		fd.omitEmpty && fd.mode != modeElement && fd.mode != modeAttr {
+		return fd, fmt.Errorf("invalid tag %q", original)
+	}
+	if anyFlag {
+		fd.mode = modeAny
+	}
+	if fd.xmlns != "" && tag == "" {
+		return fd, fmt.Errorf("namespace without name in tag %q", original)
+	}
+	if fieldName == "XMLName" || tag == "" {
+		fd.xmlName = tag
+		return fd, nil
+	}
+	parents := strings.Split(tag, ">")
+	if parents[0] == "" {
+		parents[0] = fieldName
+	}
+	if parents[len(parents)-1] == "" {
+		return fd, fmt.Errorf("trailing '>' in tag %q", original)
+	}
+	fd.xmlName = parents[len(parents)-1]
+	if len(parents) > 1 {
+		if fd.mode != modeElement {
+			return fd, fmt.Errorf("%s chain not valid with %s flag", tag, strings.Join(tokens[1:], ","))
+		}
+		fd.parents = parents[:len(parents)-1]
+	}
+	return fd, nil
+}
+
+// supported reports whether the type of the field can be mapped to its XML construct
+func (f *field) supported() bool {
+	t := f.typ
+	switch f.mode {
+	case modeElement:
+		return t.kind != kindUnsupported
+	case modeAttr:
+		if t.kind == kindPointer {
+			t = t.elem
+		}
+		return t.isScalar()
+	case modeCDATA, modeCharData:
+		return t.isScalar()
+	case modeInnerXML, modeComment:
+		return t.kind == kindString || t.kind == kindBytes
+	}
+	return false
+}
+
+// addField adds a field to the struct. Of fields which conflict by matching the same XML,
+// the one embedded at the least depth is kept, and those of equal depth are an error.
+func addField(decl *typeDecl, info *structInfo, f field) {
+	var conflicts []int
+Fields:
+	for i, old := range info.fields {
+		if old.mode != f.mode || old.xmlns != "" && f.xmlns != "" && old.xmlns != f.xmlns {
+			continue
+		}
+		for p := range min(len(old.parents), len(f.parents)) {
+			if old.parents[p] != f.parents[p] {
+				continue Fields
+			}
+		}
+		switch {
+		case len(old.parents) > len(f.parents):
+			if old.parents[len(f.parents)] != f.xmlName {
+				continue
+			}
+		case len(old.parents) < len(f.parents):
+			if f.parents[len(old.parents)] != old.xmlName {
+				continue
+			}
+		case f.xmlName != old.xmlName || f.xmlns != old.xmlns:
+			continue
+		}
+		conflicts = append(conflicts, i)
+	}
+	for _, i := range conflicts {
+		if info.fields[i].depth < f.depth {
+			return
+		}
+	}
+	for _, i := range conflicts {
+		if old := info.fields[i]; old.depth == f.depth {
+			log.Fatalf("%s: fields %s and %s have conflicting names", decl.name, old.name, f.name)
+		}
+	}
+	for _, i := range slices.Backward(conflicts) {
+		info.fields = slices.Delete(info.fields, i, i+1)
+	}
+	info.fields = append(info.fields, f)
+}
+
+// xmlNameTag returns the name and namespace given by the tag of the XMLName field of a
+// struct type, or of the structs of a slice or pointer type
+func (g *Generator) xmlNameTag(t *valueType) (name, xmlns string) {
+	decl := g.elementType(t)
+	if decl == nil {
+		return "", ""
+	}
+	for _, f := range decl.spec.Type.(*ast.StructType).Fields.List {
+		for _, ident := range f.Names {
+			if ident.Name != "XMLName" {
+				continue
+			}
+			var tag string
+			if f.Tag != nil {
+				tag = reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("xml")
+			}
+			if fd, err := parseTag("XMLName", tag); err == nil {
+				return fd.xmlName, fd.xmlns
+			}
+			return "", ""
+		}
+	}
+	return "", ""
+}
+
+// elementName returns the name and namespace of the element of values of a declared type:
+// those of the tag of its XMLName field, or the name given by its //runxml: directive, or
+// the name of the type
+func (g *Generator) elementName(decl *typeDecl) (name, xmlns string) {
+	if _, ok := decl.spec.Type.(*ast.StructType); ok {
+		if xn := g.structInfo(decl).xmlName; xn != nil && xn.xmlName != "" {
+			return xn.xmlName, xn.xmlns
+		}
+	}
+	if decl.element != "" {
+		return decl.element, ""
+	}
+	return decl.name, ""
+}
@@ -2,14 +2,19 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"go/ast"
 	"go/build"
 	"go/format"
+	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
 	"log"
+	"maps"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -26,18 +31,27 @@ type File struct {
 }
 
 type Package struct {
-	dir      string
-	name     string
-	defs     map[*ast.Ident]types.Object
-	files    []*File
-	typesPkg *types.Package
+	dir        string
+	name       string
+	defs       map[*ast.Ident]types.Object
+	files      []*File
+	typesPkg   *types.Package
+	typesInfo  *types.Info
+	types      map[string]*typeDecl       // Type declarations by name
+	methods    map[string]map[string]bool // Names of declared methods by receiver type
+	funcs      []*ast.FuncDecl            // Functions with a //runxml:converter directive
+	converters map[string]*converter      // Converters by the qualified name of their type
 }
 
 // Generator holds the state of the analysis. Primarily used to buffer
 // the output for format.Source.
 type Generator struct {
-	buf bytes.Buffer // Accumulated output.
-	pkg *Package     // Package we are scanning.
+	buf     bytes.Buffer    // Accumulated output.
+	pkg     *Package        // Package we are scanning.
+	output  string          // Name of the generated file, which is not scanned for methods
+	imports map[string]bool // Packages used by the generated code
+	queue   []string        // Types to generate
+	queued  map[string]bool
 
 	trimPrefix  string
 	lineComment bool
@@ -62,7 +76,7 @@ func (g *Generator) parsePackage(directory string, names []string, text interfac
 	g.pkg = new(Package)
 	fs := token.NewFileSet()
 	for _, name := range names {
-		if !strings.HasSuffix(name, ".go") {
+		if !strings.HasSuffix(name, ".go") || filepath.Clean(name) == filepath.Clean(g.output) {
 			continue
 		}
 		parsedFile, err := parser.ParseFile(fs, name, text, parser.ParseComments)
@@ -83,7 +97,41 @@ func (g *Generator) parsePackage(directory string, names []string, text interfac
 	g.pkg.name = astFiles[0].Name.Name
 	g.pkg.files = files
 	g.pkg.dir = directory
-	//g.pkg.typeCheck(fs, astFiles)
+	g.pkg.collectDecls(astFiles)
+	g.pkg.typeCheck(fs, astFiles)
+	g.pkg.collectConverters()
+	g.checkUnions()
+}
+
+// typeCheck records the types of the package. Errors are ignored, as the package may use the
+// methods of the generated file, which is not parsed.
+func (pkg *Package) typeCheck(fs *token.FileSet, astFiles []*ast.File) {
+	pkg.defs = make(map[*ast.Ident]types.Object)
+	pkg.typesInfo = &types.Info{
+		Defs:  pkg.defs,
+		Uses:  map[*ast.Ident]types.Object{},
+		Types: map[ast.Expr]types.TypeAndValue{},
+	}
+	config := types.Config{
+		Importer:    importer.ForCompiler(fs, "source", nil),
+		FakeImportC: true,
+		Error:       func(error) {},
+	}
+	pkg.typesPkg, _ = config.Check(pkg.dir, fs, astFiles, pkg.typesInfo)
+}
+
+// typeOf returns the type of an expression, or of a type declared in the package by name
+func (pkg *Package) typeOf(expr ast.Expr) types.Type {
+	t := pkg.typesInfo.TypeOf(expr)
+	if id, ok := expr.(*ast.Ident); ok && t == nil && pkg.typesPkg != nil {
+		if obj := pkg.typesPkg.Scope().Lookup(id.Name); obj != nil {
+			t = obj.Type()
+		}
+	}
+	if t == types.Typ[types.Invalid] {
+		return nil
+	}
+	return t
 }
 
 // parsePackageDir parses the package residing in the directory.
@@ -117,6 +165,476 @@ func (g *Generator) format() []byte {
 	return src
 }
 
+// generate writes the methods of the type, and of the types of its fields which are declared
+// in the package
 func (g *Generator) generate(typeName string) {
-	//stub
+	g.enqueue(typeName)
+	for len(g.queue) > 0 {
+		name := g.queue[0]
+		g.queue = g.queue[1:]
+		decl := g.pkg.types[name]
+		if decl == nil {
+			log.Fatalf("type %s not found in package %s", name, g.pkg.name)
+		}
+		if !g.pkg.methods[name]["UnmarshalRunXML"] {
+			g.generateUnmarshal(decl)
+		}
+		g.generateMarshal(decl)
+	}
+}
+
+// enqueue adds a type to the types to generate, unless it has been added before
+func (g *Generator) enqueue(typeName string) {
+	if g.queued == nil {
+		g.queued = map[string]bool{}
+	}
+	if !g.queued[typeName] {
+		g.queued[typeName] = true
+		g.queue = append(g.queue, typeName)
+	}
+}
+
+// addImport records a package used by the generated code
+func (g *Generator) addImport(path string) {
+	if g.imports == nil {
+		g.imports = map[string]bool{}
+	}
+	g.imports[path] = true
+}
+
+// header returns the header, package clause and imports of the generated file
+func (g *Generator) header(args []string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"rxgen %s\"; DO NOT EDIT.\n\n", strings.Join(args, " "))
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg.name)
+	// Standard library packages precede the others
+	thirdParty := func(path string) bool {
+		first, _, _ := strings.Cut(path, "/")
+		return strings.Contains(first, ".")
+	}
+	paths := slices.SortedFunc(maps.Keys(g.imports), func(a, b string) int {
+		if thirdParty(a) != thirdParty(b) {
+			if thirdParty(a) {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a, b)
+	})
+	fmt.Fprintf(&b, "import (\n")
+	for i, path := range paths {
+		if i > 0 && thirdParty(path) && !thirdParty(paths[i-1]) {
+			fmt.Fprintf(&b, "\n")
+		}
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	fmt.Fprintf(&b, ")\n")
+	return b.Bytes()
+}
+
+// typeDecl is a type declared in the package
+type typeDecl struct {
+	name    string
+	spec    *ast.TypeSpec
+	element string      // Element name given by a //runxml: directive
+	info    *structInfo // Mapping of a struct to XML, once it is determined
+	impls   *union      // Implementations of an interface given by //runxml: directives
+}
+
+// union returns the implementations of an interface type, creating them
+func (decl *typeDecl) union() *union {
+	if decl.impls == nil {
+		decl.impls = &union{discriminator: "element"}
+	}
+	return decl.impls
+}
+
+// union is the implementations of an interface, given by //runxml:implementation
+// directives, and the discriminator telling them apart in XML, given by a
+// //runxml:discriminator directive: the element name, or a type or xsi:type attribute
+type union struct {
+	discriminator string
+	impls         []implementation
+}
+
+// implementation is a type implementing an interface
+type implementation struct {
+	typ   string // Type as written: a struct declared in the package, or a pointer to one
+	value string // Element name or value of the attribute of the type
+}
+
+// name returns the name of the struct type of the implementation
+func (impl implementation) name() string {
+	return strings.TrimPrefix(impl.typ, "*")
+}
+
+// xsiURL is the namespace of the xsi:type attribute
+const xsiURL = "http://www.w3.org/2001/XMLSchema-instance"
+
+// checkUnions checks the directives of the interfaces with implementations
+func (g *Generator) checkUnions() {
+	for _, decl := range g.pkg.types {
+		u := decl.impls
+		if u == nil {
+			continue
+		}
+		if _, ok := decl.spec.Type.(*ast.InterfaceType); !ok {
+			log.Fatalf("type %s with implementations is not an interface", decl.name)
+		}
+		switch u.discriminator {
+		case "element", "type", "xsi:type":
+		default:
+			log.Fatalf("type %s: discriminator must be element, type or xsi:type, not %s", decl.name, u.discriminator)
+		}
+		if len(u.impls) == 0 {
+			log.Fatalf("type %s has no implementations", decl.name)
+		}
+		for i, impl := range u.impls {
+			elem := g.pkg.types[impl.name()]
+			if elem == nil {
+				log.Fatalf("type %s: implementation %s not found in package %s", decl.name, impl.name(), g.pkg.name)
+			}
+			if _, ok := elem.spec.Type.(*ast.StructType); !ok {
+				log.Fatalf("type %s: implementation %s is not a struct", decl.name, impl.name())
+			}
+			for _, other := range u.impls[:i] {
+				if other.value == impl.value || other.name() == impl.name() {
+					log.Fatalf("type %s: implementations %s and %s are not distinct", decl.name, other.typ, impl.typ)
+				}
+			}
+			if name, _ := g.xmlNameTag(g.resolve(ast.NewIdent(impl.name()))); name != "" && u.discriminator == "element" && name != impl.value {
+				log.Fatalf("type %s: implementation %s is named %s by its XMLName tag", decl.name, impl.typ, name)
+			}
+			ifaceType, implType := g.pkg.typeOf(ast.NewIdent(decl.name)), g.pkg.typeOf(ast.NewIdent(impl.name()))
+			if ifaceType == nil || implType == nil {
+				continue
+			}
+			if strings.HasPrefix(impl.typ, "*") {
+				implType = types.NewPointer(implType)
+			}
+			if iface, ok := ifaceType.Underlying().(*types.Interface); ok && !types.Implements(implType, iface) {
+				log.Fatalf("type %s: %s does not implement it", decl.name, impl.typ)
+			}
+		}
+	}
+}
+
+// collectDecls records the type declarations and methods of the package
+func (pkg *Package) collectDecls(files []*ast.File) {
+	pkg.types = map[string]*typeDecl{}
+	pkg.methods = map[string]map[string]bool{}
+	for _, f := range files {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					doc := ts.Doc
+					if doc == nil && len(d.Specs) == 1 {
+						doc = d.Doc
+					}
+					decl := &typeDecl{name: ts.Name.Name, spec: ts}
+					for _, directive := range directives(doc) {
+						switch {
+						case len(directive) == 1:
+							decl.element = directive[0]
+						case len(directive) == 2 && directive[0] == "discriminator":
+							decl.union().discriminator = directive[1]
+						case len(directive) == 3 && directive[0] == "implementation":
+							u := decl.union()
+							u.impls = append(u.impls, implementation{typ: directive[1], value: directive[2]})
+						}
+					}
+					pkg.types[decl.name] = decl
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil && slices.ContainsFunc(directives(d.Doc), func(directive []string) bool {
+					return len(directive) == 1 && directive[0] == "converter"
+				}) {
+					pkg.funcs = append(pkg.funcs, d)
+				}
+				if d.Recv == nil || len(d.Recv.List) != 1 {
+					continue
+				}
+				recv := d.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if id, ok := recv.(*ast.Ident); ok {
+					if pkg.methods[id.Name] == nil {
+						pkg.methods[id.Name] = map[string]bool{}
+					}
+					pkg.methods[id.Name][d.Name.Name] = true
+				}
+			}
+		}
+	}
+}
+
+// directives returns the fields of the //runxml: directives of a doc comment
+func directives(doc *ast.CommentGroup) [][]string {
+	if doc == nil {
+		return nil
+	}
+	var ret [][]string
+	for _, c := range doc.List {
+		if text, ok := strings.CutPrefix(c.Text, "//runxml:"); ok {
+			ret = append(ret, strings.Fields(text))
+		}
+	}
+	return ret
+}
+
+// kind is the category of a Go type, deciding the code mapping it to XML
+type kind int
+
+const (
+	kindUnsupported kind = iota
+	kindString
+	kindBytes
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindStruct
+	kindSlice
+	kindPointer
+	kindInterface // Interfaces with implementations given by //runxml: directives
+	kindTime      // time.Time, written in a layout
+	kindText      // Types with MarshalText and UnmarshalText methods
+	kindConverter // Types with functions of a //runxml:converter directive
+)
+
+// valueType describes the Go type of a field
+type valueType struct {
+	kind   kind
+	name   string     // Type as written in the source
+	bits   int        // Size of numeric types; 0 for int and uint
+	elem   *valueType // Element type of slices and pointers
+	layout string     // Layout of times, given by a layout tag
+	conv   *converter // Functions converting values of kindConverter
+	union  *union     // Implementations of kindInterface
+	empty  kind       // Kind of the underlying type of kindTime, kindText and kindConverter
+}
+
+// isScalar reports whether values of the type are represented by text
+func (t *valueType) isScalar() bool {
+	return t.kind >= kindString && t.kind <= kindFloat || t.kind >= kindTime
+}
+
+// resolve returns the description of a type expression
+func (g *Generator) resolve(expr ast.Expr) *valueType {
+	t := &valueType{name: types.ExprString(expr)}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		switch expr.Name {
+		case "string":
+			t.kind = kindString
+		case "bool":
+			t.kind = kindBool
+		case "int", "int8", "int16", "int32", "int64", "rune":
+			t.kind = kindInt
+			t.bits = bitSize(expr.Name)
+		case "uint", "uint8", "uint16", "uint32", "uint64", "byte", "uintptr":
+			t.kind = kindUint
+			t.bits = bitSize(expr.Name)
+		case "float32", "float64":
+			t.kind = kindFloat
+			t.bits = bitSize(expr.Name)
+		default:
+			decl := g.pkg.types[expr.Name]
+			if decl == nil {
+				break
+			}
+			if _, ok := decl.spec.Type.(*ast.StructType); ok {
+				t.kind = kindStruct
+				break
+			}
+			if decl.impls != nil {
+				t.kind, t.union = kindInterface, decl.impls
+				break
+			}
+			// Named types take the kind of their underlying type
+			underlying := g.resolve(decl.spec.Type)
+			t.kind, t.bits, t.elem = underlying.kind, underlying.bits, underlying.elem
+		}
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			break
+		}
+		elem := g.resolve(expr.Elt)
+		switch {
+		case elem.name == "byte" || elem.name == "uint8":
+			t.kind = kindBytes
+		case elem.kind != kindUnsupported && elem.kind != kindSlice:
+			t.kind = kindSlice
+			t.elem = elem
+		}
+	case *ast.StarExpr:
+		elem := g.resolve(expr.X)
+		if elem.kind != kindUnsupported && elem.kind != kindSlice && elem.kind != kindPointer && elem.kind != kindInterface {
+			t.kind = kindPointer
+			t.elem = elem
+		}
+		return t
+	}
+	// Values converted to text by functions or methods, except pointers to them
+	if typ := g.pkg.typeOf(expr); typ != nil {
+		if conv := g.pkg.converters[types.TypeString(typ, nil)]; conv != nil {
+			t.kind, t.conv, t.empty = kindConverter, conv, emptyKind(typ)
+		} else if isTime(typ) {
+			t.kind, t.empty = kindTime, emptyKind(typ)
+		} else if hasTextMethods(typ) {
+			t.kind, t.empty = kindText, emptyKind(typ)
+		}
+	}
+	return t
+}
+
+// isTime reports whether the type is time.Time
+func isTime(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time"
+}
+
+// hasTextMethods reports whether values of the type, or pointers to them, implement
+// encoding.TextMarshaler and encoding.TextUnmarshaler
+func hasTextMethods(t types.Type) bool {
+	methods := types.NewMethodSet(types.NewPointer(t))
+	marshal := methods.Lookup(nil, "MarshalText")
+	unmarshal := methods.Lookup(nil, "UnmarshalText")
+	return marshal != nil && unmarshal != nil &&
+		types.Identical(marshal.Type(), marshalTextType) && types.Identical(unmarshal.Type(), unmarshalTextType)
+}
+
+var (
+	bytesType         = types.NewSlice(types.Typ[types.Byte])
+	errorType         = types.Universe.Lookup("error").Type()
+	marshalTextType   = types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewParam(0, nil, "", bytesType), types.NewParam(0, nil, "", errorType)), false)
+	unmarshalTextType = types.NewSignatureType(nil, nil, nil, types.NewTuple(types.NewParam(0, nil, "", bytesType)), types.NewTuple(types.NewParam(0, nil, "", errorType)), false)
+)
+
+// emptyKind returns the kind deciding whether values of the underlying type of t are empty,
+// or kindUnsupported if they never are
+func emptyKind(t types.Type) kind {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsString != 0:
+			return kindString
+		case info&types.IsBoolean != 0:
+			return kindBool
+		case info&types.IsInteger != 0:
+			return kindInt
+		case info&types.IsFloat != 0:
+			return kindFloat
+		}
+	case *types.Slice, *types.Map:
+		return kindSlice
+	case *types.Pointer, *types.Interface:
+		return kindPointer
+	}
+	return kindUnsupported
+}
+
+// setLayout sets the layout of the times of the type, or of its elements or the value it
+// points to, and reports whether they are times
+func (t *valueType) setLayout(layout string) bool {
+	for t.kind == kindSlice || t.kind == kindPointer {
+		t = t.elem
+	}
+	t.layout = layout
+	return t.kind == kindTime
+}
+
+// custom reports whether values of the type are converted to text by a layout or converter,
+// or told apart by a discriminator, unlike encoding/xml
+func (t *valueType) custom() bool {
+	for t.kind == kindSlice || t.kind == kindPointer {
+		t = t.elem
+	}
+	return t.layout != "" || t.kind == kindConverter || t.kind == kindInterface
+}
+
+// converter is a pair of functions converting values of a type to and from text, declared
+// with //runxml:converter directives
+type converter struct {
+	decode string // func(text []byte) (T, error), which does not keep text
+	encode string // func(b []byte, v T) ([]byte, error), appending the text of v to b
+}
+
+// collectConverters records the functions of the //runxml:converter directives by type
+func (pkg *Package) collectConverters() {
+	pkg.converters = map[string]*converter{}
+	for _, d := range pkg.funcs {
+		fn, ok := pkg.defs[d.Name].(*types.Func)
+		if !ok {
+			log.Fatalf("converter %s: type unknown", d.Name.Name)
+		}
+		sig := fn.Type().(*types.Signature)
+		params, results := sig.Params(), sig.Results()
+		if results.Len() != 2 || !types.Identical(results.At(1).Type(), errorType) {
+			log.Fatalf("converter %s: results must be (T, error) or ([]byte, error)", d.Name.Name)
+		}
+		var t types.Type
+		decode := false
+		switch {
+		case params.Len() == 1 && types.Identical(params.At(0).Type(), bytesType):
+			t, decode = results.At(0).Type(), true
+		case params.Len() == 2 && types.Identical(params.At(0).Type(), bytesType) &&
+			types.Identical(results.At(0).Type(), bytesType):
+			t = params.At(1).Type()
+		default:
+			log.Fatalf("converter %s: signature must be func([]byte) (T, error) or func([]byte, T) ([]byte, error)", d.Name.Name)
+		}
+		name := types.TypeString(t, nil)
+		conv := pkg.converters[name]
+		if conv == nil {
+			conv = &converter{}
+			pkg.converters[name] = conv
+		}
+		slot := &conv.encode
+		if decode {
+			slot = &conv.decode
+		}
+		if *slot != "" {
+			log.Fatalf("converters %s and %s of type %s", *slot, d.Name.Name, name)
+		}
+		*slot = d.Name.Name
+	}
+	for name, conv := range pkg.converters {
+		if conv.decode == "" || conv.encode == "" {
+			log.Fatalf("type %s has a converter to text or from text, but not both", name)
+		}
+	}
+}
+
+// bitSize returns the size of a numeric type; 0 for int and uint
+func bitSize(name string) int {
+	switch name {
+	case "int8", "uint8", "byte":
+		return 8
+	case "int16", "uint16":
+		return 16
+	case "int32", "uint32", "rune", "float32":
+		return 32
+	case "int64", "uint64", "float64", "uintptr":
+		return 64
+	}
+	return 0
+}
+
+// elementType returns the struct declaration of values of the type, or of its elements or
+// the value it points to
+func (g *Generator) elementType(t *valueType) *typeDecl {
+	for t.kind == kindSlice || t.kind == kindPointer {
+		t = t.elem
+	}
+	if t.kind != kindStruct {
+		return nil
+	}
+	return g.pkg.types[t.name]
 }
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/robfordww/runxml"
+)
+
+// flushSize is the length of the buffer of generated MarshalRunXML methods, at which it is
+// written to the writer
+const flushSize = 4096
+
+// xmlURL is the namespace of the xml prefix
+const xmlURL = "http://www.w3.org/XML/1998/namespace"
+
+// generateMarshal writes the MarshalRunXML method of a struct or slice type, and the
+// appendRunXML method it is built on, unless they are declared in the package
+func (g *Generator) generateMarshal(decl *typeDecl) {
+	_, isStruct := decl.spec.Type.(*ast.StructType)
+	t := g.resolve(decl.spec.Type)
+	if !isStruct && t.kind != kindSlice {
+		log.Fatalf("type %s is not a struct or slice", decl.name)
+	}
+	g.addImport("io")
+	g.addImport("github.com/robfordww/runxml")
+	methods := g.pkg.methods[decl.name]
+	if !methods["MarshalRunXML"] {
+		name, xmlns := g.elementName(decl)
+		g.Printf("// MarshalRunXML writes x to w as a <%s> element\n", name)
+		g.Printf("func (x *%s) MarshalRunXML(w io.Writer) error {\n", decl.name)
+		g.Printf("b, err := x.appendRunXML(make([]byte, 0, %d), w, %q, %q, \"\", \"\")\n", flushSize, name, xmlns)
+		g.Printf("if err == nil {\n_, err = w.Write(b)\n}\nreturn err\n}\n\n")
+	}
+	if methods["appendRunXML"] {
+		return
+	}
+	g.Printf("// appendRunXML appends x to b as the element name in the namespace space, within an\n")
+	g.Printf("// element in the namespace parent, with the attributes attrs before those of x, and\n")
+	g.Printf("// writes b to w when it is full\n")
+	g.Printf("func (x *%s) appendRunXML(b []byte, w io.Writer, name, space, parent, attrs string) ([]byte, error) {\n", decl.name)
+	if isStruct {
+		g.marshalStruct(decl)
+	} else {
+		// The elements of a slice are written as the element of their type
+		g.declareVars([]*valueType{t.elem})
+		g.startTag()
+		g.Printf("b = append(b, '>')\n")
+		g.Printf("for i := range *x {\n")
+		name, xmlns := g.itemName(t.elem)
+		g.marshalElement("(*x)[i]", t.elem, name, xmlns, "space", false)
+		g.Printf("}\n")
+	}
+	g.Printf("b = append(b, \"</\"...)\nb = append(b, name...)\nb = append(b, '>')\n")
+	g.Printf("if len(b) >= %d {\nif _, err := w.Write(b); err != nil {\nreturn b, err\n}\nb = b[:0]\n}\n", flushSize)
+	g.Printf("return b, nil\n}\n\n")
+}
+
+// startTag writes the code appending the start tag of the element name, up to the attributes
+// of the value
+func (g *Generator) startTag() {
+	g.Printf("b = append(b, '<')\nb = append(b, name...)\n")
+	g.Printf("if space != \"\" {\nb = append(b, %q...)\n", ` xmlns="`)
+	g.Printf("b = runxml.AppendEscaped(b, space, true)\nb = append(b, '\"')\n}\n")
+	g.Printf("b = append(b, attrs...)\n")
+}
+
+// marshalStruct writes the body of the appendRunXML method of a struct. The parent elements
+// of a field with a path are left open for the following fields of the same parents. If a
+// nil pointer leaves out its parents, the open parents are counted by the variable depth.
+func (g *Generator) marshalStruct(decl *typeDecl) {
+	info := g.structInfo(decl)
+	var fieldTypes []*valueType
+	for _, f := range info.fields {
+		fieldTypes = append(fieldTypes, f.typ)
+	}
+	g.declareVars(fieldTypes)
+	if xn := info.xmlName; xn != nil {
+		if xn.xmlName != "" {
+			g.Printf("name, space = %q, %q\n", xn.xmlName, xn.xmlns)
+		} else if info.nameType {
+			g.Printf("if x.%s.Local != \"\" {\n", xn.name)
+			g.Printf("name, space = x.%s.Local, x.%s.Space\n}\n", xn.name, xn.name)
+		}
+	}
+	g.startTag()
+	g.marshalAttributes(info)
+	if xn := info.xmlName; xn != nil && xn.xmlName == "" {
+		// An element named by a field or type is taken out of the namespace of its parent
+		g.Printf("if space == \"\" && parent != \"\" {\nb = append(b, %q...)\n}\n", ` xmlns=""`)
+	}
+	g.Printf("b = append(b, '>')\n")
+
+	dynamic := false
+	for _, f := range info.fields {
+		if f.mode == modeElement && f.typ.kind == kindPointer && len(f.parents) > 0 {
+			dynamic = true
+		}
+	}
+	if dynamic {
+		g.Printf("depth := 0\n")
+	}
+	var stack []string // Parents which may be open
+	trim := func(parents []string) {
+		k := 0
+		for k < len(parents) && k < len(stack) && parents[k] == stack[k] {
+			k++
+		}
+		switch {
+		case len(stack) == k:
+		case dynamic:
+			g.Printf("for ; depth > %d; depth-- {\n", k)
+			g.Printf("b = append(b, \"</\"...)\nb = append(b, %s[depth-1]...)\nb = append(b, '>')\n}\n", stringSlice(stack))
+		default:
+			var end strings.Builder
+			for i := len(stack) - 1; i >= k; i-- {
+				end.WriteString("</" + stack[i] + ">")
+			}
+			g.Printf("b = append(b, %q...)\n", end.String())
+		}
+		stack = stack[:k]
+	}
+	push := func(parents []string) {
+		switch {
+		case dynamic && len(parents) > 0:
+			g.Printf("for _, p := range %s[depth:] {\n", stringSlice(parents))
+			g.Printf("b = append(b, '<')\nb = append(b, p...)\nb = append(b, '>')\n}\n")
+			g.Printf("depth = %d\n", len(parents))
+		case len(parents) > len(stack):
+			var start strings.Builder
+			for _, p := range parents[len(stack):] {
+				start.WriteString("<" + p + ">")
+			}
+			g.Printf("b = append(b, %q...)\n", start.String())
+		}
+		stack = parents
+	}
+	for _, f := range info.fields {
+		target := "x." + f.name
+		switch f.mode {
+		case modeCDATA, modeCharData:
+			trim(nil)
+			if f.mode == modeCDATA && (f.typ.kind == kindString || f.typ.kind == kindBytes) {
+				// Empty CDATA sections are left out
+				g.Printf("if len(%s) > 0 {\n", target)
+				g.marshalText(target, f.typ, f.mode)
+				g.Printf("}\n")
+			} else {
+				g.marshalText(target, f.typ, f.mode)
+			}
+		case modeComment:
+			trim(nil)
+			g.marshalComment(decl, f)
+		case modeInnerXML:
+			g.Printf("b = append(b, %s...)\n", target)
+		case modeElement:
+			trim(f.parents)
+			if f.typ.kind == kindPointer && len(f.parents) > 0 {
+				// The parents of nil pointers are left out
+				g.Printf("if %s != nil {\n", target)
+				push(f.parents)
+				if f.typ.elem.kind == kindStruct {
+					g.marshalElement(target, f.typ.elem, f.xmlName, f.xmlns, `""`, false)
+				} else {
+					g.marshalElement("*"+target, f.typ.elem, f.xmlName, f.xmlns, `""`, false)
+				}
+				g.Printf("}\n")
+				continue
+			}
+			push(f.parents)
+			parent := "space"
+			if len(f.parents) > 0 {
+				parent = `""`
+			}
+			g.marshalElement(target, f.typ, f.xmlName, f.xmlns, parent, f.omitEmpty)
+		}
+	}
+	trim(nil)
+}
+
+// marshalAttributes writes the code appending the attributes of a struct. Namespaces are
+// given prefixes as by encoding/xml, and declared with the first attribute written in them.
+func (g *Generator) marshalAttributes(info *structInfo) {
+	prefixes := map[string]string{}
+	count := map[string]int{}
+	taken := map[string]bool{}
+	seq := 0
+	for _, f := range info.fields {
+		if f.mode == modeAttr && f.xmlns != "" {
+			if _, ok := prefixes[f.xmlns]; !ok {
+				prefixes[f.xmlns] = attrPrefix(f.xmlns, taken, &seq)
+			}
+			count[f.xmlns]++
+		}
+	}
+	declared := map[string]bool{} // Namespaces declared by the preceding attributes
+	guards := map[string]string{} // Variables recording whether namespaces are declared
+	for _, f := range info.fields {
+		if f.mode != modeAttr {
+			continue
+		}
+		target := "x." + f.name
+		cond := ""
+		if f.typ.kind == kindPointer || f.omitEmpty {
+			cond = nonEmpty(target, f.typ)
+		}
+		prefix := prefixes[f.xmlns]
+		declare := f.xmlns != "" && prefix != "xml" && !declared[f.xmlns]
+		if declare && cond != "" && count[f.xmlns] > 1 && guards[f.xmlns] == "" {
+			guards[f.xmlns] = fmt.Sprintf("declared%d", len(guards)+1)
+			g.Printf("%s := false\n", guards[f.xmlns])
+		}
+		if cond != "" {
+			g.Printf("if %s {\n", cond)
+		}
+		name := f.xmlName
+		if f.xmlns != "" {
+			name = prefix + ":" + name
+		}
+		if declare {
+			xmlns := fmt.Sprintf(` xmlns:%s="%s"`, prefix, runxml.AppendEscaped(nil, f.xmlns, true))
+			if guard := guards[f.xmlns]; guard != "" {
+				g.Printf("if !%s {\nb = append(b, %q...)\n%s = true\n}\n", guard, xmlns, guard)
+			} else {
+				g.Printf("b = append(b, %q...)\n", xmlns)
+				declared[f.xmlns] = true
+			}
+		}
+		g.Printf("b = append(b, %q...)\n", " "+name+`="`)
+		if f.typ.kind == kindPointer {
+			g.marshalText("*"+target, f.typ.elem, modeAttr)
+		} else {
+			g.marshalText(target, f.typ, modeAttr)
+		}
+		g.Printf("b = append(b, '\"')\n")
+		if cond != "" {
+			g.Printf("}\n")
+		}
+	}
+}
+
+// declareVars writes the declarations of the variables used by the code appending values of
+// the types: err for the errors of methods, and text for the text of values converted by
+// layouts, methods and converters
+func (g *Generator) declareVars(types []*valueType) {
+	var err, text bool
+	for _, t := range types {
+		if g.elementType(t) != nil {
+			err = true
+		}
+		for t.kind == kindSlice || t.kind == kindPointer {
+			t = t.elem
+		}
+		switch t.kind {
+		case kindInterface:
+			err = true
+		case kindText, kindConverter:
+			err, text = true, true
+		case kindTime:
+			text = true
+		}
+	}
+	if err {
+		g.Printf("var err error\n")
+	}
+	if text {
+		g.Printf("var text []byte\n")
+	}
+}
+
+// attrPrefix returns the prefix of a namespace of attributes, chosen as by encoding/xml from
+// the last segment of its path. Prefixes which are taken are numbered from seq.
+func attrPrefix(url string, taken map[string]bool, seq *int) string {
+	if url == xmlURL {
+		return "xml"
+	}
+	prefix := strings.TrimRight(url, "/")
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	if !isName(prefix) || strings.Contains(prefix, ":") {
+		prefix = "_"
+	}
+	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
+		prefix = "_" + prefix
+	}
+	if taken[prefix] {
+		for *seq++; taken[fmt.Sprintf("%s_%d", prefix, *seq)]; *seq++ {
+		}
+		prefix = fmt.Sprintf("%s_%d", prefix, *seq)
+	}
+	taken[prefix] = true
+	return prefix
+}
+
+// isName reports whether s is an XML name
+func isName(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && r != ':' && (i == 0 || !unicode.IsDigit(r) && r != '-' && r != '.') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// stringSlice returns a slice literal of the strings
+func stringSlice(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+// marshalComment writes the code appending a comment field, which is left out if empty
+func (g *Generator) marshalComment(decl *typeDecl, f field) {
+	target := "x." + f.name
+	g.addImport("errors")
+	g.Printf("if len(%s) > 0 {\n", target)
+	if f.typ.kind == kindString {
+		g.addImport("strings")
+		if f.typ.name == "string" {
+			g.Printf("if strings.Contains(%s, \"--\") {\n", target)
+		} else {
+			g.Printf("if strings.Contains(string(%s), \"--\") {\n", target)
+		}
+	} else {
+		g.addImport("bytes")
+		g.Printf("if bytes.Contains(%s, []byte(\"--\")) {\n", target)
+	}
+	g.Printf("return b, errors.New(%q)\n}\n", decl.name+"."+f.name+`: comment must not contain "--"`)
+	g.Printf("b = append(b, \"<!--\"...)\nb = append(b, %s...)\n", target)
+	// A hyphen before the end of the comment would make "--->"
+	g.Printf("if %s[len(%s)-1] == '-' {\nb = append(b, ' ')\n}\n", target, target)
+	g.Printf("b = append(b, \"-->\"...)\n}\n")
+}
+
+// marshalElement writes the code appending the element name with the value of target,
+// within an element in the namespace of the expression parent. Slices append an element for
+// each of their values, and nil pointers none.
+func (g *Generator) marshalElement(target string, t *valueType, name, xmlns, parent string, omitEmpty bool) {
+	switch t.kind {
+	case kindStruct:
+		g.enqueue(t.name)
+		g.Printf("if b, err = %s.appendRunXML(b, w, %q, %q, %s, \"\"); err != nil {\nreturn b, err\n}\n", target, name, xmlns, parent)
+	case kindInterface:
+		g.marshalInterface(target, t, name, xmlns, parent)
+	case kindPointer:
+		g.Printf("if %s != nil {\n", target)
+		if t.elem.kind == kindStruct {
+			g.marshalElement(target, t.elem, name, xmlns, parent, false)
+		} else {
+			g.marshalElement("*"+target, t.elem, name, xmlns, parent, false)
+		}
+		g.Printf("}\n")
+	case kindSlice:
+		g.Printf("for i := range %s {\n", target)
+		g.marshalElement(target+"[i]", t.elem, name, xmlns, parent, false)
+		g.Printf("}\n")
+	default:
+		cond := ""
+		if omitEmpty {
+			cond = nonEmpty(target, t)
+		}
+		if cond != "" {
+			g.Printf("if %s {\n", cond)
+		}
+		start := "<" + name
+		if xmlns != "" {
+			start += fmt.Sprintf(` xmlns="%s"`, runxml.AppendEscaped(nil, xmlns, true))
+		}
+		g.Printf("b = append(b, %q...)\n", start+">")
+		g.marshalText(target, t, modeElement)
+		g.Printf("b = append(b, %q...)\n", "</"+name+">")
+		if cond != "" {
+			g.Printf("}\n")
+		}
+	}
+}
+
+// marshalText writes the code appending the text of target, of a scalar type, as escaped
+// text or a CDATA section. Values converted by layouts, methods or converters are first
+// written to the variable text.
+func (g *Generator) marshalText(target string, t *valueType, mode fieldMode) {
+	convert := func(base string) string {
+		if t.name == base {
+			return target
+		}
+		return base + "(" + target + ")"
+	}
+	var text string // Appends the text to the slice %s
+	switch t.kind {
+	case kindString, kindBytes:
+		if mode == modeCDATA {
+			g.Printf("b = runxml.AppendCDATA(b, %s)\n", target)
+		} else {
+			g.Printf("b = runxml.AppendEscaped(b, %s, %v)\n", target, mode == modeAttr)
+		}
+		return
+	case kindTime, kindText, kindConverter:
+		switch t.kind {
+		case kindTime:
+			layout := "time.RFC3339Nano"
+			if t.layout != "" {
+				layout = fmt.Sprintf("%q", t.layout)
+			}
+			g.addImport("time")
+			g.Printf("text = %s.AppendFormat(text[:0], %s)\n", operand(target), layout)
+		case kindText:
+			g.Printf("if text, err = %s.MarshalText(); err != nil {\nreturn b, err\n}\n", operand(target))
+		case kindConverter:
+			g.Printf("if text, err = %s(text[:0], %s); err != nil {\nreturn b, err\n}\n", t.conv.encode, target)
+		}
+		if mode == modeCDATA {
+			g.Printf("if len(text) > 0 {\nb = runxml.AppendCDATA(b, text)\n}\n")
+		} else {
+			g.Printf("b = runxml.AppendEscaped(b, text, %v)\n", mode == modeAttr)
+		}
+		return
+	case kindBool:
+		text = "strconv.AppendBool(%s, " + convert("bool") + ")"
+	case kindInt:
+		text = "strconv.AppendInt(%s, " + convert("int64") + ", 10)"
+	case kindUint:
+		text = "strconv.AppendUint(%s, " + convert("uint64") + ", 10)"
+	case kindFloat:
+		text = fmt.Sprintf("strconv.AppendFloat(%%s, %s, 'g', -1, %d)", convert("float64"), t.bits)
+	}
+	g.addImport("strconv")
+	if mode == modeCDATA {
+		g.Printf("b = runxml.AppendCDATA(b, "+text+")\n", "nil")
+	} else {
+		g.Printf("b = "+text+"\n", "b")
+	}
+}
+
+// marshalInterface writes the code appending the value of target, of an interface type, as
+// its implementation. Implementations told apart by attributes are written as the element
+// name with the attribute, and the others as the element of their name.
+func (g *Generator) marshalInterface(target string, t *valueType, name, xmlns, parent string) {
+	u := t.union
+	g.addImport("fmt")
+	g.Printf("switch v := %s.(type) {\n", target)
+	for _, impl := range u.impls {
+		g.enqueue(impl.name())
+		g.Printf("case %s:\n", impl.typ)
+		if strings.HasPrefix(impl.typ, "*") {
+			g.Printf("if v == nil {\nbreak\n}\n")
+		}
+		var attrs string
+		switch u.discriminator {
+		case "element":
+			name, xmlns = impl.value, ""
+		case "xsi:type":
+			attrs = fmt.Sprintf(` xmlns:xsi="%s" xsi:type="%s"`, xsiURL, runxml.AppendEscaped(nil, impl.value, true))
+		default:
+			attrs = fmt.Sprintf(` type="%s"`, runxml.AppendEscaped(nil, impl.value, true))
+		}
+		g.Printf("if b, err = v.appendRunXML(b, w, %q, %q, %s, %q); err != nil {\nreturn b, err\n}\n", name, xmlns, parent, attrs)
+	}
+	g.Printf("case nil:\ndefault:\nreturn b, fmt.Errorf(\"%s: unsupported implementation %%T\", v)\n}\n", t.name)
+}
+
+// nonEmpty returns the condition of a scalar, slice or pointer not being omitted as empty,
+// or "" if values of the type are never empty
+func nonEmpty(target string, t *valueType) string {
+	k := t.kind
+	if k >= kindTime {
+		k = t.empty
+	}
+	switch k {
+	case kindUnsupported, kindStruct:
+		return ""
+	case kindString, kindBytes, kindSlice:
+		return fmt.Sprintf("len(%s) > 0", target)
+	case kindBool:
+		return target
+	case kindPointer, kindInterface:
+		return target + " != nil"
+	}
+	return target + " != 0"
+}
+
+// itemName returns the element name and namespace of the values of a slice: those of a
+// declared type, or else the name of the type
+func (g *Generator) itemName(t *valueType) (name, xmlns string) {
+	if elem := g.elementType(t); elem != nil {
+		return g.elementName(elem)
+	}
+	for t.kind == kindPointer {
+		t = t.elem
+	}
+	return t.name, ""
+}
+
+// generateTest writes a test marshalling a value of the type with all fields set. The output
+// is compared with that of encoding/xml, unless the type maps fields differently, and the
+// value unmarshalled from it with the original, unless it cannot be read back.
+func (g *Generator) generateTest(typeName string) {
+	decl := g.pkg.types[typeName]
+	t := g.resolve(ast.NewIdent(decl.name))
+	name, xmlns := g.elementName(decl)
+	g.addImport("bytes")
+	g.addImport("testing")
+	g.addImport("github.com/robfordww/runxml")
+	g.Printf("func TestRunXMLRoundTrip%s(t *testing.T) {\n", decl.name)
+	g.Printf("var x %s\n", decl.name)
+	n := 0
+	g.sample("x", t, name, xmlns, &n, map[string]int{})
+	g.Printf("var buf bytes.Buffer\n")
+	g.Printf("if err := x.MarshalRunXML(&buf); err != nil {\nt.Fatal(err)\n}\n")
+	if g.comparable(t, map[string]bool{}) {
+		g.addImport("encoding/xml")
+		g.Printf("// The output is equivalent to that of encoding/xml\n")
+		if t.kind == kindSlice {
+			item, _ := g.itemName(t.elem)
+			g.Printf("expected, err := xml.Marshal(struct {\nXMLName xml.Name `xml:%q`\nItems %s `xml:%q`\n}{Items: x})\n", name, decl.name, item)
+			g.Printf("if err != nil {\nt.Fatal(err)\n}\n")
+		} else {
+			g.Printf("var out bytes.Buffer\n")
+			g.Printf("start := xml.StartElement{Name: xml.Name{Space: %q, Local: %q}}\n", xmlns, name)
+			g.Printf("if err := xml.NewEncoder(&out).EncodeElement(x, start); err != nil {\nt.Fatal(err)\n}\n")
+			g.Printf("expected := out.Bytes()\n")
+		}
+		g.Printf("c := runxml.Canonicalizer{Method: runxml.ExclusiveC14N, WithComments: true}\n")
+		g.Printf("canonical := func(b []byte) string {\n")
+		g.Printf("doc, err := runxml.NewDefaultRunXML().Parse(bytes.Clone(b))\n")
+		g.Printf("if err != nil {\nt.Fatalf(\"%%v\\n%%s\", err, b)\n}\n")
+		g.Printf("s, err := c.Append(nil, doc)\nif err != nil {\nt.Fatal(err)\n}\nreturn string(s)\n}\n")
+		g.Printf("if s, e := canonical(buf.Bytes()), canonical(expected); s != e {\n")
+		g.Printf("t.Errorf(\"output differs from encoding/xml:\\n%%s\\n%%s\", s, e)\n}\n")
+	}
+	if g.roundTrips(t, map[string]bool{}) {
+		g.addImport("reflect")
+		g.Printf("doc, err := runxml.NewDefaultRunXML().Parse(bytes.Clone(buf.Bytes()))\n")
+		g.Printf("if err != nil {\nt.Fatalf(\"%%v\\n%%s\", err, buf.Bytes())\n}\n")
+		g.Printf("var y %s\n", decl.name)
+		g.Printf("if err := y.UnmarshalRunXML(doc.GetFirstChild()); err != nil {\nt.Fatal(err)\n}\n")
+		g.Printf("if !reflect.DeepEqual(x, y) {\nt.Errorf(\"expected %%+v, found %%+v\\n%%s\", x, y, buf.Bytes())\n}\n")
+	}
+	g.Printf("}\n\n")
+}
+
+// comparable reports whether the generated methods of the type, and of the types of its
+// fields, map all fields as encoding/xml does
+func (g *Generator) comparable(t *valueType, seen map[string]bool) bool {
+	if decl := g.pkg.types[t.name]; decl != nil && t.kind == kindSlice && !seen[t.name] {
+		seen[t.name] = true
+		if g.handWritten(decl) {
+			return false
+		}
+	}
+	decl := g.elementType(t)
+	if decl == nil || seen[decl.name] {
+		return true
+	}
+	seen[decl.name] = true
+	info := g.structInfo(decl)
+	if g.handWritten(decl) || info.skipped {
+		return false
+	}
+	for _, f := range info.fields {
+		if f.typ.custom() || !g.comparable(f.typ, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// handWritten reports whether methods of the type mapping it to XML are declared in the
+// package
+func (g *Generator) handWritten(decl *typeDecl) bool {
+	methods := g.pkg.methods[decl.name]
+	return methods["MarshalRunXML"] || methods["appendRunXML"] || methods["UnmarshalRunXML"]
+}
+
+// roundTrips reports whether values of the type are read back from their output. The inner
+// XML of a struct with other content holds that content as well.
+func (g *Generator) roundTrips(t *valueType, seen map[string]bool) bool {
+	decl := g.elementType(t)
+	if decl == nil || seen[decl.name] {
+		return true
+	}
+	seen[decl.name] = true
+	info := g.structInfo(decl)
+	inner, content := false, false
+	for _, f := range info.fields {
+		switch f.mode {
+		case modeInnerXML:
+			inner = true
+		case modeAttr:
+		default:
+			content = true
+		}
+		if !g.roundTrips(f.typ, seen) {
+			return false
+		}
+	}
+	return !inner || !content
+}
+
+// sample writes the statements setting target to a value of the type, with distinct values
+// in each field and two values in slices. name and space are the element of the value, set
+// in XMLName fields. Values of a struct type nest within one another once, and are left zero
+// at further depth, as are values of types with text methods or converters.
+func (g *Generator) sample(target string, t *valueType, name, space string, n *int, depth map[string]int) {
+	*n++
+	switch t.kind {
+	case kindString:
+		g.Printf("%s = %q\n", target, fmt.Sprintf(`value %d <&> "'`, *n))
+	case kindBytes:
+		g.Printf("%s = %s(%q)\n", target, t.name, fmt.Sprintf("bytes %d", *n))
+	case kindBool:
+		g.Printf("%s = true\n", target)
+	case kindInt, kindUint:
+		g.Printf("%s = %d\n", target, *n%100+1)
+	case kindFloat:
+		g.Printf("%s = %d.5\n", target, *n%100)
+	case kindTime:
+		g.addImport("time")
+		date := fmt.Sprintf("time.Date(%d, 1, 2, 3, 4, 5, 0, time.UTC)", 2000+*n%100)
+		if t.layout == "" {
+			g.Printf("%s = %s\n", target, date)
+		} else {
+			// The time is truncated to the layout
+			g.Printf("%s, _ = time.Parse(%q, %s.Format(%q))\n", target, t.layout, date, t.layout)
+		}
+	case kindInterface:
+		// The implementations are taken in turn, counted in depth by the interface
+		impl := t.union.impls[depth[t.name]%len(t.union.impls)]
+		depth[t.name]++
+		if depth[impl.name()] == 2 {
+			return
+		}
+		if t.union.discriminator == "element" {
+			name, space = impl.value, ""
+		}
+		var expr ast.Expr = ast.NewIdent(impl.name())
+		if strings.HasPrefix(impl.typ, "*") {
+			expr = &ast.StarExpr{X: expr}
+		}
+		v := fmt.Sprintf("v%d", *n)
+		g.Printf("var %s %s\n", v, impl.typ)
+		g.sample(v, g.resolve(expr), name, space, n, depth)
+		g.Printf("%s = %s\n", target, v)
+	case kindPointer:
+		if decl := g.elementType(t); decl != nil && depth[decl.name] == 2 {
+			return
+		}
+		g.Printf("%s = new(%s)\n", target, t.elem.name)
+		if t.elem.kind == kindStruct {
+			g.sample(target, t.elem, name, space, n, depth)
+		} else {
+			g.sample("*"+target, t.elem, name, space, n, depth)
+		}
+	case kindSlice:
+		if decl := g.elementType(t); decl != nil && depth[decl.name] == 2 {
+			return
+		}
+		if g.pkg.types[t.name] != nil {
+			// The values of a named slice are the elements of their type
+			name, space = g.itemName(t.elem)
+		}
+		g.Printf("%s = make(%s, 2)\n", target, t.name)
+		for i := range 2 {
+			g.sample(fmt.Sprintf("%s[%d]", target, i), t.elem, name, space, n, depth)
+		}
+	case kindStruct:
+		if depth[t.name] == 2 {
+			return
+		}
+		depth[t.name]++
+		defer func() { depth[t.name]-- }()
+		info := g.structInfo(g.pkg.types[t.name])
+		if xn := info.xmlName; xn != nil && info.nameType {
+			if xn.xmlName != "" {
+				name, space = xn.xmlName, xn.xmlns
+			}
+			g.addImport("encoding/xml")
+			g.Printf("%s.%s = xml.Name{Space: %q, Local: %q}\n", target, xn.name, space, name)
+		}
+		// Only the first field of character data, comments or inner XML is read
+		var chardata, comment, innerxml bool
+		for _, f := range info.fields {
+			ft := target + "." + f.name
+			switch f.mode {
+			case modeAttr:
+				g.sample(ft, f.typ, "", "", n, depth)
+			case modeElement:
+				fs := space
+				if f.xmlns != "" {
+					fs = f.xmlns
+				}
+				g.sample(ft, f.typ, f.xmlName, fs, n, depth)
+			case modeCDATA, modeCharData:
+				if !chardata {
+					chardata = true
+					g.sample(ft, f.typ, "", "", n, depth)
+				}
+			case modeComment:
+				if !comment {
+					comment = true
+					g.sampleText(ft, f.typ, fmt.Sprintf("comment %d", *n))
+				}
+			case modeInnerXML:
+				if !innerxml {
+					innerxml = true
+					g.sampleText(ft, f.typ, fmt.Sprintf(`<inner n="%d">text</inner>`, *n))
+				}
+			}
+		}
+	}
+}
+
+// sampleText writes the statement setting target, a string or byte slice, to the text
+func (g *Generator) sampleText(target string, t *valueType, text string) {
+	if t.kind == kindBytes {
+		g.Printf("%s = %s(%q)\n", target, t.name, text)
+	} else {
+		g.Printf("%s = %q\n", target, text)
+	}
+}
@@ -1,8 +1,45 @@
 // The rxgen is the command line utility that generates the
 // Unmarshal and Marshal methods of selected structs.
+//
+// UnmarshalRunXML methods are generated for the types and the struct types of their
+// fields. Fields are mapped by their xml tags as by encoding/xml: a tag names the element
+// or attribute, with an optional namespace as "ns name", and "a>b>c" reads c within the
+// parents a and b. The options ",attr", ",chardata", ",cdata", ",innerxml", ",comment" and
+// ",omitempty" are understood, and fields tagged "-" are left out. An XMLName field names
+// the element of a struct, which is checked when reading, and an xml.Name records it.
+// Without a name in the tag, a field is named by its XMLName tag or the //runxml:<name>
+// comment of its type, or else by the field name. A slice type reads the elements named by
+// the comment of its item type.
+//
+// Fields of time.Time are read and written in the layout of a `layout:"..."` tag, by
+// default RFC 3339. Types with MarshalText and UnmarshalText methods are converted by them,
+// as by encoding/xml. A type may instead be converted by a pair of functions with a
+// //runxml:converter comment:
+//
+//	func(text []byte) (T, error)
+//	func(b []byte, v T) ([]byte, error)
+//
+// the first of which must not keep text, and the second appends the text of v to b.
+//
+// Fields of an interface type hold one of the implementations listed by comments of the
+// interface, which tell them apart by the element name, or by a type or xsi:type attribute
+// of the element named by the field:
+//
+//	//runxml:discriminator xsi:type
+//	//runxml:implementation *Circle circle
+//	//runxml:implementation Square square
+//
+// Without a discriminator comment, implementations are told apart by the element name.
+//
+// MarshalRunXML methods write the same elements and attributes to an io.Writer, in the
+// order of the fields, with output equivalent to that of encoding/xml. A type is written as
+// the element named by its XMLName field or comment, or else the type name. Tests of
+// marshalling values of the types, comparing the output with that of encoding/xml and
+// unmarshalling it again, are written to a _test.go file.
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -42,33 +79,44 @@ func main() {
 	} else {
 		dir = "."
 	}
+	// Write to file.
+	baseName := fmt.Sprintf("%s_rxgen.go", types[0])
+	outputName := filepath.Join(dir, strings.ToLower(baseName))
+	g.output = outputName
 	g.parsePackageDir(dir)
 
-	// for each type listed, generate code
-	_ = types
-	//spew.Dump(g.)
-
-	// Print the header and package clause.
-	g.Printf("// Code generated by \"rxgen %s\"; DO NOT EDIT.\n", strings.Join(os.Args[1:], " "))
-	g.Printf("\n")
-	g.Printf("package %s", g.pkg.name)
-	g.Printf("\n")
-	g.Printf("import \"strconv\"\n") // Used by all methods.
-
 	// Run generate for each type.
 	for _, typeName := range types {
 		g.generate(typeName)
 	}
 
+	// Print the header, package clause and imports before the generated code.
+	body := bytes.Clone(g.buf.Bytes())
+	g.buf.Reset()
+	g.buf.Write(g.header(os.Args[1:]))
+	g.buf.Write(body)
+
 	// Format the output.
 	src := g.format()
 
-	// Write to file.
-	baseName := fmt.Sprintf("%s_rxgen.go", types[0])
-	outputName := filepath.Join(dir, strings.ToLower(baseName))
-
 	err := ioutil.WriteFile(outputName, src, 0644)
 	if err != nil {
 		log.Fatalf("writing output: %s", err)
 	}
+
+	// Write the round trip tests of the types to the test file.
+	g.buf.Reset()
+	g.imports = nil
+	for _, typeName := range types {
+		g.generateTest(typeName)
+	}
+	body = bytes.Clone(g.buf.Bytes())
+	g.buf.Reset()
+	g.buf.Write(g.header(os.Args[1:]))
+	g.buf.Write(body)
+	testName := strings.TrimSuffix(outputName, ".go") + "_test.go"
+	err = ioutil.WriteFile(testName, g.format(), 0644)
+	if err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
 }
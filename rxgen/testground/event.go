@@ -0,0 +1,70 @@
+package runxml
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// Event has fields of times and types with text methods
+type Event struct {
+	At       time.Time   `xml:"at,attr"`
+	Times    []time.Time `xml:"time"`
+	Until    *time.Time  `xml:"until"`
+	Severity Severity    `xml:"severity,attr,omitempty"`
+	Addr     netip.Addr  `xml:"addr"`
+}
+
+// Severity is written as its name by its text methods
+type Severity int
+
+const (
+	Low Severity = iota
+	High
+)
+
+// MarshalText implements encoding.TextMarshaler
+func (s Severity) MarshalText() ([]byte, error) {
+	switch s {
+	case Low:
+		return []byte("low"), nil
+	case High:
+		return []byte("high"), nil
+	}
+	return nil, fmt.Errorf("invalid severity %d", int(s))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*s = Low
+	case "high":
+		*s = High
+	default:
+		return fmt.Errorf("invalid severity %q", text)
+	}
+	return nil
+}
+
+// Schedule has times of a layout and fields of converter functions
+type Schedule struct {
+	Day   time.Time `xml:"day,attr" layout:"2006-01-02"`
+	Codes []Code    `xml:"code"`
+	Event *Event    `xml:"event"`
+}
+
+// Code is a number written in hexadecimal by its converters
+type Code uint32
+
+//runxml:converter
+func parseCode(text []byte) (Code, error) {
+	n, err := strconv.ParseUint(string(text), 16, 32)
+	return Code(n), err
+}
+
+//runxml:converter
+func appendCode(b []byte, c Code) ([]byte, error) {
+	return strconv.AppendUint(b, uint64(c), 16), nil
+}
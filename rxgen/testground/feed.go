@@ -0,0 +1,45 @@
+package runxml
+
+import "encoding/xml"
+
+// Feed has fields mapped by the options of xml tags
+type Feed struct {
+	XMLName  xml.Name `xml:"urn:feed feed"`
+	Lang     string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Rel      string   `xml:"urn:x/link rel,attr,omitempty"`
+	Type     string   `xml:"urn:x/link type,attr,omitempty"`
+	Title    string   `xml:"head>title"`
+	Subtitle *string  `xml:"head>subtitle"`
+	Updated  string   `xml:"head>meta>updated,omitempty"`
+	Authors  []string `xml:"authors>name"`
+	Note     string   `xml:",comment"`
+	Entries  []Entry  `xml:"entry"`
+	Ignored  string   `xml:"-"`
+	Meta
+}
+
+// Meta is embedded in a Feed
+type Meta struct {
+	Generator string `xml:"generator,attr"`
+	Rights    string `xml:"rights"`
+}
+
+// Entry is an entry of a Feed, recording the name of its element
+type Entry struct {
+	XMLName xml.Name
+	Id      int    `xml:"id,attr"`
+	Text    string `xml:",chardata"`
+	Link    *Link  `xml:"urn:link link"`
+}
+
+// Link is the link of an Entry
+type Link struct {
+	Href string `xml:"href,attr"`
+	Body string `xml:",cdata"`
+}
+
+// Fragment holds its content as XML
+type Fragment struct {
+	XMLName xml.Name `xml:"fragment"`
+	Inner   []byte   `xml:",innerxml"`
+}
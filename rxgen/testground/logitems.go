@@ -8,10 +8,28 @@ type Logitem struct {
 	XIF    TestInterface `json:"iface"`
 }
 
+// TestInterface is the content of a Logitem, told apart by its element
+//
+//runxml:implementation *Apple apple
+//runxml:implementation Banana banana
 type TestInterface interface {
 	IFFunc()
 }
 
+// Apple is a TestInterface named by an attribute
+type Apple struct {
+	Name string `xml:"name,attr"`
+}
+
+func (a *Apple) IFFunc() {}
+
+// Banana is a TestInterface with a length
+type Banana struct {
+	Length float64 `xml:"length"`
+}
+
+func (b Banana) IFFunc() {}
+
 type FruitDesc struct {
 	Name       string
 	TastesGood bool
@@ -0,0 +1,31 @@
+package runxml
+
+// Record has fields of each kind mapped by rxgen
+//
+//runxml:record
+type Record struct {
+	Key      string   `xml:"key,attr"`
+	Version  *uint16  `xml:"version,attr"`
+	Hidden   bool     `xml:"hidden,attr,omitempty"`
+	Title    string   `xml:"title"`
+	Note     string   `xml:"note,omitempty"`
+	Count    int8     `xml:"count,omitempty"`
+	Ratio    float32  `xml:"ratio"`
+	Data     []byte   `xml:"data"`
+	Tags     []string `xml:"tag"`
+	Parent   *Record  `xml:"parent"`
+	Children []Record `xml:"child"`
+	Item     []*Item
+	Level    Level `xml:"level,omitempty"`
+	internal int
+}
+
+// Item is an element of a Record
+type Item struct {
+	Name  string  `xml:"name,attr"`
+	Value float64 `xml:",attr"`
+	Valid *bool   `xml:"valid"`
+}
+
+// Level is a named numeric type
+type Level int
@@ -0,0 +1,63 @@
+package runxml
+
+import "math"
+
+// Drawing has shapes and labels told apart by their attributes
+type Drawing struct {
+	Title  string  `xml:"title,attr"`
+	Shapes []Shape `xml:"shape"`
+	Main   Shape   `xml:"main>shape"`
+	Labels []Label `xml:"label"`
+}
+
+// Shape is a shape of a Drawing
+//
+//runxml:discriminator xsi:type
+//runxml:implementation *Circle circle
+//runxml:implementation Square square
+type Shape interface {
+	Area() float64
+}
+
+// Circle is a Shape of a radius
+type Circle struct {
+	Radius float64 `xml:"r,attr"`
+}
+
+// Area implements Shape
+func (c *Circle) Area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+// Square is a Shape of a side
+type Square struct {
+	Side float64 `xml:"side"`
+}
+
+// Area implements Shape
+func (s Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+// Label is a label of a Drawing
+//
+//runxml:discriminator type
+//runxml:implementation Caption caption
+//runxml:implementation *Icon icon
+type Label interface {
+	label()
+}
+
+// Caption is a Label of text
+type Caption struct {
+	Text string `xml:",chardata"`
+}
+
+func (Caption) label() {}
+
+// Icon is a Label of an image
+type Icon struct {
+	Src string `xml:"src,attr"`
+}
+
+func (*Icon) label() {}
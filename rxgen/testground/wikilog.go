@@ -0,0 +1,44 @@
+package runxml
+
+import "time"
+
+//go:generate go run github.com/robfordww/runxml/rxgen -type LogItems,Record,Feed,Fragment,Event,Schedule,Logitem,Drawing
+
+// LogItems are the <logitem> elements of a Wikipedia log dump
+//
+//runxml:logitems
+type LogItems []LogItem
+
+// LogItem is a <logitem> of a Wikipedia log dump
+//
+//runxml:logitem
+type LogItem struct {
+	Id          int         `xml:"id"`
+	Timestamp   time.Time   `xml:"timestamp"`
+	Comment     string      `xml:"comment"`
+	Typename    string      `xml:"type"`
+	Logtitle    string      `xml:"logtitle"`
+	Contributor Contributor `xml:"contributor"`
+}
+
+// Contributor is the user who made a logged change
+type Contributor struct {
+	Username string `xml:"username"`
+	Id       string `xml:"id"`
+}
+
+// <logitem>
+// <id>62809477</id>
+// <timestamp>2015-02-27T03:27:44Z</timestamp>
+// <contributor>
+//   <username>ClueBot NG</username>
+//   <id>13286072</id>
+// </contributor>
+// <comment>automatic</comment>
+// <type>review</type>
+// <action>approve-a</action>
+// <logtitle>Jamie Colby</logtitle>
+// <params xml:space="preserve">649036197
+// 647791618
+// 20150227032744</params>
+// </logitem>
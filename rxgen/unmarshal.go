@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"log"
+	"slices"
+	"strings"
+)
+
+// generateUnmarshal writes the UnmarshalRunXML method of a struct or slice type
+func (g *Generator) generateUnmarshal(decl *typeDecl) {
+	g.addImport("github.com/robfordww/runxml")
+	if _, ok := decl.spec.Type.(*ast.StructType); ok {
+		g.unmarshalStruct(decl)
+		return
+	}
+	t := g.resolve(decl.spec.Type)
+	if t.kind != kindSlice {
+		log.Fatalf("type %s is not a struct or slice", decl.name)
+	}
+	// The elements of a slice are read from the child elements named as their type
+	g.Printf("// UnmarshalRunXML appends the child elements of n to x\n")
+	g.Printf("func (x *%s) UnmarshalRunXML(n *runxml.GenericNode) error {\n", decl.name)
+	g.Printf("for c := range n.Children() {\n")
+	if name := g.itemFilter(t.elem); name != "" {
+		g.Printf("if c.NodeType != runxml.Element || string(c.LocalName()) != %q {\n", name)
+	} else {
+		g.Printf("if c.NodeType != runxml.Element {\n")
+	}
+	g.Printf("continue\n}\n")
+	g.unmarshalElement("*x", t, decl.name)
+	g.Printf("}\nreturn nil\n}\n\n")
+}
+
+// itemFilter returns the name of the elements read as values of a slice: the name of the
+// XMLName tag or //runxml: directive of their type, or "" for all elements
+func (g *Generator) itemFilter(t *valueType) string {
+	if name, _ := g.xmlNameTag(t); name != "" {
+		return name
+	}
+	if elem := g.elementType(t); elem != nil {
+		return elem.element
+	}
+	return ""
+}
+
+func (g *Generator) unmarshalStruct(decl *typeDecl) {
+	info := g.structInfo(decl)
+	g.Printf("// UnmarshalRunXML sets the fields of x from the attributes and content of n\n")
+	g.Printf("func (x *%s) UnmarshalRunXML(n *runxml.GenericNode) error {\n", decl.name)
+	if xn := info.xmlName; xn != nil {
+		if xn.xmlName != "" {
+			g.addImport("fmt")
+			g.Printf("if string(n.LocalName()) != %q {\n", xn.xmlName)
+			g.Printf("return fmt.Errorf(\"expected element type <%s> but have <%%s>\", n.LocalName())\n}\n", xn.xmlName)
+		}
+		if xn.xmlns != "" {
+			g.addImport("fmt")
+			g.Printf("if string(n.NamespaceURI()) != %q {\n", xn.xmlns)
+			g.Printf("return fmt.Errorf(\"expected element <%s> in name space %s but have %%q\", n.NamespaceURI())\n}\n", xn.xmlName, xn.xmlns)
+		}
+		if info.nameType {
+			g.addImport("encoding/xml")
+			g.Printf("x.%s = xml.Name{Space: string(n.NamespaceURI()), Local: string(n.LocalName())}\n", xn.name)
+		}
+	}
+	var attrs, elements []field
+	var chardata, comment, innerxml *field
+	for _, f := range info.fields {
+		switch f.mode {
+		case modeAttr:
+			attrs = append(attrs, f)
+		case modeElement:
+			elements = append(elements, f)
+		case modeCDATA, modeCharData:
+			if chardata == nil {
+				chardata = &f
+			}
+		case modeComment:
+			if comment == nil {
+				comment = &f
+			}
+		case modeInnerXML:
+			if innerxml == nil {
+				innerxml = &f
+			}
+		}
+	}
+	if len(attrs) > 0 {
+		// Every attribute field of the name is set, as by encoding/xml
+		g.Printf("for a := range n.Attributes() {\n")
+		g.Printf("switch string(a.LocalName()) {\n")
+		for _, name := range distinctNames(attrs, 0) {
+			g.Printf("case %q:\n", name)
+			for _, f := range attrs {
+				if f.xmlName != name {
+					continue
+				}
+				if f.xmlns != "" {
+					g.Printf("if string(a.NamespaceURI()) == %q {\n", f.xmlns)
+				}
+				target := "x." + f.name
+				if f.typ.kind == kindPointer {
+					g.Printf("%s = new(%s)\n", target, f.typ.elem.name)
+					g.unmarshalText("*"+target, f.typ.elem, "a.Value", decl.name+"."+f.name)
+				} else {
+					g.unmarshalText(target, f.typ, "a.Value", decl.name+"."+f.name)
+				}
+				if f.xmlns != "" {
+					g.Printf("}\n")
+				}
+			}
+		}
+		g.Printf("}\n}\n")
+	}
+	if chardata != nil {
+		g.unmarshalText("x."+chardata.name, chardata.typ, "n.Text()", decl.name+"."+chardata.name)
+	}
+	if comment != nil {
+		// The comments of the element are joined
+		g.Printf("var comment []byte\n")
+		g.Printf("for c := range n.Children() {\nif c.NodeType == runxml.Comment {\n")
+		g.Printf("comment = append(comment, c.Value...)\n}\n}\n")
+		if comment.typ.kind == kindString {
+			g.Printf("x.%s = %s(comment)\n", comment.name, comment.typ.name)
+		} else {
+			g.Printf("x.%s = comment\n", comment.name)
+		}
+	}
+	if innerxml != nil {
+		// The content of the element is written again, as the input is not kept
+		g.addImport("fmt")
+		g.Printf("var inner []byte\n")
+		g.Printf("for c := range n.Children() {\nvar err error\n")
+		g.Printf("if inner, err = c.AppendText(inner); err != nil {\n")
+		g.Printf("return fmt.Errorf(\"%s.%s: %%w\", err)\n}\n}\n", decl.name, innerxml.name)
+		if innerxml.typ.kind == kindString {
+			g.Printf("x.%s = %s(inner)\n", innerxml.name, innerxml.typ.name)
+		} else {
+			g.Printf("x.%s = inner\n", innerxml.name)
+		}
+	}
+	if len(elements) > 0 {
+		g.unmarshalChildren(decl, elements, 0, "n")
+	}
+	g.Printf("return nil\n}\n\n")
+}
+
+// unmarshalChildren writes the loop reading the child elements of parent into the fields,
+// whose paths of parent elements are the same to the depth. The first field matching an
+// element reads it. The elements of a path are read as their children are matched to the
+// fields of the path.
+func (g *Generator) unmarshalChildren(decl *typeDecl, fields []field, depth int, parent string) {
+	g.Printf("for c := range %s.Children() {\n", parent)
+	g.Printf("if c.NodeType != runxml.Element {\ncontinue\n}\n")
+	g.Printf("switch string(c.LocalName()) {\n")
+	for _, name := range distinctNames(fields, depth) {
+		g.Printf("case %q:\n", name)
+		// Fields matching the element, and the fields within it of a path
+		var conds []string
+		var matches []*field
+		var path []field
+		pathIndex := -1
+		for _, f := range fields {
+			switch {
+			case len(f.parents) == depth && slices.Contains(f.elementNames(), name):
+				conds = append(conds, namespaceCond(f.xmlns))
+				matches = append(matches, &f)
+			case len(f.parents) > depth && f.parents[depth] == name:
+				if pathIndex < 0 {
+					pathIndex = len(conds)
+					conds = append(conds, "")
+					matches = append(matches, nil)
+				}
+				if f.xmlns == "" {
+					conds[pathIndex] = "true"
+				} else if conds[pathIndex] != "true" {
+					conds[pathIndex] = strings.TrimPrefix(conds[pathIndex]+" || "+namespaceCond(f.xmlns), " || ")
+				}
+				path = append(path, f)
+			}
+		}
+		for i, cond := range conds {
+			switch {
+			case cond == "true" && i > 0:
+				g.Printf("} else {\n")
+			case cond != "true" && i > 0:
+				g.Printf("} else if %s {\n", cond)
+			case cond != "true":
+				g.Printf("if %s {\n", cond)
+			}
+			if f := matches[i]; f != nil {
+				g.unmarshalElement("x."+f.name, f.typ, decl.name+"."+f.name)
+			} else {
+				g.unmarshalChildren(decl, path, depth+1, "c")
+			}
+			if cond == "true" {
+				break
+			}
+		}
+		if conds[0] != "true" {
+			g.Printf("}\n")
+		}
+	}
+	g.Printf("}\n}\n")
+}
+
+// distinctNames returns the names of the elements or attributes matched by fields, in order,
+// where the parents of fields at the depth name an element
+func distinctNames(fields []field, depth int) []string {
+	var names []string
+	for _, f := range fields {
+		matched := []string{f.xmlName}
+		if len(f.parents) > depth {
+			matched = f.parents[depth : depth+1]
+		} else if f.mode == modeElement {
+			matched = f.elementNames()
+		}
+		for _, name := range matched {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// elementNames returns the names of the elements of an element field: those of the
+// implementations of an interface told apart by their element, or else the field name
+func (f *field) elementNames() []string {
+	t := f.typ
+	for t.kind == kindSlice || t.kind == kindPointer {
+		t = t.elem
+	}
+	if t.kind != kindInterface || t.union.discriminator != "element" {
+		return []string{f.xmlName}
+	}
+	var names []string
+	for _, impl := range t.union.impls {
+		names = append(names, impl.value)
+	}
+	return names
+}
+
+// namespaceCond returns the condition of the element c being in the namespace, which is
+// always true for fields without a namespace
+func namespaceCond(xmlns string) string {
+	if xmlns == "" {
+		return "true"
+	}
+	return fmt.Sprintf("string(c.NamespaceURI()) == %q", xmlns)
+}
+
+// unmarshalElement writes the code setting target from the element c. Slices append the
+// value of the element.
+func (g *Generator) unmarshalElement(target string, t *valueType, context string) {
+	switch t.kind {
+	case kindStruct:
+		g.enqueue(t.name)
+		g.Printf("if err := %s.UnmarshalRunXML(c); err != nil {\nreturn err\n}\n", target)
+	case kindPointer:
+		g.Printf("%s = new(%s)\n", target, t.elem.name)
+		if t.elem.kind == kindStruct {
+			g.unmarshalElement(target, t.elem, context)
+		} else {
+			g.unmarshalElement("*"+target, t.elem, context)
+		}
+	case kindSlice:
+		g.Printf("var v %s\n", t.elem.name)
+		g.unmarshalElement("v", t.elem, context)
+		g.Printf("%s = append(%s, v)\n", target, target)
+	case kindInterface:
+		g.unmarshalInterface(target, t, context)
+	default:
+		g.unmarshalText(target, t, "c.Text()", context)
+	}
+}
+
+// unmarshalInterface writes the code setting target, of an interface type, to the
+// implementation told apart by the element c or its attribute. Elements of other names are
+// skipped, and other values of the attribute are an error.
+func (g *Generator) unmarshalInterface(target string, t *valueType, context string) {
+	u := t.union
+	switch u.discriminator {
+	case "element":
+		g.Printf("switch string(c.LocalName()) {\n")
+	default:
+		cond := "len(a.NamespaceURI()) == 0"
+		if u.discriminator == "xsi:type" {
+			cond = fmt.Sprintf("string(a.NamespaceURI()) == %q", xsiURL)
+		}
+		g.Printf("var kind []byte\nfor a := range c.Attributes() {\n")
+		g.Printf("if string(a.LocalName()) == \"type\" && %s {\nkind = a.Value\n}\n}\n", cond)
+		g.Printf("switch string(kind) {\n")
+	}
+	for _, impl := range u.impls {
+		g.enqueue(impl.name())
+		g.Printf("case %q:\n", impl.value)
+		g.Printf("p := new(%s)\n", impl.name())
+		g.Printf("if err := p.UnmarshalRunXML(c); err != nil {\nreturn err\n}\n")
+		if strings.HasPrefix(impl.typ, "*") {
+			g.Printf("%s = p\n", target)
+		} else {
+			g.Printf("%s = *p\n", target)
+		}
+	}
+	if u.discriminator == "element" {
+		g.Printf("default:\ncontinue\n}\n")
+	} else {
+		g.addImport("fmt")
+		g.Printf("default:\nreturn fmt.Errorf(\"%s: unknown %s %%q\", kind)\n}\n", context, u.discriminator)
+	}
+}
+
+// unmarshalText writes the code setting target, of a scalar type, from the text src.
+// Numbers, booleans and times are trimmed of whitespace, and are zero if empty.
+func (g *Generator) unmarshalText(target string, t *valueType, src, context string) {
+	switch t.kind {
+	case kindString:
+		g.Printf("%s = %s(%s)\n", target, t.name, src)
+		return
+	case kindBytes:
+		g.addImport("bytes")
+		g.Printf("%s = %s(bytes.Clone(%s))\n", target, t.name, src)
+		return
+	case kindText:
+		g.addImport("bytes")
+		g.addImport("fmt")
+		g.Printf("if err := %s.UnmarshalText(bytes.Clone(%s)); err != nil {\n", operand(target), src)
+		g.Printf("return fmt.Errorf(\"%s: %%w\", err)\n}\n", context)
+		return
+	case kindConverter:
+		g.addImport("fmt")
+		g.Printf("if p, err := %s(%s); err != nil {\n", t.conv.decode, src)
+		g.Printf("return fmt.Errorf(\"%s: %%w\", err)\n} else {\n%s = p\n}\n", context, target)
+		return
+	}
+	g.addImport("bytes")
+	g.addImport("fmt")
+	g.addImport("strconv")
+	g.Printf("if s := bytes.TrimSpace(%s); len(s) > 0 {\n", src)
+	value, zero := t.name+"(p)", "0"
+	switch t.kind {
+	case kindBool:
+		g.Printf("p, err := strconv.ParseBool(string(s))\n")
+		zero = "false"
+	case kindInt:
+		g.Printf("p, err := strconv.ParseInt(string(s), 10, %d)\n", t.bits)
+	case kindUint:
+		g.Printf("p, err := strconv.ParseUint(string(s), 10, %d)\n", t.bits)
+	case kindFloat:
+		g.Printf("p, err := strconv.ParseFloat(string(s), %d)\n", t.bits)
+	case kindTime:
+		g.addImport("time")
+		g.Printf("p, err := time.Parse(%s, string(s))\n", parseLayout(t))
+		value, zero = "p", "time.Time{}"
+	}
+	g.Printf("if err != nil {\nreturn fmt.Errorf(\"%s: %%w\", err)\n}\n", context)
+	g.Printf("%s = %s\n", target, value)
+	g.Printf("} else {\n%s = %s\n}\n", target, zero)
+}
+
+// parseLayout returns the expression of the layout of times read, by default RFC 3339
+func parseLayout(t *valueType) string {
+	if t.layout != "" {
+		return fmt.Sprintf("%q", t.layout)
+	}
+	return "time.RFC3339"
+}
+
+// operand returns target as the operand of a selector
+func operand(target string) string {
+	if strings.HasPrefix(target, "*") {
+		return "(" + target + ")"
+	}
+	return target
+}
@@ -0,0 +1,220 @@
+package runxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// readBufferSize is the minimum number of bytes read from the input of ParseReader at a time
+const readBufferSize = 64 << 10
+
+// ParseReader parses a document read from rd in chunks. Only a window of the input is held in
+// the parser's buffer; the rest of the memory is used by the nodes of the returned tree, which
+// refer to the data of the buffers they were parsed from.
+func (r *RunXML) ParseReader(rd io.Reader) (*GenericNode, error) {
+	return r.ParseReaderFunc(rd, nil)
+}
+
+// ParseReaderFunc parses a document read from rd in chunks, calling fn with each child node of
+// the document element as soon as it is complete. The node is removed from the tree after fn
+// returns, and its nodes and the buffers only it refers to are reused for the following children,
+// so the memory in use is bounded by the largest child rather than the document. fn must copy
+// what it needs, as the node, its descendants and their names and values are overwritten after
+// it returns. Parsing stops at the first error returned by fn. A nil fn keeps the children.
+func (r *RunXML) ParseReaderFunc(rd io.Reader, fn func(*GenericNode) error) (*GenericNode, error) {
+	input, err := r.decodeReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	r.reset(r.BaseURI)
+	r.input = &lineEndReader{r: input}
+	r.emit = fn
+	defer func() {
+		r.input = nil
+		r.emit = nil
+	}()
+	return r.parseDocument()
+}
+
+// streamState holds the memory of the children of the document element emitted by
+// ParseReaderFunc, which is reused for the following children
+type streamState struct {
+	marked     bool      // The arena positions before the first child have been recorded
+	nodes      arenaMark // Position of the node arena before the first child
+	attributes arenaMark // Position of the attribute arena before the first child
+	fresh      bool      // The buffer was read after the first child, so only children refer to it
+	retired    [][]byte  // Buffers replaced since the children were last emitted
+	spare      [][]byte  // Buffers no nodes refer to, reused by fill
+}
+
+// buffer returns an empty buffer with a capacity of at least size, reusing a spare one if possible
+func (s *streamState) buffer(size int) []byte {
+	for len(s.spare) > 0 {
+		b := s.spare[len(s.spare)-1]
+		s.spare = s.spare[:len(s.spare)-1]
+		if cap(b) >= size {
+			return b[:0]
+		}
+	}
+	return make([]byte, 0, size)
+}
+
+// fill makes sure the buffer holds the text at the position and the markup construct following
+// it, reading more of the input of ParseReader when needed. The parsed data is not copied to the
+// new buffer, but stays in the previous one as long as nodes refer to it; buffers only emitted
+// children refer to are reused.
+func (r *RunXML) fill() error {
+	if r.input == nil || len(r.openEntities) > 0 { // not reading the input, but an entity
+		return nil
+	}
+	for !r.inputEOF {
+		rest := r.data[r.position:]
+		// One byte of lookahead after the construct, as when parsing a complete document
+		if end := constructEnd(rest); end >= 0 && end < len(rest) {
+			return nil
+		}
+		buf := r.stream.buffer(len(rest) + max(len(rest), readBufferSize))[:len(rest)]
+		copy(buf, rest)
+		if r.stream.fresh {
+			r.stream.retired = append(r.stream.retired, r.data[:0])
+		}
+		r.stream.fresh = r.stream.marked
+		n, err := io.ReadFull(r.input, buf[len(rest):cap(buf)])
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			r.inputEOF = true
+		} else if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		r.data = buf[:len(rest)+n]
+		r.position = 0
+		r.docSize += n
+	}
+	return nil
+}
+
+// constructEnd returns the length of the text at the start of b and the markup construct
+// following it, or -1 if b ends before the construct does
+func constructEnd(b []byte) int {
+	start := bytes.IndexByte(b, '<')
+	if start < 0 || len(b)-start < len("<!DOCTYPE") {
+		return -1
+	}
+	var end int
+	switch m := b[start:]; {
+	case bytes.HasPrefix(m, []byte("<!--")):
+		end = terminatorEnd(m, 4, "-->")
+	case bytes.HasPrefix(m, []byte("<![CDATA[")):
+		end = terminatorEnd(m, 9, "]]>")
+	case bytes.HasPrefix(m, []byte("<?")):
+		end = terminatorEnd(m, 2, "?>")
+	case bytes.HasPrefix(m, []byte("<!")):
+		end = declarationEnd(m)
+	default:
+		end = tagEnd(m)
+	}
+	if end < 0 {
+		return -1
+	}
+	return start + end
+}
+
+// terminatorEnd returns the position after the first terminator in b from i, or -1
+func terminatorEnd(b []byte, i int, terminator string) int {
+	end := bytes.Index(b[i:], []byte(terminator))
+	if end < 0 {
+		return -1
+	}
+	return i + end + len(terminator)
+}
+
+// tagEnd returns the position after the '>' ending the tag at the start of b, skipping
+// quoted attribute values, or -1
+func tagEnd(b []byte) int {
+	for i := 1; i < len(b); i++ {
+		switch b[i] {
+		case '"', '\'':
+			end := bytes.IndexByte(b[i+1:], b[i])
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case '>':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// declarationEnd returns the position after the '>' ending the declaration at the start of
+// b, including the internal subset of a document type declaration, or -1
+func declarationEnd(b []byte) int {
+	depth := 0
+	for i := 2; i < len(b); i++ {
+		switch b[i] {
+		case '"', '\'':
+			end := bytes.IndexByte(b[i+1:], b[i])
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case '<':
+			var end int
+			switch {
+			case bytes.HasPrefix(b[i:], []byte("<!--")):
+				end = terminatorEnd(b[i:], 4, "-->")
+			case bytes.HasPrefix(b[i:], []byte("<?")):
+				end = terminatorEnd(b[i:], 2, "?>")
+			default:
+				continue
+			}
+			if end < 0 {
+				return -1
+			}
+			i += end - 1
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '>':
+			if depth <= 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// emitChildren passes the children of the document element to the function of
+// ParseReaderFunc, removes them from the tree and reuses their memory
+func (r *RunXML) emitChildren(element *GenericNode) error {
+	if r.emit == nil || element.Parent == nil || element.Parent.NodeType != Document {
+		return nil
+	}
+	s := &r.stream
+	if !s.marked {
+		// Called before the first child is parsed, after the document element and its attributes
+		s.nodes, s.attributes = r.nodeArena.mark(), r.attributeArena.mark()
+		s.marked = true
+	}
+	if element.firstChild == nil {
+		return nil
+	}
+	for c := element.firstChild; c != nil; {
+		next := c.next
+		c.prev, c.next = nil, nil
+		if err := r.emit(c); err != nil {
+			return err
+		}
+		c = next
+	}
+	element.RemoveAllNodes()
+	element.Value = nil // the text of a child
+	r.nodeArena.rewindTo(s.nodes)
+	r.attributeArena.rewindTo(s.attributes)
+	s.spare = append(s.spare, s.retired...)
+	clear(s.retired)
+	s.retired = s.retired[:0]
+	return nil
+}
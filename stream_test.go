@@ -0,0 +1,210 @@
+package runxml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// streamTestDoc returns a document of several read buffers, with every kind of node
+func streamTestDoc(items int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0"?>
+<!DOCTYPE log [
+	<!-- the ']>' in this comment does not end the subset -->
+	<!ENTITY sep "&#x2014;">
+	<!ENTITY item '<item id="x">a "quoted" > value</item>'>
+]>
+<log>`)
+	for i := 0; i < items; i++ {
+		fmt.Fprintf(&b, "\n\t<item id=\"%d\" note='a > b'>value &sep; %d<!-- comment --><![CDATA[<cdata>]]><?pi data?></item>", i, i)
+		if i%100 == 0 {
+			b.WriteString("&item;")
+		}
+	}
+	b.WriteString("\n</log>\n")
+	return b.Bytes()
+}
+
+func TestParseReader(t *testing.T) {
+	xml := streamTestDoc(5000)
+	if len(xml) < 4*readBufferSize {
+		t.Fatal("test document should span several buffers")
+	}
+	doc, err := NewDefaultRunXML().Parse(bytes.Clone(xml))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var expected strings.Builder
+	writeTree(&expected, doc.GetLastChild())
+	doc, err = NewDefaultRunXML().ParseReader(iotest.HalfReader(bytes.NewReader(xml)))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var sb strings.Builder
+	writeTree(&sb, doc.GetLastChild())
+	if sb.String() != expected.String() {
+		t.Error("tree differs from the tree of Parse")
+	}
+}
+
+func TestParseReaderFunc(t *testing.T) {
+	xml := streamTestDoc(5000)
+	items := 0
+	doc, err := NewDefaultRunXML().ParseReaderFunc(bytes.NewReader(xml), func(n *GenericNode) error {
+		if n.NodeType != Element {
+			return nil
+		}
+		if string(n.Parent.Name) != "log" || n.GetNextSibling() != nil {
+			t.Fatal("node should be removed from the tree")
+		}
+		items++
+		return nil
+	})
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if items != 5050 {
+		t.Errorf("expected 5050 items, found %v", items)
+	}
+	if doc.GetLastChild().GetFirstChild() != nil {
+		t.Error("expected the children of the document element to be removed")
+	}
+	// Errors of the function stop parsing
+	stop := errors.New("stop")
+	_, err = NewDefaultRunXML().ParseReaderFunc(bytes.NewReader(xml), func(n *GenericNode) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected error %v, found %v", stop, err)
+	}
+	// Read errors are reported
+	_, err = NewDefaultRunXML().ParseReader(iotest.TimeoutReader(bytes.NewReader(xml)))
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("expected error %v, found %v", iotest.ErrTimeout, err)
+	}
+}
+
+// repeatReader reads s n times
+type repeatReader struct {
+	s      string
+	n, off int
+}
+
+func (rr *repeatReader) Read(p []byte) (int, error) {
+	if rr.n == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, rr.s[rr.off:])
+	rr.off += n
+	if rr.off == len(rr.s) {
+		rr.off = 0
+		rr.n--
+	}
+	return n, nil
+}
+
+// TestParseReaderFuncMemory checks that the emitted children are reused, so the heap does not
+// grow with the document
+func TestParseReaderFuncMemory(t *testing.T) {
+	// The children parsed from reused memory equal the children of Parse
+	xml := streamTestDoc(5000)
+	doc, err := NewDefaultRunXML().Parse(bytes.Clone(xml))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var expected, sb strings.Builder
+	for c := doc.GetLastChild().GetFirstChild(); c != nil; c = c.GetNextSibling() {
+		writeTree(&expected, c)
+	}
+	_, err = NewDefaultRunXML().ParseReaderFunc(bytes.NewReader(xml), func(n *GenericNode) error {
+		writeTree(&sb, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if sb.String() != expected.String() {
+		t.Error("emitted children differ from the children of Parse")
+	}
+	// The heap in use after parsing ten times the children is about the same
+	const item = "\n\t<item id=\"1\" note='a &amp; b'>value &sep;<!-- comment --><![CDATA[<cdata>]]><sub>text</sub></item>"
+	peak := func(items int) uint64 {
+		input := io.MultiReader(strings.NewReader(`<!DOCTYPE log [<!ENTITY sep "&#x2014;">]><log>`),
+			&repeatReader{s: item, n: items}, strings.NewReader("</log>"))
+		var m runtime.MemStats
+		var heap uint64
+		count := 0
+		_, err := NewDefaultRunXML().ParseReaderFunc(input, func(n *GenericNode) error {
+			if count++; count%10000 == 0 {
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > heap {
+					heap = m.HeapAlloc
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal("should not fail", err)
+		}
+		if count != items {
+			t.Fatalf("expected %v children, found %v", items, count)
+		}
+		return heap
+	}
+	small, large := peak(20000), peak(200000)
+	if large > small+1<<20 {
+		t.Errorf("heap grew from %v to %v bytes with the number of children", small, large)
+	}
+}
+
+// TestParseReaderFiles compares the trees of the conformance test files parsed from a reader
+func TestParseReaderFiles(t *testing.T) {
+	files, _ := filepath.Glob("xmltestfiles/xmlconf/xmltest/valid/sa/*.xml")
+	japanese, _ := filepath.Glob("xmltestfiles/xmlconf/japanese/*.xml")
+	for _, fn := range append(files, japanese...) {
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewDefaultRunXML()
+		doc, err := r.Parse(bytes.Clone(b))
+		if err != nil {
+			t.Fatal(fn, err)
+		}
+		var expected strings.Builder
+		writeTree(&expected, doc)
+		doc, err = r.ParseReader(iotest.OneByteReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatal(fn, err)
+		}
+		var sb strings.Builder
+		writeTree(&sb, doc)
+		if sb.String() != expected.String() {
+			t.Errorf("%v: tree differs from the tree of Parse", fn)
+		}
+	}
+}
+
+func TestParseReaderLineEnds(t *testing.T) {
+	xml := []byte("<doc a='x\r\ny\rz'>a\r\nb\rc&#13;\n\r</doc>\r\n")
+	doc, err := NewDefaultRunXML().ParseReader(iotest.OneByteReader(bytes.NewReader(xml)))
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	root := doc.GetLastChild()
+	if v := string(root.GetFirstChild().Value); v != "a\nb\nc\r\n\n" {
+		t.Errorf("unexpected text value %q", v)
+	}
+	if v := string(root.GetAttributes()[0].Value); v != "x y z" {
+		t.Errorf("unexpected attribute value %q", v)
+	}
+}
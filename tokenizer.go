@@ -0,0 +1,232 @@
+//go:generate stringer -type=TokenType
+
+package runxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// TokenType is the kind of a token returned by Tokenizer
+type TokenType int
+
+// TokenType enum values
+const (
+	StartElementToken TokenType = iota //!< The start of an element. Name contains element name. Attributes contains its attributes.
+	EndElementToken                    //!< The end of an element, also following an empty-element tag. Name contains element name.
+	DataToken                          //!< Text. Value contains data text.
+	CdataToken                         //!< A CDATA section. Value contains data text.
+	CommentToken                       //!< A comment. Value contains comment text.
+	DeclarationToken                   //!< The XML declaration. Declaration parameters are in Attributes.
+	DoctypeToken                       //!< A DOCTYPE. Value contains DOCTYPE text.
+	PiToken                            //!< A PI. Name contains target. Value contains instructions.
+	EntityRefToken                     //!< A reference to an entity declared in the parts of the DTD that were not read. Name contains entity name.
+)
+
+// Token is a node of the document returned by Tokenizer. Name and Value refer to the data
+// being parsed, as the fields of nodes do. Attributes are reused by the next call to Next.
+type Token struct {
+	Type       TokenType
+	Name       []byte
+	Value      []byte
+	Attributes []*AttributeNode
+}
+
+// Tokenizer returns the nodes of a document one at a time, without building a tree. It keeps
+// its state in the RunXML creating it, which must not parse other documents meanwhile.
+type Tokenizer struct {
+	r     *RunXML
+	doc   *GenericNode // Parent of the document element
+	empty bool         // The last token was an empty-element tag, whose end is next
+	err   error        // Error that ended tokenization
+}
+
+// NewTokenizer returns a Tokenizer of the document in b
+func (r *RunXML) NewTokenizer(b []byte) (*Tokenizer, error) {
+	data, err := r.decodeDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	r.reset(r.BaseURI)
+	r.data = data
+	r.docSize = len(b)
+	r.events.reuse = true
+	return &Tokenizer{r: r, doc: r.newNode(Document)}, nil
+}
+
+// Next returns the next token of the document, or io.EOF at its end
+func (t *Tokenizer) Next() (Token, error) {
+	if t.err != nil {
+		return Token{}, t.err
+	}
+	tok, err := t.next()
+	if err != nil {
+		if err != io.EOF {
+			err = t.r.contextError(err)
+		}
+		t.err = err
+	}
+	return tok, err
+}
+
+func (t *Tokenizer) next() (Token, error) {
+	r := t.r
+	if t.empty {
+		t.empty = false
+		return t.endElement(), nil
+	}
+	for {
+		if r.events.depth == 0 {
+			// Outside the document element
+			r.events.used = 0
+			if r.position >= len(r.data) {
+				return Token{}, io.EOF
+			}
+			r.skip(lookupWhitespace)
+			if r.position == len(r.data)-1 {
+				r.position++
+				return Token{}, io.EOF // normal end of file
+			}
+			if c := r.getCurrentByte(); c != '<' {
+				return Token{}, fmt.Errorf("expected '<', but found %q", rune(c))
+			}
+			r.position++
+			if tok, ok, err := t.markup(t.doc); ok || err != nil {
+				return tok, err
+			}
+			continue
+		}
+		element := r.events.elements[r.events.depth-1]
+		kind, value, err := r.scanContent()
+		if err != nil {
+			return Token{}, err
+		}
+		switch kind {
+		case contentText:
+			return Token{Type: DataToken, Value: value}, nil
+		case contentMarkup:
+			if tok, ok, err := t.markup(element); ok || err != nil {
+				return tok, err
+			}
+		case contentEndTag:
+			if err := r.parseEndTag(element); err != nil {
+				return Token{}, err
+			}
+			return t.endElement(), nil
+		case contentReference:
+			ref, err := r.includeEntity(element)
+			if err != nil {
+				return Token{}, err
+			}
+			if ref != nil {
+				return Token{Type: EntityRefToken, Name: ref.Name}, nil
+			}
+		case contentEntityEnd:
+			if err := r.endEntity(element); err != nil {
+				return Token{}, err
+			}
+		}
+	}
+}
+
+// markup returns the token of the markup after a '<'. Unrecognized markup is skipped,
+// returning false.
+func (t *Tokenizer) markup(parent *GenericNode) (Token, bool, error) {
+	r := t.r
+	if c := r.getCurrentByte(); c != '?' && c != '!' {
+		element, empty, err := r.parseStartTag(parent)
+		if err != nil {
+			return Token{}, false, err
+		}
+		t.empty = empty
+		return Token{Type: StartElementToken, Name: element.Name, Attributes: r.attributeList(element)}, true, nil
+	}
+	node, err := r.parseNode(parent)
+	if err != nil || node == nil {
+		return Token{}, false, err
+	}
+	switch node.NodeType {
+	case Declaration:
+		if parent != t.doc {
+			return Token{}, false, fmt.Errorf("XML declaration not allowed in content")
+		}
+		return Token{Type: DeclarationToken, Attributes: r.attributeList(node)}, true, nil
+	case Cdata:
+		if parent == t.doc {
+			return Token{}, false, fmt.Errorf("CDATA section not allowed outside the document element")
+		}
+		return Token{Type: CdataToken, Value: node.Value}, true, nil
+	case Comment:
+		return Token{Type: CommentToken, Value: node.Value}, true, nil
+	case Doctype:
+		return Token{Type: DoctypeToken, Value: node.Value}, true, nil
+	case Pi:
+		return Token{Type: PiToken, Name: node.Name, Value: node.Value}, true, nil
+	}
+	return Token{}, false, fmt.Errorf("unexpected %v node", node.NodeType)
+}
+
+// endElement returns the end of the current element, and releases it
+func (t *Tokenizer) endElement() Token {
+	r := t.r
+	element := r.events.elements[r.events.depth-1]
+	r.endElement(element)
+	return Token{Type: EndElementToken, Name: element.Name}
+}
+
+// TokenReader returns an encoding/xml.TokenReader of the tokens, for use with
+// xml.NewTokenDecoder. Names are split in prefix and local name, as by xml.Decoder.RawToken.
+func (t *Tokenizer) TokenReader() xml.TokenReader {
+	return tokenReader{t}
+}
+
+// tokenReader converts the tokens of a Tokenizer to encoding/xml tokens
+type tokenReader struct {
+	t *Tokenizer
+}
+
+// Token returns the next token as an encoding/xml token
+func (tr tokenReader) Token() (xml.Token, error) {
+	tok, err := tr.t.Next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.Type {
+	case StartElementToken:
+		attrs := make([]xml.Attr, len(tok.Attributes))
+		for i, a := range tok.Attributes {
+			attrs[i] = xml.Attr{Name: xmlName(a.Name), Value: string(a.Value)}
+		}
+		return xml.StartElement{Name: xmlName(tok.Name), Attr: attrs}, nil
+	case EndElementToken:
+		return xml.EndElement{Name: xmlName(tok.Name)}, nil
+	case DataToken, CdataToken:
+		return xml.CharData(tok.Value), nil
+	case CommentToken:
+		return xml.Comment(tok.Value), nil
+	case DeclarationToken:
+		var inst []byte
+		for _, a := range tok.Attributes {
+			if len(inst) > 0 {
+				inst = append(inst, ' ')
+			}
+			inst = fmt.Appendf(inst, "%s=%q", a.Name, a.Value)
+		}
+		return xml.ProcInst{Target: "xml", Inst: inst}, nil
+	case DoctypeToken:
+		return xml.Directive(append([]byte("DOCTYPE"), tok.Value...)), nil
+	case PiToken:
+		return xml.ProcInst{Target: string(tok.Name), Inst: tok.Value}, nil
+	case EntityRefToken:
+		// Like xml.Decoder, which has no token for references it cannot expand
+		return nil, fmt.Errorf("reference to undeclared entity %q", tok.Name)
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok.Type)
+}
+
+// xmlName returns the encoding/xml name of a qualified name, with the prefix as Space
+func xmlName(name []byte) xml.Name {
+	prefix, local := splitQName(name)
+	return xml.Name{Space: string(prefix), Local: string(local)}
+}
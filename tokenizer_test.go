@@ -0,0 +1,166 @@
+package runxml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// replayTokens passes the tokens of a document to a handler, as ParseEvents does
+func replayTokens(t *testing.T, xml []byte, h Handler) error {
+	tz, err := NewDefaultRunXML().NewTokenizer(xml)
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := tz.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok.Type {
+		case StartElementToken:
+			err = h.StartElement(tok.Name, tok.Attributes)
+		case EndElementToken:
+			err = h.EndElement(tok.Name)
+		case DataToken:
+			err = h.CharData(tok.Value)
+		case CdataToken:
+			err = h.CDATA(tok.Value)
+		case CommentToken:
+			err = h.Comment(tok.Value)
+		case PiToken:
+			err = h.ProcessingInstruction(tok.Name, tok.Value)
+		case EntityRefToken:
+			err = h.EntityRef(tok.Name)
+		case DoctypeToken:
+			err = h.Doctype(tok.Value, tz.r.dtd)
+		case DeclarationToken:
+			var v [3][]byte
+			for _, a := range tok.Attributes {
+				switch string(a.Name) {
+				case "version":
+					v[0] = a.Value
+				case "encoding":
+					v[1] = a.Value
+				case "standalone":
+					v[2] = a.Value
+				}
+			}
+			err = h.XMLDecl(v[0], v[1], v[2])
+		default:
+			t.Fatalf("unexpected token type %v", tok.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	files, _ := filepath.Glob("xmltestfiles/xmlconf/xmltest/valid/sa/*.xml")
+	for _, fn := range append(files, "xmltestfiles/xmlconf/japanese/pr-xml-euc-jp.xml") {
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var events, tokens recordingHandler
+		if err := NewDefaultRunXML().ParseEvents(bytes.Clone(b), &events); err != nil {
+			t.Fatal(fn, err)
+		}
+		if err := replayTokens(t, b, &tokens); err != nil {
+			t.Fatal(fn, err)
+		}
+		if tokens.String() != events.String() {
+			t.Errorf("%v: tokens differ from events:\n%v\n%v", fn, tokens.String(), events.String())
+		}
+	}
+}
+
+func TestTokenizerErrors(t *testing.T) {
+	for _, xml := range []string{
+		`<doc>`,
+		`<doc></other>`,
+		`<doc><![CDATA[x]]>`,
+		`<![CDATA[x]]><doc/>`,
+		`<doc><?xml version="1.0"?></doc>`,
+		`<!DOCTYPE doc [<!ENTITY e "<a>">]><doc>&e;</a></doc>`,
+		`<!DOCTYPE doc [<!ENTITY e "</doc><doc>">]><doc>&e;</doc>`,
+		`<doc>&undeclared;</doc>`,
+	} {
+		if err := replayTokens(t, []byte(xml), NopHandler{}); err == nil {
+			t.Errorf("%s: expected error", xml)
+		}
+	}
+}
+
+func TestTokenizerEntityRef(t *testing.T) {
+	// The entities may be declared in the external subset, which is not read
+	doc := []byte(`<!DOCTYPE doc SYSTEM "doc.dtd" [<!ENTITY b "<b>&f;</b>">]><doc>a&e;&b;</doc>`)
+	var events, tokens recordingHandler
+	if err := NewDefaultRunXML().ParseEvents(bytes.Clone(doc), &events); err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if err := replayTokens(t, bytes.Clone(doc), &tokens); err != nil {
+		t.Fatal("should not fail", err)
+	}
+	expected := "doctype doc\nstart doc\ntext \"a\"\nentityref e\nstart b\nentityref f\nend b\nend doc\n"
+	if events.String() != expected || tokens.String() != expected {
+		t.Errorf("unexpected events:\n%v\ntokens:\n%v", events.String(), tokens.String())
+	}
+	tz, err := NewDefaultRunXML().NewTokenizer(doc)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	var v struct{}
+	if err := xml.NewTokenDecoder(tz.TokenReader()).Decode(&v); err == nil {
+		t.Error("expected error for an unexpanded reference")
+	}
+}
+
+type tokenTestFeed struct {
+	XMLName xml.Name `xml:"urn:feed feed"`
+	Title   string   `xml:"title"`
+	Entries []struct {
+		ID    int    `xml:"id,attr"`
+		Lang  string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+		Link  string `xml:"urn:link href"`
+		Value string `xml:",chardata"`
+	} `xml:"entry"`
+}
+
+func TestTokenReader(t *testing.T) {
+	doc := []byte(`<?xml version="1.0"?>
+<!DOCTYPE feed [<!ENTITY title "Feed &amp; entries">]>
+<feed xmlns="urn:feed" xmlns:l="urn:link">
+	<title>&title;</title>
+	<!-- entries -->
+	<entry id="1" xml:lang="en"><l:href>http://example.com/1</l:href>One</entry>
+	<entry id="2"><![CDATA[<two>]]></entry>
+</feed>`)
+	var expected, found tokenTestFeed
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	d.Entity = map[string]string{"title": "Feed & entries"}
+	if err := d.Decode(&expected); err != nil {
+		t.Fatal(err)
+	}
+	tz, err := NewDefaultRunXML().NewTokenizer(doc)
+	if err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if err := xml.NewTokenDecoder(tz.TokenReader()).Decode(&found); err != nil {
+		t.Fatal("should not fail", err)
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %+v, found %+v", expected, found)
+	}
+	if found.Title != "Feed & entries" || len(found.Entries) != 2 || found.Entries[0].Link == "" {
+		t.Errorf("unexpected result %+v", found)
+	}
+}
@@ -1,26 +1,27 @@
 package runxml
 
 import (
-	"bytes"
+	"encoding/binary"
 	"fmt"
 	"unicode/utf16"
 	"unicode/utf8"
 )
 
-// decodeUTF16 to UTF8. Copied from bradleypeabody
-func decodeUTF16(b []byte) ([]byte, error) {
+// decodeUTF16 to UTF8, with the given byte order
+func decodeUTF16(b []byte, order binary.ByteOrder) ([]byte, error) {
 	if len(b)%2 != 0 {
 		return nil, fmt.Errorf("Must have even length byte slice")
 	}
-	u16s := make([]uint16, 1)
-	ret := &bytes.Buffer{}
-	b8buf := make([]byte, 4)
-	lb := len(b)
-	for i := 0; i < lb; i += 2 {
-		u16s[0] = uint16(b[i]) + (uint16(b[i+1]) << 8)
-		r := utf16.Decode(u16s)
-		n := utf8.EncodeRune(b8buf, r[0])
-		ret.Write(b8buf[:n])
+	ret := make([]byte, 0, len(b)/2*3)
+	for i := 0; i < len(b); i += 2 {
+		r := rune(order.Uint16(b[i:]))
+		if utf16.IsSurrogate(r) && i+3 < len(b) {
+			if r2 := utf16.DecodeRune(r, rune(order.Uint16(b[i+2:]))); r2 != utf8.RuneError {
+				r = r2
+				i += 2
+			}
+		}
+		ret = utf8.AppendRune(ret, r)
 	}
-	return ret.Bytes(), nil
+	return ret, nil
 }
@@ -1,6 +1,7 @@
 package runxml
 
 import (
+	"encoding/binary"
 	"testing"
 )
 
@@ -12,7 +13,7 @@ func TestConvert(t *testing.T) {
 		0x65, 0x0, 0x20, 0x0, 0xf8, 0x0, 0x6c, 0x0,
 		0x21, 0x0, 0x20, 0x0, 0x3a, 0x0, 0x29, 0x0,
 	}
-	u8, err := decodeUTF16(u16)
+	u8, err := decodeUTF16(u16, binary.LittleEndian)
 	if string(u8) != "En blå flaske øl! :)" || err != nil {
 		t.Fail()
 	}
@@ -29,8 +30,19 @@ func TestUnEvenLength(t *testing.T) {
 		0x21, 0x0, 0x20, 0x0, 0x3a, 0x0, 0x29, 0x0,
 		0x29,
 	}
-	_, err := decodeUTF16(u16)
+	_, err := decodeUTF16(u16, binary.LittleEndian)
 	if err == nil {
 		t.Error("Expected uneven length to fail")
 	}
 }
+
+func TestConvertBigEndian(t *testing.T) {
+	u16 := []byte{
+		0x0, 0x45, 0x0, 0x6e, 0x0, 0x20, 0x0, 0xf8,
+		0x0, 0x6c, 0xd8, 0x3c, 0xdf, 0x7a, 0x0, 0x21,
+	}
+	u8, err := decodeUTF16(u16, binary.BigEndian)
+	if string(u8) != "En øl\U0001F37A!" || err != nil {
+		t.Errorf("unexpected result %q %v", u8, err)
+	}
+}
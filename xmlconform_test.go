@@ -1,7 +1,9 @@
 package runxml
 
 import (
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,14 +17,6 @@ func TestInvalideFiles(t *testing.T) {
 	}
 	excludeList := map[string]bool{
 		"002.xml": true, // <.doc></.doc>
-		"007.xml": true, // <doc>&amp no refc</doc>
-		"008.xml": true, // <doc>&.entity;</doc>
-		"009.xml": true, // <doc>&#RE;</doc>
-		"010.xml": true, // <doc>A & B</doc>
-		"014.xml": true, // <doc a1="<foo>"></doc>
-		"020.xml": true, // <doc a1="A & B"></doc>
-		"021.xml": true, // <doc a1="a&b"></doc>
-		"022.xml": true, // <doc a1="&#123:"></doc>
 		"023.xml": true, // <doc 12="34"></doc>
 		"024.xml": true, // <123></123>
 		"025.xml": true, // <doc>]]></doc>
@@ -38,6 +32,85 @@ func TestInvalideFiles(t *testing.T) {
 	testhelp(t, r, f, excludeList, false)
 }
 
+func TestInvalidDTDFiles(t *testing.T) {
+	r := NewDefaultRunXML()
+	for _, testdir := range []string{
+		"xmltestfiles/xmlconf/xmltest/not-wf/sa/0[5-8]*.xml",
+		"xmltestfiles/xmlconf/xmltest/not-wf/sa/1[0-3]*.xml",
+	} {
+		f, err := filepath.Glob(testdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excludeList := map[string]bool{
+			"050.xml": true, // empty document
+			"100.xml": true, // <?xml version="1.0" standalone="YES" ?>
+			"101.xml": true, // <?xml version="1.0" encoding=" UTF-8"?>
+			"102.xml": true, // <?xml version="1.0 " ?>
+		}
+		testhelp(t, r, f, excludeList, false)
+	}
+}
+
+func TestInvalidExternalFiles(t *testing.T) {
+	r := NewDefaultRunXML()
+	r.EntityResolver = NewFileResolver("xmltestfiles")
+	for _, testdir := range []string{
+		"xmltestfiles/xmlconf/xmltest/not-wf/not-sa/*.xml",
+		"xmltestfiles/xmlconf/xmltest/not-wf/ext-sa/*.xml",
+	} {
+		f, err := filepath.Glob(testdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		excludeList := map[string]bool{
+			"005.xml": true, // undeclared parameter entity in the external subset
+		}
+		testhelp(t, r, f, excludeList, false)
+	}
+}
+
+// TestJapaneseFiles compares the trees of the same documents in different encodings. The
+// UTF-16 versions differ in line ends and a few characters, and are only parsed in TestValidFiles.
+func TestJapaneseFiles(t *testing.T) {
+	for _, doc := range []string{"pr-xml", "weekly"} {
+		var expected string
+		for _, enc := range []string{"utf-8", "shift_jis", "euc-jp", "iso-2022-jp"} {
+			fn := "xmltestfiles/xmlconf/japanese/" + doc + "-" + enc + ".xml"
+			r := NewDefaultRunXML()
+			r.EntityResolver = NewFileResolver("xmltestfiles")
+			d, err := r.ParseFile(fn)
+			if err != nil {
+				t.Fatal(fn, err)
+			}
+			var sb strings.Builder
+			for n := d.GetFirstChild(); n != nil; n = n.GetNextSibling() {
+				if n.NodeType == Element {
+					writeTree(&sb, n)
+				}
+			}
+			if expected == "" {
+				expected = sb.String()
+			} else if sb.String() != expected {
+				t.Errorf("%v: tree differs from %v-utf-8.xml", fn, doc)
+			}
+		}
+	}
+}
+
+// writeTree writes the names, values and attributes of a node and its descendants
+func writeTree(sb *strings.Builder, n *GenericNode) {
+	fmt.Fprintf(sb, "%v %q %q", n.NodeType, n.Name, n.Value)
+	for _, a := range n.GetAttributes() {
+		fmt.Fprintf(sb, " %q=%q", a.Name, a.Value)
+	}
+	sb.WriteByte('\n')
+	for c := n.GetFirstChild(); c != nil; c = c.GetNextSibling() {
+		writeTree(sb, c)
+	}
+	sb.WriteString("end\n")
+}
+
 type testDir struct {
 	path      string
 	exclusion map[string]bool
@@ -90,12 +163,17 @@ var testDirs = []testDir{
 		path:      "xmltestfiles/xmlconf/sun/valid/*.xml",
 		exclusion: map[string]bool{},
 	},
+	testDir{
+		path:      "xmltestfiles/xmlconf/japanese/*.xml",
+		exclusion: map[string]bool{},
+	},
 }
 
 func TestValidFiles(t *testing.T) {
 	numFiles := 0
 	for i := range testDirs {
 		r := NewDefaultRunXML()
+		r.EntityResolver = NewFileResolver("xmltestfiles")
 		f, err := filepath.Glob(testDirs[i].path)
 		if err != nil {
 			t.Fatal(err)
@@ -132,3 +210,38 @@ func testhelp(t *testing.T, r *RunXML, f []string, excludeList map[string]bool,
 		}
 	}
 }
+
+// TestNamespaceFiles runs the namespace test suites; the test cases are read from the suite catalogs
+func TestNamespaceFiles(t *testing.T) {
+	for _, catalog := range []string{
+		"xmltestfiles/xmlconf/eduni/namespaces/1.0/rmt-ns10.xml",
+		"xmltestfiles/xmlconf/eduni/namespaces/1.1/rmt-ns11.xml",
+		"xmltestfiles/xmlconf/eduni/namespaces/errata-1e/errata1e.xml",
+	} {
+		doc, err := NewDefaultRunXML().ParseFile(catalog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir, _ := filepath.Split(catalog)
+		for test := doc.GetLastChild().GetFirstChild(); test != nil; test = test.GetNextSibling() {
+			if test.NodeType != Element {
+				continue
+			}
+			attrs := map[string]string{}
+			for _, a := range test.GetAttributes() {
+				attrs[string(a.Name)] = string(a.Value)
+			}
+			if attrs["TYPE"] == "error" {
+				continue // optional errors
+			}
+			r := NewDefaultRunXML()
+			r.StrictNamespaces = true
+			_, err := r.ParseFile(dir + attrs["URI"])
+			if attrs["TYPE"] == "not-wf" && err == nil {
+				t.Errorf("%v%v: expected fail, but test succeded", dir, attrs["URI"])
+			} else if attrs["TYPE"] != "not-wf" && err != nil {
+				t.Errorf("%v%v: expected success, but test failed: %v", dir, attrs["URI"], err)
+			}
+		}
+	}
+}
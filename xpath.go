@@ -0,0 +1,778 @@
+package runxml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// XPath is a compiled XPath 1.0 expression, evaluated against GenericNode trees. It can be
+// used by several goroutines at once.
+type XPath struct {
+	expr string
+	root xpathExpr
+}
+
+// Compile compiles an XPath 1.0 expression. Prefixes in names are not bound; use CompileNS
+// for expressions selecting names in a namespace.
+func Compile(expr string) (*XPath, error) {
+	return CompileNS(expr, nil)
+}
+
+// CompileNS compiles an XPath 1.0 expression, with namespaces binding the prefixes of the
+// names in the expression to namespace names. Unprefixed names select nodes in no namespace,
+// as XPath 1.0 has no default namespace. The prefix xml is always bound.
+func CompileNS(expr string, namespaces map[string]string) (*XPath, error) {
+	p := &xpathParser{lex: xpathLexer{expr: expr}, namespaces: namespaces}
+	if err := p.next(); err != nil {
+		return nil, fmt.Errorf("xpath %q: %w", expr, err)
+	}
+	root, err := p.parseExpr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = fmt.Errorf("unexpected %v at position %v", p.tok, p.tok.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xpath %q: %w", expr, err)
+	}
+	return &XPath{expr: expr, root: root}, nil
+}
+
+// MustCompile is like Compile, but panics if the expression cannot be compiled
+func MustCompile(expr string) *XPath {
+	x, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+// String returns the source text of the expression
+func (x *XPath) String() string {
+	return x.expr
+}
+
+// SelectNodes returns the nodes selected by the expression in document order. Attributes
+// selected by the expression are left out; see SelectAttributes.
+func (g *GenericNode) SelectNodes(expr string) ([]*GenericNode, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.SelectNodes(g)
+}
+
+// SelectNode returns the first node selected by the expression in document order, or nil
+func (g *GenericNode) SelectNode(expr string) (*GenericNode, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.SelectNode(g)
+}
+
+// SelectAttributes returns the attributes selected by the expression in document order
+func (g *GenericNode) SelectAttributes(expr string) ([]*AttributeNode, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.SelectAttributes(g)
+}
+
+// EvaluateString returns the result of the expression converted to a string, as by the
+// XPath string function
+func (g *GenericNode) EvaluateString(expr string) (string, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return "", err
+	}
+	return x.EvaluateString(g)
+}
+
+// EvaluateNumber returns the result of the expression converted to a number, as by the
+// XPath number function
+func (g *GenericNode) EvaluateNumber(expr string) (float64, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return 0, err
+	}
+	return x.EvaluateNumber(g)
+}
+
+// EvaluateBool returns the result of the expression converted to a boolean, as by the
+// XPath boolean function
+func (g *GenericNode) EvaluateBool(expr string) (bool, error) {
+	x, err := Compile(expr)
+	if err != nil {
+		return false, err
+	}
+	return x.EvaluateBool(g)
+}
+
+// SelectNodes returns the nodes selected by the expression with g as context node, in
+// document order. Attributes selected by the expression are left out.
+func (x *XPath) SelectNodes(g *GenericNode) ([]*GenericNode, error) {
+	nodes, err := x.selectNodes(g)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*GenericNode
+	for _, n := range nodes {
+		if n.attr == nil && n.ns == nil {
+			ret = append(ret, n.node)
+		}
+	}
+	return ret, nil
+}
+
+// SelectNode returns the first node selected by the expression with g as context node, in
+// document order, or nil
+func (x *XPath) SelectNode(g *GenericNode) (*GenericNode, error) {
+	nodes, err := x.SelectNodes(g)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// SelectAttributes returns the attributes selected by the expression with g as context
+// node, in document order
+func (x *XPath) SelectAttributes(g *GenericNode) ([]*AttributeNode, error) {
+	nodes, err := x.selectNodes(g)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*AttributeNode
+	for _, n := range nodes {
+		if n.attr != nil {
+			ret = append(ret, n.attr)
+		}
+	}
+	return ret, nil
+}
+
+// EvaluateString returns the result of the expression with g as context node converted
+// to a string
+func (x *XPath) EvaluateString(g *GenericNode) (string, error) {
+	v, err := x.evaluate(g)
+	if err != nil {
+		return "", err
+	}
+	return xpathString(v), nil
+}
+
+// EvaluateNumber returns the result of the expression with g as context node converted
+// to a number
+func (x *XPath) EvaluateNumber(g *GenericNode) (float64, error) {
+	v, err := x.evaluate(g)
+	if err != nil {
+		return 0, err
+	}
+	return xpathNumber(v), nil
+}
+
+// EvaluateBool returns the result of the expression with g as context node converted
+// to a boolean
+func (x *XPath) EvaluateBool(g *GenericNode) (bool, error) {
+	v, err := x.evaluate(g)
+	if err != nil {
+		return false, err
+	}
+	return xpathBool(v), nil
+}
+
+// evaluate returns the value of the expression with g as context node
+func (x *XPath) evaluate(g *GenericNode) (xpathValue, error) {
+	if g == nil {
+		return nil, fmt.Errorf("xpath %q: no context node", x.expr)
+	}
+	e := &xpathEval{}
+	v, err := x.root.eval(e, xpathContext{node: xpathNode{node: g}, position: 1, size: 1})
+	if err != nil {
+		return nil, fmt.Errorf("xpath %q: %w", x.expr, err)
+	}
+	return v, nil
+}
+
+// selectNodes returns the node-set selected by the expression with g as context node
+func (x *XPath) selectNodes(g *GenericNode) ([]xpathNode, error) {
+	v, err := x.evaluate(g)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]xpathNode)
+	if !ok {
+		return nil, fmt.Errorf("xpath %q: result is a %v, not a node-set", x.expr, xpathTypeName(v))
+	}
+	return nodes, nil
+}
+
+// xpathTokenKind is the kind of a token of an XPath expression
+type xpathTokenKind int
+
+const (
+	tokEOF      xpathTokenKind = iota
+	tokNumber                  // A number; num holds the value
+	tokLiteral                 // A string literal; text holds the value
+	tokOperator                // An operator: / // | + - = != < <= > >= and or mod div *
+	tokNameTest                // A name test: *, NCName:* or QName
+	tokNodeType                // comment, text, processing-instruction or node, followed by '('
+	tokFunction                // A function name, followed by '('
+	tokAxis                    // An axis name, followed by '::'
+	tokVariable                // A variable reference; text holds the name
+	tokPunct                   // ( ) [ ] . .. @ , ::
+)
+
+// xpathToken is a token of an XPath expression
+type xpathToken struct {
+	kind xpathTokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t xpathToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// xpathLexer splits an XPath expression in tokens, disambiguating names and '*' by the
+// preceding token as described in section 3.7 of the XPath specification
+type xpathLexer struct {
+	expr string
+	pos  int
+	prev *xpathToken
+}
+
+// isOperand reports whether the previous token ends an operand, so that a following '*' or
+// name is an operator
+func (l *xpathLexer) isOperand() bool {
+	if l.prev == nil {
+		return false
+	}
+	switch l.prev.kind {
+	case tokOperator:
+		return false
+	case tokPunct:
+		switch l.prev.text {
+		case "@", "::", "(", "[", ",":
+			return false
+		}
+	}
+	return true
+}
+
+func (l *xpathLexer) next() (xpathToken, error) {
+	tok, err := l.scan()
+	l.prev = &tok
+	return tok, err
+}
+
+func (l *xpathLexer) skipSpace() {
+	for l.pos < len(l.expr) && lookupWhitespace[l.expr[l.pos]] == 1 {
+		l.pos++
+	}
+}
+
+func (l *xpathLexer) scan() (xpathToken, error) {
+	l.skipSpace()
+	start := l.pos
+	tok := func(kind xpathTokenKind, n int) (xpathToken, error) {
+		l.pos += n
+		return xpathToken{kind: kind, text: l.expr[start:l.pos], pos: start}, nil
+	}
+	if l.pos >= len(l.expr) {
+		return xpathToken{kind: tokEOF, pos: start}, nil
+	}
+	rest := l.expr[l.pos:]
+	c := rest[0]
+	switch {
+	case c == '(' || c == ')' || c == '[' || c == ']' || c == '@' || c == ',':
+		return tok(tokPunct, 1)
+	case strings.HasPrefix(rest, "::"):
+		return tok(tokPunct, 2)
+	case strings.HasPrefix(rest, ".."):
+		return tok(tokPunct, 2)
+	case c == '.' && (len(rest) == 1 || !isDigit(rest[1])):
+		return tok(tokPunct, 1)
+	case c == '.' || isDigit(c):
+		n := 0
+		for n < len(rest) && isDigit(rest[n]) {
+			n++
+		}
+		if n < len(rest) && rest[n] == '.' {
+			n++
+			for n < len(rest) && isDigit(rest[n]) {
+				n++
+			}
+		}
+		t, _ := tok(tokNumber, n)
+		t.num, _ = strconv.ParseFloat(t.text, 64)
+		return t, nil
+	case c == '"' || c == '\'':
+		end := strings.IndexByte(rest[1:], c)
+		if end < 0 {
+			return xpathToken{}, fmt.Errorf("unterminated literal at position %v", start)
+		}
+		t, _ := tok(tokLiteral, end+2)
+		t.text = rest[1 : end+1]
+		return t, nil
+	case strings.HasPrefix(rest, "//"), strings.HasPrefix(rest, "!="),
+		strings.HasPrefix(rest, "<="), strings.HasPrefix(rest, ">="):
+		return tok(tokOperator, 2)
+	case c == '/' || c == '|' || c == '+' || c == '-' || c == '=' || c == '<' || c == '>':
+		return tok(tokOperator, 1)
+	case c == '*':
+		if l.isOperand() {
+			return tok(tokOperator, 1)
+		}
+		return tok(tokNameTest, 1)
+	case c == '$':
+		n := xpathNameLen(rest[1:], true)
+		if n == 0 {
+			return xpathToken{}, fmt.Errorf("expected variable name at position %v", start)
+		}
+		t, _ := tok(tokVariable, n+1)
+		t.text = rest[1 : n+1]
+		return t, nil
+	}
+	n := xpathNameLen(rest, false)
+	if n == 0 {
+		return xpathToken{}, fmt.Errorf("unexpected character %q at position %v", c, start)
+	}
+	if l.isOperand() {
+		switch rest[:n] {
+		case "and", "or", "mod", "div":
+			return tok(tokOperator, n)
+		}
+		return xpathToken{}, fmt.Errorf("expected operator at position %v, found %q", start, rest[:n])
+	}
+	// A prefixed name or NCName:*
+	if n+1 < len(rest) && rest[n] == ':' && rest[n+1] != ':' {
+		if rest[n+1] == '*' {
+			return tok(tokNameTest, n+2)
+		}
+		local := xpathNameLen(rest[n+1:], false)
+		if local == 0 {
+			return xpathToken{}, fmt.Errorf("invalid name at position %v", start)
+		}
+		n += 1 + local
+	}
+	t, _ := tok(tokNameTest, n)
+	// The token following the name decides between function names, node types and axes
+	l.skipSpace()
+	switch after := l.expr[l.pos:]; {
+	case strings.HasPrefix(after, "::"):
+		t.kind = tokAxis
+	case strings.HasPrefix(after, "("):
+		t.kind = tokFunction
+		switch t.text {
+		case "comment", "text", "processing-instruction", "node":
+			t.kind = tokNodeType
+		}
+	}
+	return t, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// xpathNameLen returns the length of the NCName, or QName if qualified, starting s
+func xpathNameLen(s string, qualified bool) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c == ':' && qualified && n > 0 && n+1 < len(s) && s[n+1] != ':' {
+			qualified = false
+			n++
+			continue
+		}
+		if c < 0x80 && (lookupNodeName[c] == 0 || c == ':' || strings.IndexByte("()[]@,|=!<>*+\"'$/;&#%{}~^`\\", c) >= 0) {
+			break
+		}
+		if n == 0 && (c == '-' || c == '.' || isDigit(c)) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// xpathParser parses XPath expressions by recursive descent
+type xpathParser struct {
+	lex        xpathLexer
+	tok        xpathToken
+	namespaces map[string]string
+}
+
+func (p *xpathParser) next() error {
+	tok, err := p.lex.next()
+	p.tok = tok
+	return err
+}
+
+// is reports whether the current token is of the kind and text
+func (p *xpathParser) is(kind xpathTokenKind, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+// expect consumes the current token, which must be of the kind and text
+func (p *xpathParser) expect(kind xpathTokenKind, text string) error {
+	if !p.is(kind, text) {
+		return fmt.Errorf("expected %q at position %v, found %v", text, p.tok.pos, p.tok)
+	}
+	return p.next()
+}
+
+// binaryLevels are the operators of the binary expressions, by increasing precedence
+var binaryLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseBinary(0)
+}
+
+// parseBinary parses the binary expressions of a level of precedence
+func (p *xpathParser) parseBinary(level int) (xpathExpr, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOperator {
+		op := p.tok.text
+		found := false
+		for _, o := range binaryLevels[level] {
+			found = found || o == op
+		}
+		if !found {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.is(tokOperator, "-") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negateExpr{e}, nil
+	}
+	return p.parseUnion()
+}
+
+func (p *xpathParser) parseUnion() (xpathExpr, error) {
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOperator, "|") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left, right}
+	}
+	return left, nil
+}
+
+// parsePath parses a location path, or a filter expression optionally followed by a
+// relative location path
+func (p *xpathParser) parsePath() (xpathExpr, error) {
+	path := &pathExpr{}
+	switch {
+	case p.tok.kind == tokNumber, p.tok.kind == tokLiteral, p.tok.kind == tokVariable,
+		p.tok.kind == tokFunction, p.is(tokPunct, "("):
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		path.filter = filter
+		switch {
+		case p.is(tokOperator, "/"):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case !p.is(tokOperator, "//"):
+			return filter, nil
+		}
+	case p.is(tokOperator, "/"):
+		path.absolute = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !p.startsStep() {
+			return path, nil // the root node
+		}
+		return path, p.parseSteps(path)
+	case p.is(tokOperator, "//"):
+		path.absolute = true
+	}
+	return path, p.parseSteps(path)
+}
+
+// startsStep reports whether the current token starts a location step
+func (p *xpathParser) startsStep() bool {
+	switch p.tok.kind {
+	case tokNameTest, tokNodeType, tokAxis:
+		return true
+	case tokPunct:
+		return p.tok.text == "." || p.tok.text == ".." || p.tok.text == "@"
+	}
+	return false
+}
+
+// parseSteps parses the steps of a relative location path, preceded by '//' if at its start
+func (p *xpathParser) parseSteps(path *pathExpr) error {
+	if p.is(tokOperator, "//") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		path.steps = append(path.steps, &step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}})
+	}
+	for {
+		s, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		// descendant-or-self::node()/child::x selects the same nodes as descendant::x
+		// without positional predicates
+		if n := len(path.steps); n > 0 && s.axis == axisChild && len(s.predicates) == 0 {
+			if last := path.steps[n-1]; last.axis == axisDescendantOrSelf && last.test.kind == testNode && len(last.predicates) == 0 {
+				s.axis = axisDescendant
+				path.steps = path.steps[:n-1]
+			}
+		}
+		path.steps = append(path.steps, s)
+		switch {
+		case p.is(tokOperator, "/"):
+		case p.is(tokOperator, "//"):
+			path.steps = append(path.steps, &step{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}})
+		default:
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *xpathParser) parseStep() (*step, error) {
+	switch {
+	case p.is(tokPunct, "."):
+		return &step{axis: axisSelf, test: nodeTest{kind: testNode}}, p.next()
+	case p.is(tokPunct, ".."):
+		return &step{axis: axisParent, test: nodeTest{kind: testNode}}, p.next()
+	}
+	s := &step{axis: axisChild}
+	switch {
+	case p.is(tokPunct, "@"):
+		s.axis = axisAttribute
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	case p.tok.kind == tokAxis:
+		axis, ok := axisNames[p.tok.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown axis %v at position %v", p.tok, p.tok.pos)
+		}
+		s.axis = axis
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokPunct, "::"); err != nil {
+			return nil, err
+		}
+	}
+	test, err := p.parseNodeTest(s.axis)
+	if err != nil {
+		return nil, err
+	}
+	s.test = test
+	for p.is(tokPunct, "[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		s.predicates = append(s.predicates, pred)
+	}
+	return s, nil
+}
+
+func (p *xpathParser) parseNodeTest(axis xpathAxis) (nodeTest, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNameTest:
+		if err := p.next(); err != nil {
+			return nodeTest{}, err
+		}
+		test := nodeTest{kind: testName}
+		if axis == axisNamespace {
+			test.kind = testNamespace
+		}
+		if tok.text == "*" {
+			test.anyNamespace = true
+			return test, nil
+		}
+		prefix, local, ok := strings.Cut(tok.text, ":")
+		if !ok {
+			test.local = tok.text
+			return test, nil
+		}
+		uri, err := p.namespace(prefix)
+		if err != nil {
+			return nodeTest{}, fmt.Errorf("%w at position %v", err, tok.pos)
+		}
+		test.uri = uri
+		if local != "*" {
+			test.local = local
+		}
+		return test, nil
+	case tokNodeType:
+		if err := p.next(); err != nil {
+			return nodeTest{}, err
+		}
+		if err := p.expect(tokPunct, "("); err != nil {
+			return nodeTest{}, err
+		}
+		test := nodeTest{kind: nodeTypeTests[tok.text]}
+		if test.kind == testPI && p.tok.kind == tokLiteral {
+			test.local = p.tok.text
+			if err := p.next(); err != nil {
+				return nodeTest{}, err
+			}
+		}
+		return test, p.expect(tokPunct, ")")
+	}
+	return nodeTest{}, fmt.Errorf("expected node test at position %v, found %v", tok.pos, tok)
+}
+
+// namespace returns the namespace name bound to a prefix of the expression
+func (p *xpathParser) namespace(prefix string) (string, error) {
+	if prefix == "xml" {
+		return XMLNamespace, nil
+	}
+	uri, ok := p.namespaces[prefix]
+	if !ok {
+		return "", fmt.Errorf("undeclared namespace prefix %q", prefix)
+	}
+	return uri, nil
+}
+
+func (p *xpathParser) parsePredicate() (xpathExpr, error) {
+	if err := p.expect(tokPunct, "["); err != nil {
+		return nil, err
+	}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.expect(tokPunct, "]")
+}
+
+// parseFilter parses a primary expression followed by predicates
+func (p *xpathParser) parseFilter() (xpathExpr, error) {
+	var e xpathExpr
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		e = numberExpr(tok.num)
+	case tokLiteral:
+		e = literalExpr(tok.text)
+	case tokVariable:
+		return nil, fmt.Errorf("variable $%v at position %v: variables are not supported", tok.text, tok.pos)
+	case tokFunction:
+		f, err := p.parseFunctionCall()
+		if err != nil {
+			return nil, err
+		}
+		e = f
+	default: // '('
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.is(tokPunct, ")") {
+			return nil, fmt.Errorf("expected ')' at position %v, found %v", p.tok.pos, p.tok)
+		}
+		e = inner
+	}
+	if tok.kind != tokFunction {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.is(tokPunct, "[") {
+		return e, nil
+	}
+	filter := &filterExpr{primary: e}
+	for p.is(tokPunct, "[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		filter.predicates = append(filter.predicates, pred)
+	}
+	return filter, nil
+}
+
+func (p *xpathParser) parseFunctionCall() (xpathExpr, error) {
+	tok := p.tok
+	f, ok := xpathFunctions[tok.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %v at position %v", tok, tok.pos)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+	call := &functionCall{name: tok.text, fn: f.fn}
+	for !p.is(tokPunct, ")") {
+		if len(call.args) > 0 {
+			if err := p.expect(tokPunct, ","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	if len(call.args) < f.minArgs || f.maxArgs >= 0 && len(call.args) > f.maxArgs {
+		return nil, fmt.Errorf("wrong number of arguments to %v at position %v", tok.text, tok.pos)
+	}
+	return call, p.next()
+}
@@ -0,0 +1,304 @@
+package runxml
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var xpathTestDoc = `<?xml version="1.0"?>
+<!-- catalog -->
+<catalog xmlns:p="urn:prices" xml:lang="en-GB">
+	<book id="b1" xml:id="first" year="2001">
+		<title>Go</title>
+		<author>Ann</author>
+		<p:price>10.50</p:price>
+	</book>
+	<book id="b2" year="1999">
+		<title lang="de">XML <![CDATA[&]]> more</title>
+		<author>Bob</author>
+		<author>Cid</author>
+		<p:price currency="EUR">7</p:price>
+		<?note check?>
+	</book>
+	<!-- third -->
+	<book id="b3" year="2010" xml:lang="fr">
+		<title>  Deux   mots  </title>
+		<p:price>4.25</p:price>
+	</book>
+</catalog>`
+
+// xpathNames returns a description of the nodes selected by an expression: element names
+// with the value of the id attribute, values of other nodes
+func xpathNames(t *testing.T, g *GenericNode, expr string) string {
+	x, err := CompileNS(expr, map[string]string{"pr": "urn:prices"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := x.selectNodes(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s []string
+	for _, n := range nodes {
+		switch {
+		case n.attr != nil:
+			s = append(s, "@"+string(n.attr.Name)+"="+string(n.attr.Value))
+		case n.ns != nil:
+			s = append(s, "ns:"+string(n.ns.prefix))
+		case n.node.NodeType == Element:
+			name := string(n.node.Name)
+			for a := range n.node.Attributes() {
+				if string(a.Name) == "id" {
+					name += "#" + string(a.Value)
+				}
+			}
+			s = append(s, name)
+		case n.node.NodeType == Document:
+			s = append(s, "/")
+		default:
+			s = append(s, n.node.NodeType.String()+":"+strings.TrimSpace(string(n.node.Value)))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestXPathSelect(t *testing.T) {
+	doc, err := NewDefaultRunXML().Parse([]byte(xpathTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, _ := doc.SelectNode("//book[2]")
+	for _, test := range []struct {
+		context *GenericNode
+		expr    string
+		nodes   string
+	}{
+		{doc, "/", "/"},
+		{doc, "/catalog/book", "book#b1 book#b2 book#b3"},
+		{doc, "//book[@year > 2000]/title", "title title"},
+		{doc, "//book[last()]", "book#b3"},
+		{doc, "//book[position() < 3][last()]", "book#b2"},
+		{doc, "//author[1]", "author author"},
+		{doc, "(//author)[1]", "author"},
+		{doc, "//book[author = 'Cid']/@id", "@id=b2"},
+		{doc, "//book[not(author)]", "book#b3"},
+		{doc, "//p:price", "p:price p:price p:price"},
+		{doc, "//pr:*[@currency]", "p:price"},
+		{doc, "//*[local-name() = 'price'][. > 5]", "p:price p:price"},
+		{doc, "//title/text()", "Data:Go Data:XML Cdata:& Data:more Data:Deux   mots"},
+		{doc, "//comment()", "Comment:catalog Comment:third"},
+		{doc, "//processing-instruction('note')", "Pi:check"},
+		{doc, "//processing-instruction('other')", ""},
+		{doc, "//book[@id = 'b1'] | //book[@id = 'b3'] | //book[1]", "book#b1 book#b3"},
+		{doc, "id('first')/title", "title"},
+		{doc, "//book[lang('fr')]", "book#b3"},
+		{doc, "//book[lang('en')]", "book#b1 book#b2"},
+		{b2, "author", "author author"},
+		{b2, "..", "catalog"},
+		{b2, "ancestor::*", "catalog"},
+		{b2, "ancestor-or-self::node()", "/ catalog book#b2"},
+		{b2, "preceding-sibling::*", "book#b1"},
+		{b2, "following-sibling::node()", "Comment:third book#b3"},
+		{b2, "following::title", "title"},
+		{b2, "preceding::*", "book#b1 title author p:price"},
+		{b2, "preceding::node()[1]", "Data:10.50"},
+		{b2, "ancestor::node()[1]", "catalog"},
+		{b2, "descendant::*[2]", "author"},
+		{b2, "descendant-or-self::*[@currency]", "p:price"},
+		{b2, "attribute::*", "@id=b2 @year=1999"},
+		{b2, "@*[2]", "@year=1999"},
+		{b2, "self::book", "book#b2"},
+		{b2, "self::title", ""},
+		{b2, "namespace::*", "ns:xml ns:p"},
+		{b2, "@id/following::author[1]", "author"},
+		{b2, "@id/..", "book#b2"},
+		{b2, "@id/ancestor::*", "catalog book#b2"},
+		{b2, "title/node()", "Data:XML Cdata:& Data:more"},
+		{b2, "./title/../author[last()]", "author"},
+		{b2, "//book[.//p:price[@currency]]", "book#b2"},
+	} {
+		expr := strings.ReplaceAll(test.expr, "p:price", "pr:price")
+		if nodes := xpathNames(t, test.context, expr); nodes != test.nodes {
+			t.Errorf("%v: expected %q, found %q", test.expr, test.nodes, nodes)
+		}
+	}
+}
+
+func TestXPathEvaluate(t *testing.T) {
+	doc, err := NewDefaultRunXML().Parse([]byte(xpathTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		expr, value string
+	}{
+		{"count(//book)", "3"},
+		{"sum(//*[local-name() = 'price'])", "21.75"},
+		{"string(//book[2]/title)", "XML & more"},
+		{"normalize-space(//book[3]/title)", "Deux mots"},
+		{"name(//*[@currency])", "p:price"},
+		{"local-name(//*[@currency])", "price"},
+		{"namespace-uri(//*[@currency])", "urn:prices"},
+		{"namespace-uri(/catalog)", ""},
+		{"name(/)", ""},
+		{"concat('a', 1, true(), 2.5)", "a1true2.5"},
+		{"substring('12345', 2, 3)", "234"},
+		{"substring('12345', 2)", "2345"},
+		{"substring('12345', 1.5, 2.6)", "234"},
+		{"substring('12345', 0, 3)", "12"},
+		{"substring('12345', 0 div 0, 3)", ""},
+		{"substring('12345', 1, 0 div 0)", ""},
+		{"substring('12345', -42, 1 div 0)", "12345"},
+		{"substring('12345', -1 div 0, 1 div 0)", ""},
+		{"substring-before('1999/04/01', '/')", "1999"},
+		{"substring-after('1999/04/01', '/')", "04/01"},
+		{"substring-before('abc', 'x')", ""},
+		{"translate('bar', 'abc', 'ABC')", "BAr"},
+		{"translate('--aaa--', 'abc-', 'ABC')", "AAA"},
+		{"string-length('héllo')", "5"},
+		{"starts-with('hello', 'he')", "true"},
+		{"contains('hello', 'ell')", "true"},
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"7 div 2", "3.5"},
+		{"5 mod 2", "1"},
+		{"-5 mod 2", "-1"},
+		{"5 mod -2", "1"},
+		{"1 div 0", "Infinity"},
+		{"-1 div 0", "-Infinity"},
+		{"0 div 0", "NaN"},
+		{"- - 3", "3"},
+		{"number(' 12.5 ')", "12.5"},
+		{"number('1e3')", "NaN"},
+		{"number('.5')", "0.5"},
+		{"number(true())", "1"},
+		{"floor(-1.5)", "-2"},
+		{"ceiling(1.2)", "2"},
+		{"round(2.5)", "3"},
+		{"round(-2.5)", "-2"},
+		{"round(-0.4)", "0"},
+		{"1000000 * 1000000", "1000000000000"},
+		{"0.1 + 0.2 = 0.30000000000000004", "true"},
+		{"//book/@year = 1999", "true"},
+		{"//book/@year != 1999", "true"},
+		{"//book/@year < 1999", "false"},
+		{"//book/@year >= 2010", "true"},
+		{"//book/@year = //title/@lang", "false"},
+		{"//missing = ''", "false"},
+		{"//missing != ''", "false"},
+		{"//missing = false()", "true"},
+		{"true() = 'x'", "true"},
+		{"1 = '1.0'", "true"},
+		{"'1' = '1.0'", "false"},
+		{"2 > 1 and 1 > 2 or 3 > 2", "true"},
+		{"boolean(//book[4])", "false"},
+		{"boolean('0')", "true"},
+		{"boolean(0)", "false"},
+		{"not(0 div 0)", "true"},
+		{"count(//book[1]/*)", "3"},
+		{"count(//node())", "27"},
+		{"count(//@*)", "11"},
+		{"count(//namespace::p)", "13"},
+		{"string(//book[1]/@year + 1)", "2002"},
+		{"//book[1]/author", "Ann"},
+		{"//book[last()]/@id", "b3"},
+		{"position()", "1"},
+		{"last()", "1"},
+	} {
+		x, err := Compile(test.expr)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		v, err := x.EvaluateString(doc)
+		if err != nil {
+			t.Error(err)
+		} else if v != test.value {
+			t.Errorf("%v: expected %q, found %q", test.expr, test.value, v)
+		}
+	}
+	if n, err := doc.EvaluateNumber("count(//title) * 2"); err != nil || n != 6 {
+		t.Errorf("expected 6, found %v %v", n, err)
+	}
+	if n, err := doc.EvaluateNumber("//title"); err != nil || !math.IsNaN(n) {
+		t.Errorf("expected NaN, found %v %v", n, err)
+	}
+	if b, err := doc.EvaluateBool("//book[@year < 2000]"); err != nil || !b {
+		t.Errorf("expected true, found %v %v", b, err)
+	}
+	attrs, err := doc.SelectAttributes("//@id")
+	if err != nil || len(attrs) != 3 || string(attrs[2].Value) != "b3" {
+		t.Errorf("unexpected attributes %v %v", attrs, err)
+	}
+	if n, err := doc.SelectNode("//nothing"); n != nil || err != nil {
+		t.Errorf("expected no node, found %v %v", n, err)
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"//",
+		"/book[",
+		"book]",
+		"foo(1)",
+		"count()",
+		"count(1, 2)",
+		"book/unknown::x",
+		"'unterminated",
+		"$var",
+		"x:y",
+		"1 +",
+		"book/",
+		"@",
+		"#",
+		"book book",
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+	doc, err := NewDefaultRunXML().Parse([]byte(xpathTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expr := range []string{
+		"count(1)",
+		"1 | //book",
+		"'a'/b",
+		"'a'[1]",
+		"1 + 1",
+	} {
+		if _, err := doc.SelectNodes(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestXPathLexer(t *testing.T) {
+	// Names and '*' are operators after operands
+	doc, err := NewDefaultRunXML().Parse([]byte(`<r><div>6</div><mod>4</mod><and>1</and></r>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for expr, value := range map[string]string{
+		"r/div div r/mod":        "1.5",
+		"r/mod mod 3":            "1",
+		"r/and and r/div":        "true",
+		"count(r/*) * 2":         "6",
+		"r/*[1]*2":               "12",
+		"r/div*r/mod":            "24",
+		"string(r / div)":        "6",
+		"child::r/child::div":    "6",
+		"r/div[. = 6]/..//and":   "1",
+		"count(r/node()[. > 2])": "2",
+	} {
+		v, err := doc.EvaluateString(expr)
+		if err != nil {
+			t.Error(err)
+		} else if v != value {
+			t.Errorf("%v: expected %q, found %q", expr, value, v)
+		}
+	}
+}
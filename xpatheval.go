@@ -0,0 +1,1021 @@
+package runxml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// xpathValue is the value of an XPath expression: a node-set ([]xpathNode), string,
+// number (float64) or boolean
+type xpathValue any
+
+// xpathNode is a node of the XPath data model. Attributes and namespace nodes are identified
+// by their element and the attribute or namespace binding.
+type xpathNode struct {
+	node *GenericNode   // The node, or the element of an attribute or namespace node
+	attr *AttributeNode // The attribute of an attribute node
+	ns   *namespaceNode // The binding of a namespace node
+}
+
+// namespaceNode is a namespace in scope of an element
+type namespaceNode struct {
+	prefix, uri []byte
+	index       int // Position among the namespace nodes of the element
+}
+
+// xpathContext is the context of evaluating an expression
+type xpathContext struct {
+	node           xpathNode
+	position, size int
+}
+
+// xpathEval holds the state of evaluating an expression
+type xpathEval struct {
+	order map[*GenericNode]int // Positions of the nodes in document order, when sorted
+}
+
+// xpathExpr is a compiled XPath expression
+type xpathExpr interface {
+	eval(e *xpathEval, ctx xpathContext) (xpathValue, error)
+}
+
+// xpathAxis is an axis of a location step
+type xpathAxis int
+
+const (
+	axisChild xpathAxis = iota
+	axisDescendant
+	axisParent
+	axisAncestor
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisFollowing
+	axisPreceding
+	axisAttribute
+	axisNamespace
+	axisSelf
+	axisDescendantOrSelf
+	axisAncestorOrSelf
+)
+
+var axisNames = map[string]xpathAxis{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"following":          axisFollowing,
+	"preceding":          axisPreceding,
+	"attribute":          axisAttribute,
+	"namespace":          axisNamespace,
+	"self":               axisSelf,
+	"descendant-or-self": axisDescendantOrSelf,
+	"ancestor-or-self":   axisAncestorOrSelf,
+}
+
+// reverse reports whether the axis is a reverse axis, whose proximity positions are in
+// reverse document order
+func (a xpathAxis) reverse() bool {
+	return a == axisParent || a == axisAncestor || a == axisAncestorOrSelf ||
+		a == axisPreceding || a == axisPrecedingSibling
+}
+
+// testKind is the kind of a node test
+type testKind int
+
+const (
+	testName      testKind = iota // A name test of the principal node type of the axis
+	testNamespace                 // A name test on the namespace axis
+	testNode                      // node()
+	testText                      // text()
+	testComment                   // comment()
+	testPI                        // processing-instruction()
+)
+
+var nodeTypeTests = map[string]testKind{
+	"node":                   testNode,
+	"text":                   testText,
+	"comment":                testComment,
+	"processing-instruction": testPI,
+}
+
+// nodeTest is the node test of a location step
+type nodeTest struct {
+	kind         testKind
+	local        string // Local name, or target of processing-instruction('target'); empty for any
+	uri          string // Namespace name bound to the prefix of the name
+	anyNamespace bool   // Names in any namespace match, for *
+}
+
+// step is a location step
+type step struct {
+	axis       xpathAxis
+	test       nodeTest
+	predicates []xpathExpr
+}
+
+// pathExpr is a location path, or a filter expression followed by a relative location path
+type pathExpr struct {
+	filter   xpathExpr // Expression selecting the initial nodes; nil for location paths
+	absolute bool      // The path starts at the root node
+	steps    []*step
+}
+
+type filterExpr struct {
+	primary    xpathExpr
+	predicates []xpathExpr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right xpathExpr
+}
+
+type negateExpr struct {
+	e xpathExpr
+}
+
+type unionExpr struct {
+	left, right xpathExpr
+}
+
+type numberExpr float64
+
+type literalExpr string
+
+type functionCall struct {
+	name string
+	fn   func(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error)
+	args []xpathExpr
+}
+
+func (n numberExpr) eval(*xpathEval, xpathContext) (xpathValue, error) {
+	return float64(n), nil
+}
+
+func (l literalExpr) eval(*xpathEval, xpathContext) (xpathValue, error) {
+	return string(l), nil
+}
+
+func (n *negateExpr) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	v, err := n.e.eval(e, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return -xpathNumber(v), nil
+}
+
+func (u *unionExpr) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	var sets [2][]xpathNode
+	for i, expr := range []xpathExpr{u.left, u.right} {
+		v, err := expr.eval(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		nodes, ok := v.([]xpathNode)
+		if !ok {
+			return nil, fmt.Errorf("operand of '|' is a %v, not a node-set", xpathTypeName(v))
+		}
+		sets[i] = nodes
+	}
+	return e.sort(append(slices.Clip(sets[0]), sets[1]...)), nil
+}
+
+func (b *binaryExpr) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	left, err := b.left.eval(e, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "or", "and":
+		if xpathBool(left) == (b.op == "or") {
+			return b.op == "or", nil // the right operand is not evaluated
+		}
+		right, err := b.right.eval(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return xpathBool(right), nil
+	}
+	right, err := b.right.eval(e, ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return xpathCompare(b.op, left, right), nil
+	}
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "div":
+		return l / r, nil
+	}
+	return math.Mod(l, r), nil // mod truncates, as the % operator of Java and ECMAScript
+}
+
+func (f *functionCall) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	args := make([]xpathValue, len(f.args))
+	for i, a := range f.args {
+		v, err := a.eval(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := f.fn(e, ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("%v(): %w", f.name, err)
+	}
+	return v, nil
+}
+
+func (f *filterExpr) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	v, err := f.primary.eval(e, ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]xpathNode)
+	if !ok {
+		return nil, fmt.Errorf("predicate applied to a %v, not a node-set", xpathTypeName(v))
+	}
+	return e.filter(slices.Clone(nodes), f.predicates)
+}
+
+func (p *pathExpr) eval(e *xpathEval, ctx xpathContext) (xpathValue, error) {
+	var nodes []xpathNode
+	switch {
+	case p.filter != nil:
+		v, err := p.filter.eval(e, ctx)
+		if err != nil {
+			return nil, err
+		}
+		n, ok := v.([]xpathNode)
+		if !ok {
+			return nil, fmt.Errorf("path applied to a %v, not a node-set", xpathTypeName(v))
+		}
+		nodes = n
+	case p.absolute:
+		root := ctx.node.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		nodes = []xpathNode{{node: root}}
+	default:
+		nodes = []xpathNode{ctx.node}
+	}
+	for _, s := range p.steps {
+		var next []xpathNode
+		var candidates []xpathNode
+		for _, n := range nodes {
+			candidates = candidates[:0]
+			e.axis(s.axis, n, func(c xpathNode) {
+				if s.test.matches(s.axis, c) {
+					candidates = append(candidates, c)
+				}
+			})
+			selected, err := e.filter(candidates, s.predicates)
+			if err != nil {
+				return nil, err
+			}
+			if s.axis.reverse() {
+				slices.Reverse(selected)
+			}
+			next = append(next, selected...)
+		}
+		if len(nodes) > 1 {
+			next = e.sort(next)
+		}
+		nodes = next
+	}
+	if nodes == nil {
+		nodes = []xpathNode{}
+	}
+	return nodes, nil
+}
+
+// filter returns the nodes for which the predicates are true, evaluated with the positions
+// of the nodes in the slice. The nodes are filtered in place.
+func (e *xpathEval) filter(nodes []xpathNode, predicates []xpathExpr) ([]xpathNode, error) {
+	for _, pred := range predicates {
+		kept := nodes[:0]
+		size := len(nodes)
+		for i, n := range nodes {
+			v, err := pred.eval(e, xpathContext{node: n, position: i + 1, size: size})
+			if err != nil {
+				return nil, err
+			}
+			if num, ok := v.(float64); ok {
+				if num == float64(i+1) {
+					kept = append(kept, n)
+				}
+			} else if xpathBool(v) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes, nil
+}
+
+// axis calls yield with the nodes of the axis from n, in proximity order
+func (e *xpathEval) axis(axis xpathAxis, n xpathNode, yield func(xpathNode)) {
+	g := n.node
+	isNode := n.attr == nil && n.ns == nil
+	switch axis {
+	case axisSelf:
+		yield(n)
+	case axisChild:
+		if isNode {
+			for c := g.firstChild; c != nil; c = c.next {
+				yield(xpathNode{node: c})
+			}
+		}
+	case axisDescendant, axisDescendantOrSelf:
+		if axis == axisDescendantOrSelf {
+			yield(n)
+		}
+		if isNode {
+			for c := range g.Descendants() {
+				yield(xpathNode{node: c})
+			}
+		}
+	case axisParent:
+		if !isNode {
+			yield(xpathNode{node: g})
+		} else if g.Parent != nil {
+			yield(xpathNode{node: g.Parent})
+		}
+	case axisAncestor, axisAncestorOrSelf:
+		if axis == axisAncestorOrSelf {
+			yield(n)
+		}
+		if !isNode {
+			yield(xpathNode{node: g})
+		}
+		for a := range g.Ancestors() {
+			yield(xpathNode{node: a})
+		}
+	case axisFollowingSibling:
+		if isNode {
+			for s := range g.FollowingSiblings() {
+				yield(xpathNode{node: s})
+			}
+		}
+	case axisPrecedingSibling:
+		if isNode {
+			for s := g.prev; s != nil; s = s.prev {
+				yield(xpathNode{node: s})
+			}
+		}
+	case axisFollowing:
+		// The descendants of an attribute's element follow the attribute
+		if !isNode {
+			for c := range g.Descendants() {
+				yield(xpathNode{node: c})
+			}
+		}
+		for a := g; a != nil; a = a.Parent {
+			for s := range a.FollowingSiblings() {
+				yield(xpathNode{node: s})
+				for c := range s.Descendants() {
+					yield(xpathNode{node: c})
+				}
+			}
+		}
+	case axisPreceding:
+		// The nodes before n, except its ancestors
+		for a := g; a != nil; a = a.Parent {
+			for s := a.prev; s != nil; s = s.prev {
+				reverseDescendants(s, yield)
+				yield(xpathNode{node: s})
+			}
+		}
+	case axisAttribute:
+		if isNode && g.NodeType == Element {
+			for a := range g.Attributes() {
+				if _, ok := namespaceDecl(a.Name); !ok {
+					yield(xpathNode{node: g, attr: a})
+				}
+			}
+		}
+	case axisNamespace:
+		if isNode && g.NodeType == Element {
+			for _, ns := range inScopeNamespaces(g) {
+				yield(xpathNode{node: g, ns: ns})
+			}
+		}
+	}
+}
+
+// reverseDescendants calls yield with the descendants of g in reverse document order
+func reverseDescendants(g *GenericNode, yield func(xpathNode)) {
+	for c := g.lastChild; c != nil; c = c.prev {
+		reverseDescendants(c, yield)
+		yield(xpathNode{node: c})
+	}
+}
+
+// inScopeNamespaces returns the namespace nodes of an element
+func inScopeNamespaces(g *GenericNode) []*namespaceNode {
+	nodes := []*namespaceNode{{prefix: []byte("xml"), uri: xmlNamespace}}
+	seen := map[string]bool{"xml": true}
+	for n := g; n != nil && n.NodeType == Element; n = n.Parent {
+		for a := range n.Attributes() {
+			prefix, ok := namespaceDecl(a.Name)
+			if !ok || seen[string(prefix)] {
+				continue
+			}
+			seen[string(prefix)] = true
+			if len(a.Value) > 0 { // an empty value undeclares the default namespace
+				nodes = append(nodes, &namespaceNode{prefix: prefix, uri: a.Value, index: len(nodes)})
+			}
+		}
+	}
+	return nodes
+}
+
+// matches reports whether the node test is true for a node of the axis
+func (t *nodeTest) matches(axis xpathAxis, n xpathNode) bool {
+	if n.attr == nil && n.ns == nil && (n.node.NodeType == Declaration || n.node.NodeType == Doctype) {
+		return false // not in the data model of XPath
+	}
+	switch t.kind {
+	case testNode:
+		return true
+	case testNamespace:
+		return n.ns != nil && (t.local == "" || string(n.ns.prefix) == t.local)
+	case testName:
+		var name []byte
+		var uri []byte
+		switch {
+		case axis == axisAttribute:
+			if n.attr == nil {
+				return false
+			}
+			name, uri = n.attr.LocalName(), n.attr.NamespaceURI()
+		case n.attr != nil || n.ns != nil || n.node.NodeType != Element:
+			return false
+		default:
+			name, uri = n.node.LocalName(), n.node.NamespaceURI()
+		}
+		return (t.anyNamespace || string(uri) == t.uri) && (t.local == "" || string(name) == t.local)
+	}
+	if n.attr != nil || n.ns != nil {
+		return false
+	}
+	switch t.kind {
+	case testText:
+		return n.node.NodeType == Data || n.node.NodeType == Cdata
+	case testComment:
+		return n.node.NodeType == Comment
+	}
+	return n.node.NodeType == Pi && (t.local == "" || string(n.node.Name) == t.local)
+}
+
+// sort sorts the nodes in document order, removing duplicates
+func (e *xpathEval) sort(nodes []xpathNode) []xpathNode {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	if e.order == nil {
+		root := nodes[0].node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		e.order = map[*GenericNode]int{root: 0}
+		for n := range root.Descendants() {
+			e.order[n] = len(e.order)
+		}
+	}
+	slices.SortStableFunc(nodes, func(a, b xpathNode) int {
+		if c := e.order[a.node] - e.order[b.node]; c != 0 {
+			return c
+		}
+		return a.suborder() - b.suborder()
+	})
+	return slices.Compact(nodes)
+}
+
+// suborder returns the position of attribute and namespace nodes after their element
+func (n xpathNode) suborder() int {
+	switch {
+	case n.ns != nil:
+		return 1 + n.ns.index
+	case n.attr != nil:
+		i := 1 << 20
+		for a := n.attr.prev; a != nil; a = a.prev {
+			i++
+		}
+		return i
+	}
+	return 0
+}
+
+// stringValue returns the string-value of a node
+func (n xpathNode) stringValue() string {
+	switch {
+	case n.attr != nil:
+		return string(n.attr.Value)
+	case n.ns != nil:
+		return string(n.ns.uri)
+	}
+	switch n.node.NodeType {
+	case Document, Element:
+		var sb strings.Builder
+		for c := range n.node.Descendants() {
+			if c.NodeType == Data || c.NodeType == Cdata {
+				sb.Write(c.Value)
+			}
+		}
+		return sb.String()
+	}
+	return string(n.node.Value)
+}
+
+// xpathTypeName returns the XPath name of the type of a value
+func xpathTypeName(v xpathValue) string {
+	switch v.(type) {
+	case []xpathNode:
+		return "node-set"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	}
+	return "boolean"
+}
+
+// xpathString converts a value to a string, as the string function
+func xpathString(v xpathValue) string {
+	switch v := v.(type) {
+	case []xpathNode:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	case string:
+		return v
+	case float64:
+		return formatXPathNumber(v)
+	case bool:
+		if v {
+			return "true"
+		}
+	}
+	return "false"
+}
+
+// formatXPathNumber returns the string of a number, without exponent
+func formatXPathNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// xpathNumber converts a value to a number, as the number function
+func xpathNumber(v xpathValue) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return parseXPathNumber(xpathString(v))
+}
+
+// parseXPathNumber returns the number of a string matching the Number production, surrounded
+// by whitespace, or NaN
+func parseXPathNumber(s string) float64 {
+	s = strings.Trim(s, " \t\r\n")
+	digits, i := 0, 0
+	if strings.HasPrefix(s, "-") {
+		i++
+	}
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			digits++
+		}
+	}
+	if digits == 0 || i != len(s) {
+		return math.NaN()
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// xpathBool converts a value to a boolean, as the boolean function
+func xpathBool(v xpathValue) bool {
+	switch v := v.(type) {
+	case []xpathNode:
+		return len(v) > 0
+	case string:
+		return len(v) > 0
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	}
+	return v.(bool)
+}
+
+// xpathCompare compares two values with an equality or relational operator, as described
+// in section 3.4 of the XPath specification
+func xpathCompare(op string, left, right xpathValue) bool {
+	if l, ok := left.([]xpathNode); ok {
+		if r, ok := right.(bool); ok {
+			return compareAtoms(op, len(l) > 0, r)
+		}
+		if r, ok := right.([]xpathNode); ok {
+			for _, a := range l {
+				sa := a.stringValue()
+				for _, b := range r {
+					if compareAtoms(op, sa, b.stringValue()) {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		for _, a := range l {
+			if compareAtoms(op, a.stringValue(), right) {
+				return true
+			}
+		}
+		return false
+	}
+	if r, ok := right.([]xpathNode); ok {
+		if l, ok := left.(bool); ok {
+			return compareAtoms(op, l, len(r) > 0)
+		}
+		for _, b := range r {
+			if compareAtoms(op, left, b.stringValue()) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, left, right)
+}
+
+// compareAtoms compares two strings, numbers or booleans
+func compareAtoms(op string, left, right xpathValue) bool {
+	switch op {
+	case "=", "!=":
+		var equal bool
+		_, lb := left.(bool)
+		_, rb := right.(bool)
+		_, ln := left.(float64)
+		_, rn := right.(float64)
+		switch {
+		case lb || rb:
+			equal = xpathBool(left) == xpathBool(right)
+		case ln || rn:
+			equal = xpathNumber(left) == xpathNumber(right)
+		default:
+			equal = xpathString(left) == xpathString(right)
+		}
+		return equal == (op == "=")
+	}
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	}
+	return l >= r
+}
+
+// xpathFunction is a function of the core function library
+type xpathFunction struct {
+	minArgs, maxArgs int // Number of arguments; maxArgs is -1 for any number
+	fn               func(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error)
+}
+
+var xpathFunctions = map[string]xpathFunction{
+	"last":             {0, 0, fnLast},
+	"position":         {0, 0, fnPosition},
+	"count":            {1, 1, fnCount},
+	"id":               {1, 1, fnID},
+	"local-name":       {0, 1, fnLocalName},
+	"namespace-uri":    {0, 1, fnNamespaceURI},
+	"name":             {0, 1, fnName},
+	"string":           {0, 1, fnString},
+	"concat":           {2, -1, fnConcat},
+	"starts-with":      {2, 2, fnStartsWith},
+	"contains":         {2, 2, fnContains},
+	"substring-before": {2, 2, fnSubstringBefore},
+	"substring-after":  {2, 2, fnSubstringAfter},
+	"substring":        {2, 3, fnSubstring},
+	"string-length":    {0, 1, fnStringLength},
+	"normalize-space":  {0, 1, fnNormalizeSpace},
+	"translate":        {3, 3, fnTranslate},
+	"boolean":          {1, 1, fnBoolean},
+	"not":              {1, 1, fnNot},
+	"true":             {0, 0, fnTrue},
+	"false":            {0, 0, fnFalse},
+	"lang":             {1, 1, fnLang},
+	"number":           {0, 1, fnNumber},
+	"sum":              {1, 1, fnSum},
+	"floor":            {1, 1, fnFloor},
+	"ceiling":          {1, 1, fnCeiling},
+	"round":            {1, 1, fnRound},
+}
+
+// nodeSetArg returns an argument that must be a node-set
+func nodeSetArg(v xpathValue) ([]xpathNode, error) {
+	nodes, ok := v.([]xpathNode)
+	if !ok {
+		return nil, fmt.Errorf("argument is a %v, not a node-set", xpathTypeName(v))
+	}
+	return nodes, nil
+}
+
+// nodeArg returns the first node of an optional node-set argument, or the context node
+func nodeArg(ctx xpathContext, args []xpathValue) (xpathNode, bool, error) {
+	if len(args) == 0 {
+		return ctx.node, true, nil
+	}
+	nodes, err := nodeSetArg(args[0])
+	if err != nil || len(nodes) == 0 {
+		return xpathNode{}, false, err
+	}
+	return nodes[0], true, nil
+}
+
+// stringArg returns an optional argument converted to a string, or the string-value of the
+// context node
+func stringArg(ctx xpathContext, args []xpathValue) string {
+	if len(args) == 0 {
+		return ctx.node.stringValue()
+	}
+	return xpathString(args[0])
+}
+
+func fnLast(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return float64(ctx.size), nil
+}
+
+func fnPosition(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return float64(ctx.position), nil
+}
+
+func fnCount(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	nodes, err := nodeSetArg(args[0])
+	return float64(len(nodes)), err
+}
+
+// fnID selects elements by their xml:id attributes, as the tree does not record which
+// attributes are declared of type ID
+func fnID(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	var ids []string
+	if nodes, ok := args[0].([]xpathNode); ok {
+		for _, n := range nodes {
+			ids = append(ids, strings.Fields(n.stringValue())...)
+		}
+	} else {
+		ids = strings.Fields(xpathString(args[0]))
+	}
+	root := ctx.node.node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	ret := []xpathNode{}
+	for n := range root.Descendants() {
+		if n.NodeType != Element {
+			continue
+		}
+		for a := range n.Attributes() {
+			if string(a.Name) == "xml:id" && slices.Contains(ids, string(a.Value)) {
+				ret = append(ret, xpathNode{node: n})
+				break
+			}
+		}
+	}
+	return ret, nil
+}
+
+func fnLocalName(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	n, ok, err := nodeArg(ctx, args)
+	if !ok {
+		return "", err
+	}
+	switch {
+	case n.attr != nil:
+		return string(n.attr.LocalName()), nil
+	case n.ns != nil:
+		return string(n.ns.prefix), nil
+	case n.node.NodeType == Element:
+		return string(n.node.LocalName()), nil
+	case n.node.NodeType == Pi:
+		return string(n.node.Name), nil
+	}
+	return "", nil
+}
+
+func fnNamespaceURI(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	n, ok, err := nodeArg(ctx, args)
+	if !ok {
+		return "", err
+	}
+	switch {
+	case n.attr != nil:
+		return string(n.attr.NamespaceURI()), nil
+	case n.ns == nil && n.node.NodeType == Element:
+		return string(n.node.NamespaceURI()), nil
+	}
+	return "", nil
+}
+
+func fnName(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	n, ok, err := nodeArg(ctx, args)
+	if !ok {
+		return "", err
+	}
+	switch {
+	case n.attr != nil:
+		return string(n.attr.Name), nil
+	case n.ns != nil:
+		return string(n.ns.prefix), nil
+	case n.node.NodeType == Element, n.node.NodeType == Pi:
+		return string(n.node.Name), nil
+	}
+	return "", nil
+}
+
+func fnString(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return stringArg(ctx, args), nil
+}
+
+func fnConcat(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	var sb strings.Builder
+	for _, a := range args {
+		sb.WriteString(xpathString(a))
+	}
+	return sb.String(), nil
+}
+
+func fnStartsWith(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return strings.HasPrefix(xpathString(args[0]), xpathString(args[1])), nil
+}
+
+func fnContains(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return strings.Contains(xpathString(args[0]), xpathString(args[1])), nil
+}
+
+func fnSubstringBefore(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	before, _, found := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+	if !found {
+		return "", nil
+	}
+	return before, nil
+}
+
+func fnSubstringAfter(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	_, after, _ := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+	return after, nil
+}
+
+// fnSubstring returns the characters at positions from round(start) before
+// round(start)+round(length), with the rules of IEEE 754 for NaN and infinity
+func fnSubstring(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	s := xpathString(args[0])
+	start := xpathRound(xpathNumber(args[1]))
+	end := math.Inf(1)
+	if len(args) == 3 {
+		end = start + xpathRound(xpathNumber(args[2]))
+	}
+	var sb strings.Builder
+	p := 0.0
+	for _, c := range s {
+		p++
+		if p >= start && p < end {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+func fnStringLength(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return float64(utf8.RuneCountInString(stringArg(ctx, args))), nil
+}
+
+func fnNormalizeSpace(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return strings.Join(strings.FieldsFunc(stringArg(ctx, args), func(c rune) bool {
+		return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+	}), " "), nil
+}
+
+func fnTranslate(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	from, to := []rune(xpathString(args[1])), []rune(xpathString(args[2]))
+	return strings.Map(func(c rune) rune {
+		i := slices.Index(from, c)
+		switch {
+		case i < 0:
+			return c
+		case i < len(to):
+			return to[i]
+		}
+		return -1 // removed
+	}, xpathString(args[0])), nil
+}
+
+func fnBoolean(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return xpathBool(args[0]), nil
+}
+
+func fnNot(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return !xpathBool(args[0]), nil
+}
+
+func fnTrue(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return true, nil
+}
+
+func fnFalse(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return false, nil
+}
+
+// fnLang reports whether the xml:lang in scope of the context node is the language, or a
+// sublanguage of it
+func fnLang(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	lang := []byte(xpathString(args[0]))
+	for n := ctx.node.node; n != nil; n = n.Parent {
+		for a := range n.Attributes() {
+			if string(a.Name) != "xml:lang" {
+				continue
+			}
+			v := a.Value
+			if i := bytes.IndexByte(v, '-'); i >= 0 && i == len(lang) {
+				v = v[:i]
+			}
+			return bytes.EqualFold(v, lang), nil
+		}
+	}
+	return false, nil
+}
+
+func fnNumber(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	if len(args) == 0 {
+		return parseXPathNumber(ctx.node.stringValue()), nil
+	}
+	return xpathNumber(args[0]), nil
+}
+
+func fnSum(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	nodes, err := nodeSetArg(args[0])
+	sum := 0.0
+	for _, n := range nodes {
+		sum += parseXPathNumber(n.stringValue())
+	}
+	return sum, err
+}
+
+func fnFloor(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return math.Floor(xpathNumber(args[0])), nil
+}
+
+func fnCeiling(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return math.Ceil(xpathNumber(args[0])), nil
+}
+
+func fnRound(e *xpathEval, ctx xpathContext, args []xpathValue) (xpathValue, error) {
+	return xpathRound(xpathNumber(args[0])), nil
+}
+
+// xpathRound returns the integer closest to f, rounding halves towards positive infinity
+func xpathRound(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	if f < 0 && f >= -0.5 {
+		return math.Copysign(0, -1)
+	}
+	return math.Floor(f + 0.5)
+}